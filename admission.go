@@ -0,0 +1,107 @@
+package noise
+
+import (
+	"context"
+	"log"
+	"net"
+	"sync"
+	"sync/atomic"
+)
+
+// admission bounds the incoming handshakes in progress.
+// A connection flood can't exhaust CPU and memory running unlimited handshakes,
+// and a single address can't take all the handshake slots.
+type admission struct {
+	mu         sync.Mutex
+	pending    uint32
+	perIP      map[string]uint32
+	rejected   atomic.Uint64 // rejected by max pending handshakes
+	rejectedIP atomic.Uint64 // rejected by max handshakes per IP
+}
+
+func newAdmission() *admission {
+	return &admission{perIP: make(map[string]uint32)}
+}
+
+// Acquire reserves a handshake slot for the IP address.
+// It returns false if the limits are exceeded, a limit equal to 0 means unlimited.
+func (a *admission) Acquire(ip net.IP, max, maxPerIP uint32) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if max > 0 && a.pending >= max {
+		a.rejected.Add(1)
+		return false
+	}
+
+	key := ip.String()
+	if ip != nil && maxPerIP > 0 && a.perIP[key] >= maxPerIP {
+		a.rejectedIP.Add(1)
+		return false
+	}
+
+	a.pending++
+	if ip != nil {
+		a.perIP[key]++
+	}
+
+	return true
+}
+
+// Release frees the handshake slot for the IP address.
+func (a *admission) Release(ip net.IP) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.pending--
+	if ip == nil {
+		return
+	}
+
+	key := ip.String()
+	a.perIP[key]--
+	if a.perIP[key] == 0 {
+		delete(a.perIP, key)
+	}
+}
+
+// Pending returns the number of handshakes in progress.
+func (a *admission) Pending() uint32 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.pending
+}
+
+// [HandshakeStats] reports the state of incoming handshakes.
+type HandshakeStats struct {
+	// Incoming handshakes in progress.
+	Pending uint32
+	// Connections rejected because max pending handshakes was reached.
+	Rejected uint64
+	// Connections rejected because max handshakes per IP was reached.
+	RejectedPerIP uint64
+}
+
+// Handshakes returns the incoming handshakes statistics.
+func (n *Node) Handshakes() HandshakeStats {
+	return HandshakeStats{
+		Pending:       n.admission.Pending(),
+		Rejected:      n.admission.rejected.Load(),
+		RejectedPerIP: n.admission.rejectedIP.Load(),
+	}
+}
+
+// admit runs the handshake for incoming connection if there is a free handshake slot.
+// Otherwise the connection is closed without handshake.
+func (n *Node) admit(ctx context.Context, conn net.Conn) {
+	ip := hostIP(conn.RemoteAddr().String())
+	if !n.admission.Acquire(ip, n.config.MaxPendingHandshakes(), n.config.MaxHandshakesPerIP()) {
+		log.Printf("too many handshakes in progress, connection rejected: %s", conn.RemoteAddr())
+		conn.Close()
+		return
+	}
+
+	// We need to run in a separate goroutine to improve time performance between nodes requesting connections.
+	go func() {
+		defer n.admission.Release(ip)
+		n.handshake(ctx, conn, false, "")
+	}()
+}
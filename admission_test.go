@@ -0,0 +1,100 @@
+package noise
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/geolffreym/p2p-noise/config"
+)
+
+func TestAdmissionLimits(t *testing.T) {
+	a := newAdmission()
+	ipA := net.ParseIP("10.0.0.1")
+	ipB := net.ParseIP("10.0.0.2")
+
+	acquires := []struct {
+		ip       net.IP
+		expected bool
+	}{
+		{ipA, true},
+		{ipA, true},
+		{ipA, false}, // per IP limit
+		{ipB, true},
+		{nil, false}, // global limit
+	}
+
+	for _, e := range acquires {
+		if a.Acquire(e.ip, 3, 2) != e.expected {
+			t.Errorf("expected acquire for %v = %v", e.ip, e.expected)
+		}
+	}
+
+	if a.Pending() != 3 || a.rejected.Load() != 1 || a.rejectedIP.Load() != 1 {
+		t.Errorf("expected 3 pending and 1 rejected for each limit")
+	}
+
+	a.Release(ipA)
+	if !a.Acquire(ipA, 3, 2) {
+		t.Errorf("expected acquire after release")
+	}
+
+	// Zero limits means unlimited.
+	unlimited := newAdmission()
+	for i := 0; i < 100; i++ {
+		if !unlimited.Acquire(ipA, 0, 0) {
+			t.Fatalf("expected unlimited handshakes")
+		}
+	}
+}
+
+func TestHandshakesPerIP(t *testing.T) {
+	configurationA := config.New()
+	configurationA.Write(
+		config.SetSelfListeningAddress("127.0.0.1:"),
+		config.SetMaxHandshakesPerIP(1),
+	)
+
+	nodeA := New(configurationA)
+	nodeB := New(config.New())
+	defer nodeA.Close()
+	defer nodeB.Close()
+
+	<-whenReadyForIncomingDial(nodeA)
+	// A connection that never starts the handshake holds the slot.
+	conn, err := net.Dial("tcp", nodeA.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	timeout := time.After(2 * time.Second)
+	for nodeA.Handshakes().Pending != 1 {
+		select {
+		case <-timeout:
+			t.Fatalf("expected pending handshake")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	if err := nodeB.Dial(nodeA.LocalAddr().String()); err == nil {
+		t.Errorf("expected handshake rejected by per IP limit")
+	}
+
+	if stats := nodeA.Handshakes(); stats.RejectedPerIP != 1 {
+		t.Errorf("expected 1 rejected handshake, got %+v", stats)
+	}
+
+	// The slot is released after the stalled handshake fails.
+	conn.Close()
+	for nodeA.Handshakes().Pending != 0 {
+		select {
+		case <-timeout:
+			t.Fatalf("expected handshake slot released")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	if err := nodeB.Dial(nodeA.LocalAddr().String()); err != nil {
+		t.Errorf("expected handshake allowed after release, got %v", err)
+	}
+}
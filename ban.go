@@ -0,0 +1,446 @@
+package noise
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// offense represents a misbehaviour of a remote peer.
+type offense uint8
+
+const (
+	// Messages failing decryption or signature verification.
+	badSignature offense = iota
+	// Messages bigger than max buffer size.
+	oversizedFrame
+	// Failed handshakes from the same address.
+	handshakeFailure
+)
+
+// strikeWindow is the time an offense is counted towards a ban.
+const strikeWindow = 10 * time.Minute
+
+// [Ban] represents a banned peer identity or IP address.
+// Only one of ID or IP is set for each ban.
+type Ban struct {
+	ID ID
+	IP net.IP
+	// Expires is zero if the ban never expires.
+	Expires time.Time
+}
+
+// Permanent returns true if the ban never expires.
+func (b Ban) Permanent() bool {
+	return b.Expires.IsZero()
+}
+
+// banRecord is the persisted representation of a ban.
+type banRecord struct {
+	ID      string    `json:"id,omitempty"`
+	IP      string    `json:"ip,omitempty"`
+	Expires time.Time `json:"expires,omitempty"`
+}
+
+// strike identifies the offense counter for a peer ID or IP address.
+type strike struct {
+	kind    offense
+	subject string
+}
+
+// banList keeps the banned peer IDs and IP addresses with their expiration.
+// If a path is set the list is persisted to file on every change, so bans survive restarts.
+type banList struct {
+	mu      sync.Mutex
+	ids     map[ID]time.Time
+	ips     map[string]time.Time
+	strikes map[strike][]time.Time // offenses during the last strike window
+	purged  time.Time
+	path    string
+}
+
+func newBanList(path string) *banList {
+	return &banList{
+		ids:     make(map[ID]time.Time),
+		ips:     make(map[string]time.Time),
+		strikes: make(map[strike][]time.Time),
+		purged:  time.Now(),
+		path:    path,
+	}
+}
+
+// expired returns true if the expiration time is set and already passed.
+func expired(expires time.Time) bool {
+	return !expires.IsZero() && time.Now().After(expires)
+}
+
+// expiration returns the expiration time for a ban duration.
+// A duration equal to 0 means that the ban never expires.
+func expiration(duration time.Duration) time.Time {
+	if duration <= 0 {
+		return time.Time{}
+	}
+
+	return time.Now().Add(duration)
+}
+
+// Load reads the persisted bans from file.
+// A missing file is not an error, the list starts empty.
+func (b *banList) Load() error {
+	if b.path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(b.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	var records []banRecord
+	if err := json.Unmarshal(data, &records); err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, r := range records {
+		if expired(r.Expires) {
+			continue
+		}
+
+		if r.IP != "" {
+			if ip := net.ParseIP(r.IP); ip != nil {
+				b.ips[ip.String()] = r.Expires
+			}
+
+			continue
+		}
+
+		raw, err := hex.DecodeString(r.ID)
+		if err != nil || len(raw) != len(ID{}) {
+			log.Printf("invalid banned peer id: %s", r.ID)
+			continue
+		}
+
+		b.ids[newIDFromString(string(raw))] = r.Expires
+	}
+
+	return nil
+}
+
+// Save writes the bans to file.
+// The file is replaced atomically to avoid partial writes on crashes.
+func (b *banList) Save() error {
+	if b.path == "" {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	records := make([]banRecord, 0, len(b.ids)+len(b.ips))
+	for id, expires := range b.ids {
+		records = append(records, banRecord{ID: hex.EncodeToString(id.Bytes()), Expires: expires})
+	}
+
+	for ip, expires := range b.ips {
+		records = append(records, banRecord{IP: ip, Expires: expires})
+	}
+
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(b.path), filepath.Base(b.path)+".*")
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), b.path)
+}
+
+// BanID bans the peer ID until expiration time.
+func (b *banList) BanID(id ID, expires time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.ids[id] = expires
+}
+
+// BanIP bans the IP address until expiration time.
+func (b *banList) BanIP(ip net.IP, expires time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.ips[ip.String()] = expires
+}
+
+// UnbanID removes the peer ID from list.
+// It returns false if the ID wasn't banned.
+func (b *banList) UnbanID(id ID) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	_, ok := b.ids[id]
+	delete(b.ids, id)
+	return ok
+}
+
+// UnbanIP removes the IP address from list.
+// It returns false if the IP address wasn't banned.
+func (b *banList) UnbanIP(ip net.IP) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	_, ok := b.ips[ip.String()]
+	delete(b.ips, ip.String())
+	return ok
+}
+
+// BannedID returns true if the peer ID is banned.
+// Expired bans are removed on lookup.
+func (b *banList) BannedID(id ID) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	expires, ok := b.ids[id]
+	if ok && expired(expires) {
+		delete(b.ids, id)
+		return false
+	}
+
+	return ok
+}
+
+// BannedIP returns true if the IP address is banned.
+// A nil IP is never banned.
+// Expired bans are removed on lookup.
+func (b *banList) BannedIP(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	key := ip.String()
+	expires, ok := b.ips[key]
+	if ok && expired(expires) {
+		delete(b.ips, key)
+		return false
+	}
+
+	return ok
+}
+
+// List returns the active bans.
+func (b *banList) List() []Ban {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	bans := make([]Ban, 0, len(b.ids)+len(b.ips))
+	for id, expires := range b.ids {
+		if !expired(expires) {
+			bans = append(bans, Ban{ID: id, Expires: expires})
+		}
+	}
+
+	for ip, expires := range b.ips {
+		if !expired(expires) {
+			bans = append(bans, Ban{IP: net.ParseIP(ip), Expires: expires})
+		}
+	}
+
+	return bans
+}
+
+// Strike counts an offense for subject.
+// It returns true if the offenses during the last strike window reach max, then the counter is reset.
+// A max equal to 0 disables the counter.
+func (b *banList) Strike(kind offense, subject string, max int) bool {
+	if max <= 0 {
+		return false
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	// Subjects without recent offenses are purged lazily at most once every window.
+	if now.Sub(b.purged) > strikeWindow {
+		for key, offenses := range b.strikes {
+			if now.Sub(offenses[len(offenses)-1]) > strikeWindow {
+				delete(b.strikes, key)
+			}
+		}
+
+		b.purged = now
+	}
+
+	key := strike{kind, subject}
+	offenses := append(recent(b.strikes[key], now), now)
+	if len(offenses) < max {
+		b.strikes[key] = offenses
+		return false
+	}
+
+	delete(b.strikes, key)
+	return true
+}
+
+// recent returns the offenses during the strike window before now.
+func recent(offenses []time.Time, now time.Time) []time.Time {
+	for i, at := range offenses {
+		if now.Sub(at) <= strikeWindow {
+			return offenses[i:]
+		}
+	}
+
+	return nil
+}
+
+// remoteIP returns the remote IP address for peer connection.
+func remoteIP(peer *peer) net.IP {
+	return hostIP(peer.s.RemoteAddr().String())
+}
+
+// persistBans saves the ban list logging any error.
+func (n *Node) persistBans() {
+	if err := n.bans.Save(); err != nil {
+		log.Printf("error saving ban list: %v", err)
+	}
+}
+
+// kick closes the connected peers matching the banned ID or IP address.
+func (n *Node) kick(banned func(*peer) bool) {
+	for peer := range n.router.Table() {
+		if !banned(peer) {
+			continue
+		}
+
+		if err := n.ClosePeer(peer.ID().String(), ReasonBanned); err != nil {
+			log.Printf("error closing banned peer: %v", err)
+		}
+	}
+}
+
+// Ban bans the peer ID for duration and closes the connection with peer.
+// A duration equal to 0 bans the peer forever.
+// Banned peers are rejected after handshake.
+func (n *Node) Ban(rawID string, duration time.Duration) {
+	id := newIDFromString(rawID)
+	n.bans.BanID(id, expiration(duration))
+	n.persistBans()
+	n.kick(func(p *peer) bool { return p.ID() == id })
+}
+
+// BanIP bans the IP address for duration and closes the connections with peers using the address.
+// A duration equal to 0 bans the address forever.
+// Connections from banned addresses are rejected before handshake.
+// Returns an error if the IP address is invalid.
+func (n *Node) BanIP(ip string, duration time.Duration) error {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return &net.ParseError{Type: "IP address", Text: ip}
+	}
+
+	n.bans.BanIP(addr, expiration(duration))
+	n.persistBans()
+	n.kick(func(p *peer) bool { return addr.Equal(remoteIP(p)) })
+	return nil
+}
+
+// Unban removes the ban for peer ID.
+// It returns false if the peer wasn't banned.
+func (n *Node) Unban(rawID string) bool {
+	ok := n.bans.UnbanID(newIDFromString(rawID))
+	if ok {
+		n.persistBans()
+	}
+
+	return ok
+}
+
+// UnbanIP removes the ban for IP address.
+// It returns false if the address wasn't banned or isn't valid.
+func (n *Node) UnbanIP(ip string) bool {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return false
+	}
+
+	ok := n.bans.UnbanIP(addr)
+	if ok {
+		n.persistBans()
+	}
+
+	return ok
+}
+
+// Bans returns the active bans for peer IDs and IP addresses.
+func (n *Node) Bans() []Ban {
+	return n.bans.List()
+}
+
+// banned returns true if the peer ID or the remote address is banned.
+func (n *Node) banned(id ID, addr net.Addr) bool {
+	return n.bans.BannedID(id) || n.bans.BannedIP(hostIP(addr.String()))
+}
+
+// misbehaved counts the offense for peer based on the error that stopped the connection.
+// If the offenses exceed the configured threshold both peer ID and remote address are banned.
+// It returns true if the peer was banned.
+func (n *Node) misbehaved(peer *peer, err error) bool {
+	var max int
+	var secErr *SecError
+	var overflowErr *OverflowError
+	switch {
+	case errors.As(err, &secErr):
+		max = n.config.MaxBadSignatures()
+		if !n.bans.Strike(badSignature, peer.ID().String(), max) {
+			return false
+		}
+	case errors.As(err, &overflowErr):
+		max = n.config.MaxOversizedFrames()
+		if !n.bans.Strike(oversizedFrame, peer.ID().String(), max) {
+			return false
+		}
+	default:
+		return false
+	}
+
+	log.Printf("banning misbehaving peer %s: %v", peer.s.RemoteAddr(), err)
+	expires := expiration(n.config.BanDuration())
+	n.bans.BanID(peer.ID(), expires)
+	if ip := remoteIP(peer); ip != nil {
+		n.bans.BanIP(ip, expires)
+	}
+
+	n.persistBans()
+	return true
+}
+
+// failedHandshake counts a failed incoming handshake for remote address.
+// If the failures exceed the configured threshold the address is banned.
+func (n *Node) failedHandshake(addr net.Addr) {
+	ip := hostIP(addr.String())
+	if ip == nil || !n.bans.Strike(handshakeFailure, ip.String(), n.config.MaxHandshakeFailures()) {
+		return
+	}
+
+	log.Printf("banning address %s after failed handshakes", ip)
+	n.bans.BanIP(ip, expiration(n.config.BanDuration()))
+	n.persistBans()
+}
@@ -0,0 +1,262 @@
+package noise
+
+import (
+	"errors"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/geolffreym/p2p-noise/config"
+)
+
+func TestBanListExpiry(t *testing.T) {
+	bans := newBanList("")
+	ip := net.ParseIP("10.0.0.1")
+
+	bans.BanID(peerA.ID(), time.Time{})
+	bans.BanID(peerB.ID(), time.Now().Add(-time.Second))
+	bans.BanIP(ip, expiration(time.Hour))
+
+	if !bans.BannedID(peerA.ID()) || !bans.BannedIP(ip) {
+		t.Errorf("expected peer and address banned")
+	}
+
+	if bans.BannedID(peerB.ID()) {
+		t.Errorf("expected expired ban removed")
+	}
+
+	if bans.BannedIP(nil) {
+		t.Errorf("expected nil IP never banned")
+	}
+
+	if len(bans.List()) != 2 {
+		t.Errorf("expected 2 active bans, got %d", len(bans.List()))
+	}
+
+	if !bans.UnbanID(peerA.ID()) || bans.UnbanID(peerA.ID()) {
+		t.Errorf("expected unban only once")
+	}
+
+	if !bans.UnbanIP(ip) || bans.BannedIP(ip) {
+		t.Errorf("expected address unbanned")
+	}
+}
+
+func TestBanListPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bans.json")
+	bans := newBanList(path)
+	bans.BanID(peerA.ID(), time.Time{})
+	bans.BanIP(net.ParseIP("10.0.0.1"), expiration(time.Hour))
+	bans.BanIP(net.ParseIP("10.0.0.2"), time.Now().Add(-time.Second))
+	if err := bans.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	restored := newBanList(path)
+	if err := restored.Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !restored.BannedID(peerA.ID()) || !restored.BannedIP(net.ParseIP("10.0.0.1")) {
+		t.Errorf("expected bans restored from file")
+	}
+
+	if len(restored.List()) != 2 {
+		t.Errorf("expected expired bans discarded on load, got %d bans", len(restored.List()))
+	}
+
+	// Missing file means no bans.
+	empty := newBanList(filepath.Join(t.TempDir(), "missing.json"))
+	if err := empty.Load(); err != nil {
+		t.Errorf("expected no error for missing file, got %v", err)
+	}
+}
+
+func TestBanListStrike(t *testing.T) {
+	bans := newBanList("")
+	strikes := []struct {
+		max      int
+		expected []bool
+	}{
+		{3, []bool{false, false, true, false}},
+		{1, []bool{true, true}},
+		{0, []bool{false, false, false}},
+	}
+
+	for _, e := range strikes {
+		for i, expected := range e.expected {
+			if got := bans.Strike(badSignature, "subject", e.max); got != expected {
+				t.Errorf("expected strike %d with max %d = %v, got %v", i, e.max, expected, got)
+			}
+		}
+	}
+
+	// Offenses out of the strike window are not counted.
+	bans.Strike(badSignature, "old", 2)
+	bans.strikes[strike{badSignature, "old"}][0] = time.Now().Add(-2 * strikeWindow)
+	if bans.Strike(badSignature, "old", 2) {
+		t.Errorf("expected expired offense not counted")
+	}
+
+	bans.strikes[strike{badSignature, "old"}][0] = time.Now().Add(-2 * strikeWindow)
+	bans.purged = time.Now().Add(-2 * strikeWindow)
+	bans.Strike(badSignature, "new", 2)
+	if _, ok := bans.strikes[strike{badSignature, "old"}]; ok {
+		t.Errorf("expected subject without recent offenses purged")
+	}
+}
+
+func TestMisbehavedPeer(t *testing.T) {
+	configuration := config.New()
+	configuration.Write(
+		config.SetMaxBadSignatures(2),
+		config.SetMaxOversizedFrames(1),
+	)
+
+	node := New(configuration)
+	peer := newPeer(mockSession(&mockConn{addr: "10.0.0.1:8010"}, PeerAPb))
+	signatureErr := errVerifyingSignature(errors.New("invalid"))
+
+	if node.misbehaved(peer, errors.New("EOF")) {
+		t.Errorf("expected network errors not counted as misbehaviour")
+	}
+
+	if node.misbehaved(peer, signatureErr) {
+		t.Errorf("expected peer not banned before threshold")
+	}
+
+	if !node.misbehaved(peer, signatureErr) {
+		t.Errorf("expected peer banned after threshold")
+	}
+
+	if !node.banned(peer.ID(), peer.s.RemoteAddr()) || !node.bans.BannedIP(net.ParseIP("10.0.0.1")) {
+		t.Errorf("expected peer ID and address banned")
+	}
+
+	other := newPeer(mockSession(&mockConn{addr: "10.0.0.2:8010"}, PeerBPb))
+	if !node.misbehaved(other, errOversizedMessage(2048, 1024)) {
+		t.Errorf("expected peer banned after oversized message")
+	}
+}
+
+func TestFailedHandshakeBan(t *testing.T) {
+	configuration := config.New()
+	configuration.Write(config.SetMaxHandshakeFailures(2))
+
+	node := New(configuration)
+	addr := &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 8010}
+	node.failedHandshake(addr)
+	if node.bans.BannedIP(addr.IP) {
+		t.Errorf("expected address not banned before threshold")
+	}
+
+	node.failedHandshake(addr)
+	if !node.bans.BannedIP(addr.IP) {
+		t.Errorf("expected address banned after threshold")
+	}
+
+	if err := node.Dial(addr.String()); err == nil {
+		t.Errorf("expected dial to banned address rejected")
+	}
+}
+
+func TestFailedDialNotCounted(t *testing.T) {
+	configuration := config.New()
+	configuration.Write(config.SetMaxHandshakeFailures(1))
+	node := New(configuration)
+	defer node.Close()
+
+	// The dialed peer closes the connection during handshake.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			conn.Close()
+		}
+	}()
+
+	for i := 0; i < 2; i++ {
+		if err := node.Dial(listener.Addr().String()); err == nil {
+			t.Fatalf("expected handshake error")
+		}
+	}
+
+	if node.bans.BannedIP(net.ParseIP("127.0.0.1")) {
+		t.Errorf("expected dialed address not banned after failed handshakes")
+	}
+}
+
+func TestBanConnectedPeer(t *testing.T) {
+	configurationA := config.New()
+	configurationA.Write(config.SetSelfListeningAddress("127.0.0.1:"))
+	// Lookups would dial A concurrently and close the duplicated connection locally.
+	configurationB := config.New()
+	configurationB.Write(config.SetDHTRefreshInterval(0))
+
+	nodeA := New(configurationA)
+	nodeB := New(configurationB)
+	defer nodeA.Close()
+	defer nodeB.Close()
+
+	<-whenReadyForIncomingDial(nodeA)
+	signalsB, cancel := nodeB.Signals()
+	defer cancel()
+
+	if err := nodeB.Dial(nodeA.LocalAddr().String()); err != nil {
+		t.Fatal(err)
+	}
+
+	nodeA.Ban(nodeB.ID().String(), time.Minute)
+	signal, ok := waitFor(signalsB, PeerDisconnected, 2*time.Second)
+	if !ok {
+		t.Fatalf("expected banned peer disconnected")
+	}
+
+	if signal.Reason() != ReasonBanned {
+		t.Errorf("expected reason %s, got %s", ReasonBanned, signal.Reason())
+	}
+
+	bans := nodeA.Bans()
+	if len(bans) != 1 || bans[0].ID != nodeB.ID() || bans[0].Permanent() {
+		t.Errorf("expected temporary ban for peer, got %v", bans)
+	}
+
+	// Banned peer is rejected after handshake.
+	nodeB.Dial(nodeA.LocalAddr().String())
+	signal, ok = waitFor(signalsB, PeerDisconnected, 2*time.Second)
+	if !ok {
+		t.Fatalf("expected banned peer rejected")
+	}
+
+	if signal.Reason() != ReasonBanned {
+		t.Errorf("expected reason %s, got %s", ReasonBanned, signal.Reason())
+	}
+
+	// Banned address is rejected before handshake.
+	nodeA.Unban(nodeB.ID().String())
+	if err := nodeA.BanIP("127.0.0.1", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := nodeB.Dial(nodeA.LocalAddr().String()); err == nil {
+		t.Errorf("expected handshake failure for banned address")
+	}
+
+	if err := nodeA.BanIP("invalid", 0); err == nil {
+		t.Errorf("expected error for invalid IP address")
+	}
+
+	if !nodeA.UnbanIP("127.0.0.1") || len(nodeA.Bans()) != 0 {
+		t.Errorf("expected no bans after unban")
+	}
+}
@@ -0,0 +1,86 @@
+package noise
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// bootstrap dials the bootstrap peers concurrently when the node starts listening.
+// Failed addresses are redialed using exponential backoff up to MaxReconnectAttempts.
+// A Bootstrapped signal is emitted once MinBootstrapPeers peers are connected, see [Node.checkBootstrapped].
+// The routine stops when every address is connected or given up, the context is canceled or the node is shutting down.
+func (n *Node) bootstrap(ctx context.Context, addrs []string) {
+	defer n.wg.Done()
+	min := n.config.MinBootstrapPeers()
+	if min > len(addrs) {
+		min = len(addrs)
+	}
+
+	if min < 1 {
+		min = 1
+	}
+
+	n.bootstrapMin.Store(int32(min))
+	// The minimum could be already reached before bootstrapping.
+	for p := range n.router.Table() {
+		n.checkBootstrapped(p)
+		break
+	}
+
+	var wg sync.WaitGroup
+	for _, addr := range addrs {
+		wg.Add(1)
+		go func(addr string) {
+			defer wg.Done()
+			n.bootstrapPeer(ctx, addr)
+		}(addr)
+	}
+
+	wg.Wait()
+}
+
+// checkBootstrapped emits a Bootstrapped signal the first time the connected peers reach the bootstrap minimum.
+// It's called on every new connection, either dialed or incoming, until the minimum is met.
+func (n *Node) checkBootstrapped(peer *peer) {
+	min := n.bootstrapMin.Load()
+	if min == 0 || n.bootstrapped.Load() || int32(n.router.Len()) < min {
+		return
+	}
+
+	if n.bootstrapped.CompareAndSwap(false, true) {
+		log.Printf("node bootstrapped with %d peers", n.router.Len())
+		n.events.Bootstrapped(peer)
+	}
+}
+
+// bootstrapPeer dials the bootstrap address until connected, the node gives up or stops bootstrapping.
+func (n *Node) bootstrapPeer(ctx context.Context, addr string) {
+	max := n.config.MaxReconnectAttempts()
+	for attempt := 1; ; attempt++ {
+		_, err := n.dial(ctx, addr)
+		if err == nil {
+			log.Printf("bootstrap peer connected: %s", addr)
+			return
+		}
+
+		log.Printf("error dialing bootstrap peer %s, attempt %d: %v", addr, attempt, err)
+		if max > 0 && attempt >= max {
+			log.Printf("giving up bootstrap peer %s", addr)
+			return
+		}
+
+		delay := backoff(attempt-1, n.config.ReconnectBackoff(), n.config.MaxReconnectBackoff())
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-n.done:
+			timer.Stop()
+			return
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package noise
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/geolffreym/p2p-noise/config"
+)
+
+func TestBootstrap(t *testing.T) {
+	configurationA := config.New()
+	configurationA.Write(config.SetSelfListeningAddress("127.0.0.1:"))
+	nodeA := New(configurationA)
+	defer nodeA.Close()
+	<-whenReadyForIncomingDial(nodeA)
+
+	// Reserve an address for a bootstrap peer started later.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	late := listener.Addr().String()
+	listener.Close()
+
+	configurationB := config.New()
+	configurationB.Write(
+		config.SetSelfListeningAddress("127.0.0.1:"),
+		config.SetBootstrapPeers(nodeA.LocalAddr().String(), late),
+		config.SetMinBootstrapPeers(2),
+		config.SetReconnectBackoff(20*time.Millisecond),
+		config.SetMaxReconnectBackoff(50*time.Millisecond),
+	)
+
+	nodeB := New(configurationB)
+	defer nodeB.Close()
+	signalsB, cancel := nodeB.Signals()
+	defer cancel()
+	go nodeB.Listen()
+
+	// Only one bootstrap peer is reachable.
+	if _, ok := waitFor(signalsB, NewPeerDetected, 2*time.Second); !ok {
+		t.Fatalf("expected bootstrap peer connected")
+	}
+
+	select {
+	case signal := <-signalsB:
+		if signal.Type() == Bootstrapped {
+			t.Fatalf("expected node not bootstrapped with 1 peer")
+		}
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// The failed bootstrap peer is retried until it is reachable.
+	configurationC := config.New()
+	configurationC.Write(config.SetSelfListeningAddress(late))
+	nodeC := New(configurationC)
+	defer nodeC.Close()
+	go nodeC.Listen()
+
+	bootstrapped, ok := waitFor(signalsB, Bootstrapped, 2*time.Second)
+	if !ok {
+		t.Fatalf("expected node bootstrapped")
+	}
+
+	if bootstrapped.Payload() != nodeC.ID().String() {
+		t.Errorf("expected bootstrapped by late peer, got %s", bootstrapped.Payload())
+	}
+}
+
+func TestBootstrapIncoming(t *testing.T) {
+	// Reserve an address for a bootstrap peer never started.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unreachable := listener.Addr().String()
+	listener.Close()
+
+	configurationA := config.New()
+	configurationA.Write(
+		config.SetSelfListeningAddress("127.0.0.1:"),
+		config.SetBootstrapPeers(unreachable),
+		config.SetReconnectBackoff(time.Second),
+	)
+
+	nodeA := New(configurationA)
+	defer nodeA.Close()
+	signalsA, cancel := nodeA.Signals()
+	defer cancel()
+	go nodeA.Listen()
+
+	if _, ok := waitFor(signalsA, SelfListening, 2*time.Second); !ok {
+		t.Fatalf("expected node listening")
+	}
+
+	// The minimum is reached through an incoming connection.
+	nodeB := New(config.New())
+	defer nodeB.Close()
+	if err := nodeB.Dial(nodeA.LocalAddr().String()); err != nil {
+		t.Fatal(err)
+	}
+
+	bootstrapped, ok := waitFor(signalsA, Bootstrapped, 2*time.Second)
+	if !ok {
+		t.Fatalf("expected node bootstrapped by incoming peer")
+	}
+
+	if bootstrapped.Payload() != nodeB.ID().String() {
+		t.Errorf("expected bootstrapped by incoming peer, got %s", bootstrapped.Payload())
+	}
+}
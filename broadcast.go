@@ -0,0 +1,216 @@
+package noise
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// maxBroadcastTTL is the max number of hops a broadcast message can travel.
+const maxBroadcastTTL = 16
+
+// broadcastDomain separates the broadcast signatures from other messages signed with the node identity.
+const broadcastDomain = "p2p-noise/broadcast"
+
+// broadcastMessage is a message flooded to the whole network signed by the originator identity key.
+// The TTL is not covered by signature since every relay decrements it, it's bounded by maxBroadcastTTL.
+type broadcastMessage struct {
+	Data      []byte
+	Seq       uint64
+	TTL       uint8
+	Publisher PublicKey
+	Signature []byte
+}
+
+// ID returns the broadcast ID used to suppress duplicates.
+func (b broadcastMessage) ID() ID {
+	return newBlake2ID(append(b.signedBytes(), b.Publisher...))
+}
+
+// From returns the originator ID.
+func (b broadcastMessage) From() ID {
+	return newBlake2ID(b.Publisher)
+}
+
+// signedBytes returns the broadcast fields covered by signature.
+func (b broadcastMessage) signedBytes() []byte {
+	var buffer bytes.Buffer
+	buffer.WriteString(broadcastDomain)
+	binary.Write(&buffer, binary.BigEndian, uint32(len(b.Data)))
+	buffer.Write(b.Data)
+	binary.Write(&buffer, binary.BigEndian, b.Seq)
+	return buffer.Bytes()
+}
+
+// encodeBroadcast encode a broadcast message to bytes.
+func encodeBroadcast(b broadcastMessage) []byte {
+	var buffer bytes.Buffer
+	gob.NewEncoder(&buffer).Encode(b)
+	return buffer.Bytes()
+}
+
+// decodeBroadcast decode incoming bytes to a broadcast message.
+func decodeBroadcast(b []byte) (broadcastMessage, error) {
+	var m broadcastMessage
+	err := gob.NewDecoder(bytes.NewReader(b)).Decode(&m)
+	return m, err
+}
+
+// validateBroadcast checks the hop limit and the originator signature.
+func validateBroadcast(b broadcastMessage) error {
+	if b.TTL == 0 || b.TTL > maxBroadcastTTL {
+		return errInvalidBroadcast(errors.New("invalid ttl"))
+	}
+
+	if len(b.Publisher) != ed25519.PublicKeySize {
+		return errInvalidBroadcast(errors.New("invalid publisher key"))
+	}
+
+	if !ed25519.Verify(b.Publisher, b.signedBytes(), b.Signature) {
+		return errInvalidBroadcast(errors.New("invalid signature"))
+	}
+
+	return nil
+}
+
+// seenCache remembers the message IDs delivered during the last seenTTL.
+type seenCache struct {
+	mu     sync.Mutex
+	seen   map[ID]time.Time
+	purged time.Time
+}
+
+func newSeenCache() *seenCache {
+	return &seenCache{seen: make(map[ID]time.Time), purged: time.Now()}
+}
+
+// Add marks the ID as seen.
+// It returns false if the ID was already seen.
+func (c *seenCache) Add(id ID) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// Expired IDs are purged lazily at most once every TTL.
+	if time.Since(c.purged) > seenTTL {
+		for seen, at := range c.seen {
+			if time.Since(at) > seenTTL {
+				delete(c.seen, seen)
+			}
+		}
+
+		c.purged = time.Now()
+	}
+
+	if _, ok := c.seen[id]; ok {
+		return false
+	}
+
+	c.seen[id] = time.Now()
+	return true
+}
+
+// Remove forgets the ID.
+// It returns false if the ID wasn't seen during the last seenTTL.
+func (c *seenCache) Remove(id ID) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	at, ok := c.seen[id]
+	delete(c.seen, id)
+	return ok && time.Since(at) <= seenTTL
+}
+
+// flood holds the broadcast state for node.
+type flood struct {
+	seq  atomic.Uint64 // sequence for originated broadcasts
+	seen *seenCache
+}
+
+func newFlood() *flood {
+	f := &flood{seen: newSeenCache()}
+	// The sequence starts from time to avoid reusing a sequence after restarts.
+	f.seq.Store(uint64(time.Now().UnixNano()))
+	return f
+}
+
+// relay sends the broadcast to every connected peer except the excluded ones.
+// It returns the number of peers the broadcast was sent to.
+func (n *Node) relay(b broadcastMessage, exclude ...ID) int {
+	msg := encodeBroadcast(b)
+	var sent int
+next:
+	for peer := range n.router.Table() {
+		for _, id := range exclude {
+			if peer.ID() == id {
+				continue next
+			}
+		}
+
+		if _, err := peer.send(broadcastFrame, msg); err != nil {
+			log.Printf("error relaying broadcast: %v", err)
+			continue
+		}
+
+		sent++
+	}
+
+	return sent
+}
+
+// Broadcast floods the message to every peer in the network up to ttl hops away.
+// Each connected peer delivers the message with a BroadcastReceived signal and relays it to its own peers,
+// every peer delivers and relays the message only once. The message is signed with the node identity
+// so relays can't tamper with it.
+// It returns the number of connected peers the message was sent to or an error if ttl is not between 1 and 16.
+func (n *Node) Broadcast(msg []byte, ttl uint8) (int, error) {
+	if ttl == 0 || ttl > maxBroadcastTTL {
+		return 0, errInvalidTTL(ttl)
+	}
+
+	kr, err := n.keyRing()
+	if err != nil {
+		return 0, err
+	}
+
+	b := broadcastMessage{
+		Data:      msg,
+		Seq:       n.flood.seq.Add(1),
+		TTL:       ttl,
+		Publisher: kr.sv.Public,
+	}
+
+	b.Signature = ed25519.Sign(kr.sv.Private, b.signedBytes())
+	// The broadcast coming back from peers is ignored.
+	n.flood.seen.Add(b.ID())
+	return n.relay(b), nil
+}
+
+// handleBroadcast process an incoming broadcast from peer.
+// New valid broadcasts are delivered and relayed with a decremented ttl until the hop limit is reached.
+// The rate limits are applied by watch, so a throttled broadcast is never marked as seen.
+func (n *Node) handleBroadcast(peer *peer, msg []byte) {
+	b, err := decodeBroadcast(msg)
+	if err != nil {
+		log.Printf("error decoding broadcast: %v", err)
+		return
+	}
+
+	if err := validateBroadcast(b); err != nil {
+		log.Printf("broadcast rejected: %v", err)
+		return
+	}
+
+	origin := b.From()
+	if origin == n.ID() || !n.flood.seen.Add(b.ID()) {
+		return
+	}
+
+	n.events.Broadcast(peer, origin, b.Data)
+	if b.TTL--; b.TTL > 0 {
+		n.relay(b, peer.ID(), origin)
+	}
+}
@@ -0,0 +1,118 @@
+package noise
+
+import (
+	"crypto/ed25519"
+	"testing"
+	"time"
+
+	"github.com/geolffreym/p2p-noise/config"
+)
+
+func TestValidateBroadcast(t *testing.T) {
+	public, private, _ := ed25519.GenerateKey(nil)
+	signed := func(ttl uint8) broadcastMessage {
+		b := broadcastMessage{Data: []byte("hello"), Seq: 1, TTL: ttl, Publisher: public}
+		b.Signature = ed25519.Sign(private, b.signedBytes())
+		return b
+	}
+
+	valid := signed(3)
+	tampered := valid
+	tampered.Data = []byte("evil")
+	relayed := valid
+	relayed.TTL--
+
+	cases := []struct {
+		name      string
+		broadcast broadcastMessage
+		valid     bool
+	}{
+		{"valid", valid, true},
+		{"relayed", relayed, true},
+		{"tampered", tampered, false},
+		{"expired ttl", signed(0), false},
+		{"ttl too long", signed(maxBroadcastTTL + 1), false},
+		{"invalid publisher", broadcastMessage{TTL: 1}, false},
+	}
+
+	for _, e := range cases {
+		t.Run(e.name, func(t *testing.T) {
+			if err := validateBroadcast(e.broadcast); (err == nil) != e.valid {
+				t.Errorf("expected valid = %v, got %v", e.valid, err)
+			}
+		})
+	}
+
+	if valid.ID() != relayed.ID() {
+		t.Errorf("expected same ID for relayed broadcast")
+	}
+}
+
+func TestSeenCache(t *testing.T) {
+	cache := newSeenCache()
+	if !cache.Add(ID{1}) || cache.Add(ID{1}) {
+		t.Errorf("expected ID seen only once")
+	}
+
+	cache.seen[ID{2}] = time.Now().Add(-2 * seenTTL)
+	cache.purged = time.Now().Add(-2 * seenTTL)
+	if !cache.Add(ID{3}) || len(cache.seen) != 2 {
+		t.Errorf("expected expired IDs purged, got %d IDs", len(cache.seen))
+	}
+}
+
+func TestBroadcast(t *testing.T) {
+	nodes := make([]*Node, 4)
+	signals := make([]<-chan Signal, len(nodes))
+	for i := range nodes {
+		configuration := config.New()
+		configuration.Write(
+			config.SetSelfListeningAddress("127.0.0.1:"),
+			config.SetDHTRefreshInterval(0),
+		)
+
+		nodes[i] = New(configuration)
+		defer nodes[i].Close()
+		var cancel func()
+		signals[i], cancel = nodes[i].Signals()
+		defer cancel()
+		go nodes[i].Listen()
+	}
+
+	for i := range nodes {
+		if _, ok := waitFor(signals[i], SelfListening, 2*time.Second); !ok {
+			t.Fatalf("expected node listening")
+		}
+	}
+
+	// A line topology: A -> B -> C -> D
+	for i := 0; i < len(nodes)-1; i++ {
+		if err := nodes[i].Dial(nodes[i+1].LocalAddr().String()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := nodes[0].Broadcast([]byte("hello"), 0); err == nil {
+		t.Errorf("expected error for invalid ttl")
+	}
+
+	sent, err := nodes[0].Broadcast([]byte("hello"), 2)
+	if err != nil || sent != 1 {
+		t.Fatalf("expected broadcast sent to 1 peer, got %d: %v", sent, err)
+	}
+
+	for _, i := range []int{1, 2} {
+		signal, ok := waitFor(signals[i], BroadcastReceived, 2*time.Second)
+		if !ok {
+			t.Fatalf("expected broadcast received %d hops away", i)
+		}
+
+		if signal.Payload() != "hello" || signal.Origin() != nodes[0].ID().String() {
+			t.Errorf("expected broadcast from A, got %q", signal.Payload())
+		}
+	}
+
+	if _, ok := waitFor(signals[3], BroadcastReceived, 200*time.Millisecond); ok {
+		t.Errorf("expected broadcast stopped after 2 hops")
+	}
+}
@@ -2,11 +2,11 @@ package noise
 
 type topic struct {
 	s    []*subscriber
-	sMap map[*subscriber]uint8
-	size uint8
+	sMap map[*subscriber]uint32
+	size uint32
 }
 
-func (s *topic) Len() uint8                 { return s.size }
+func (s *topic) Len() uint32                { return s.size }
 func (s *topic) Subscribers() []*subscriber { return s.s }
 
 // topics `keep` registered events
@@ -29,11 +29,11 @@ func (t topics) Add(e Event, s *subscriber) {
 		t[e] = new(topic)
 		t[e].size = 0
 		t[e].s = []*subscriber{}
-		t[e].sMap = make(map[*subscriber]uint8)
+		t[e].sMap = make(map[*subscriber]uint32)
 	}
 
 	t[e].s = append(t[e].s, s)
-	t[e].sMap[s] = uint8(len(t[e].s) - 1)
+	t[e].sMap[s] = uint32(len(t[e].s) - 1)
 	t[e].size++
 }
 
@@ -84,7 +84,7 @@ func (b *broker) Unregister(e Event, s *subscriber) bool {
 
 // Publish Emit/send concurrently messages to topic subscribers
 // It return number of subscribers notified.
-func (b *broker) Publish(msg Signal) uint8 {
+func (b *broker) Publish(msg Signal) uint32 {
 	// Check if topic is registered before try to emit messages to subscribers.
 	topic := b.topics.Get(msg.Type())
 	if topic == nil {
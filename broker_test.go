@@ -175,7 +175,7 @@ func TestPublish(t *testing.T) {
 	broker := newBroker(4)
 
 	session := mockSession(&mockConn{}, PeerAPb)
-	header1 := header{newPeer(session), NewPeerDetected}
+	header1 := header{newPeer(session), NewPeerDetected, ReasonUnknown, ID{}}
 	signaling := Signal{header1, ""}
 
 	broker.Register(NewPeerDetected, subscriber)
@@ -197,7 +197,7 @@ func TestPublish(t *testing.T) {
 
 	// New message for new topic event
 	broker.Register(NewPeerDetected, subscriber)
-	header2 := header{newPeer(session), NewPeerDetected}
+	header2 := header{newPeer(session), NewPeerDetected, ReasonUnknown, ID{}}
 	signaling = Signal{header2, ""}
 
 	// Number of subscribers notified
@@ -214,7 +214,7 @@ func TestPublish(t *testing.T) {
 func TestInvalidPublish(t *testing.T) {
 	broker := newBroker(4)
 	session := mockSession(&mockConn{}, PeerAPb)
-	header1 := header{newPeer(session), NewPeerDetected}
+	header1 := header{newPeer(session), NewPeerDetected, ReasonUnknown, ID{}}
 	signaling := Signal{header1, ""}
 
 	// Number of subscribers notified
@@ -0,0 +1,251 @@
+// Package cert implements a lightweight certificate authority for closed networks.
+// An authority key signs certificates binding a peer identity key to metadata eg. name, roles and expiration.
+// Peers present their certificate during handshake and the remote node verifies it against the trusted authorities.
+package cert
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrMissingCertificate   = errors.New("missing certificate")
+	ErrMalformedCertificate = errors.New("malformed certificate")
+	ErrExpiredCertificate   = errors.New("expired or not yet valid certificate")
+	ErrRevokedCertificate   = errors.New("revoked certificate")
+	ErrUntrustedAuthority   = errors.New("certificate not issued by a trusted authority")
+	ErrInvalidSignature     = errors.New("invalid certificate signature")
+	ErrSubjectMismatch      = errors.New("certificate issued for another identity")
+)
+
+// [Certificate] binds a peer identity key to metadata signed by an authority.
+type Certificate struct {
+	// Unique random serial used for revocation.
+	Serial string `json:"serial"`
+	// Peer ED25519 identity public key.
+	Subject   ed25519.PublicKey `json:"subject"`
+	Name      string            `json:"name"`
+	Roles     []string          `json:"roles,omitempty"`
+	NotBefore time.Time         `json:"not_before"`
+	NotAfter  time.Time         `json:"not_after"`
+	// Authority ED25519 public key.
+	Issuer    ed25519.PublicKey `json:"issuer"`
+	Signature []byte            `json:"signature,omitempty"`
+}
+
+// newSerial returns a random 128 bits hex encoded serial.
+func newSerial() (string, error) {
+	serial := make([]byte, 16)
+	if _, err := rand.Read(serial); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(serial), nil
+}
+
+// Issue creates a new certificate for subject signed by authority.
+// The certificate is valid from now until validity duration.
+func Issue(authority ed25519.PrivateKey, subject ed25519.PublicKey, name string, roles []string, validity time.Duration) (*Certificate, error) {
+	if len(authority) != ed25519.PrivateKeySize || len(subject) != ed25519.PublicKeySize {
+		return nil, errors.New("invalid authority or subject key size")
+	}
+
+	serial, err := newSerial()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	c := &Certificate{
+		Serial:    serial,
+		Subject:   subject,
+		Name:      name,
+		Roles:     roles,
+		NotBefore: now,
+		NotAfter:  now.Add(validity),
+		Issuer:    authority.Public().(ed25519.PublicKey),
+	}
+
+	signed, err := c.signedBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	c.Signature = ed25519.Sign(authority, signed)
+	return c, nil
+}
+
+// signedBytes returns the certificate encoding without signature.
+func (c *Certificate) signedBytes() ([]byte, error) {
+	unsigned := *c
+	unsigned.Signature = nil
+	return json.Marshal(unsigned)
+}
+
+// HasRole returns true if the certificate grants the role.
+func (c *Certificate) HasRole(role string) bool {
+	for _, r := range c.Roles {
+		if r == role {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Marshal encodes the certificate to be exchanged during handshake or stored.
+func (c *Certificate) Marshal() ([]byte, error) {
+	return json.Marshal(c)
+}
+
+// Unmarshal decodes an encoded certificate.
+func Unmarshal(b []byte) (*Certificate, error) {
+	var c Certificate
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, ErrMalformedCertificate
+	}
+
+	if len(c.Subject) != ed25519.PublicKeySize || len(c.Issuer) != ed25519.PublicKeySize {
+		return nil, ErrMalformedCertificate
+	}
+
+	return &c, nil
+}
+
+// [Verifier] validates certificates against the trusted authorities and the revoked serials.
+// Authorities and revocations can be changed at runtime.
+type Verifier struct {
+	mu          sync.RWMutex
+	authorities map[string]ed25519.PublicKey
+	revoked     map[string]struct{}
+}
+
+// NewVerifier creates a new verifier trusting the authorities keys.
+func NewVerifier(authorities ...ed25519.PublicKey) *Verifier {
+	v := &Verifier{
+		authorities: make(map[string]ed25519.PublicKey),
+		revoked:     make(map[string]struct{}),
+	}
+
+	for _, authority := range authorities {
+		v.Trust(authority)
+	}
+
+	return v
+}
+
+// Trust adds the authority key to trusted authorities.
+func (v *Verifier) Trust(authority ed25519.PublicKey) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.authorities[string(authority)] = authority
+}
+
+// Revoke marks the certificate serial as revoked.
+func (v *Verifier) Revoke(serial string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.revoked[strings.ToLower(serial)] = struct{}{}
+}
+
+// Verify decodes the certificate and validates it for the subject identity key.
+// It implements the certificate verifier interface expected by node.
+func (v *Verifier) Verify(raw []byte, subject ed25519.PublicKey) error {
+	if len(raw) == 0 {
+		return ErrMissingCertificate
+	}
+
+	c, err := Unmarshal(raw)
+	if err != nil {
+		return err
+	}
+
+	return v.VerifyCertificate(c, subject, time.Now())
+}
+
+// VerifyCertificate validates the certificate for the subject identity key at time now.
+func (v *Verifier) VerifyCertificate(c *Certificate, subject ed25519.PublicKey, now time.Time) error {
+	if !c.Subject.Equal(subject) {
+		return ErrSubjectMismatch
+	}
+
+	if now.Before(c.NotBefore) || now.After(c.NotAfter) {
+		return ErrExpiredCertificate
+	}
+
+	v.mu.RLock()
+	_, trusted := v.authorities[string(c.Issuer)]
+	_, revoked := v.revoked[strings.ToLower(c.Serial)]
+	v.mu.RUnlock()
+
+	if !trusted {
+		return ErrUntrustedAuthority
+	}
+
+	if revoked {
+		return ErrRevokedCertificate
+	}
+
+	signed, err := c.signedBytes()
+	if err != nil {
+		return ErrMalformedCertificate
+	}
+
+	if !ed25519.Verify(c.Issuer, signed, c.Signature) {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
+
+// GenerateKey creates a new ED25519 key pair for an authority or a peer identity.
+func GenerateKey() (ed25519.PublicKey, ed25519.PrivateKey, error) {
+	return ed25519.GenerateKey(rand.Reader)
+}
+
+// SaveKey writes the hex encoded private key to file readable only by owner.
+func SaveKey(path string, key ed25519.PrivateKey) error {
+	return os.WriteFile(path, []byte(hex.EncodeToString(key)+"\n"), 0o600)
+}
+
+// LoadKey reads a hex encoded private key from file.
+func LoadKey(path string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(key) != ed25519.PrivateKeySize {
+		return nil, errors.New("invalid private key file")
+	}
+
+	return ed25519.PrivateKey(key), nil
+}
+
+// Save writes the encoded certificate to file.
+func Save(path string, c *Certificate) error {
+	b, err := c.Marshal()
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, b, 0o644)
+}
+
+// Load reads an encoded certificate from file.
+func Load(path string) (*Certificate, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return Unmarshal(b)
+}
@@ -0,0 +1,87 @@
+package cert
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestIssueAndVerify(t *testing.T) {
+	authorityPub, authority, _ := GenerateKey()
+	subject, _, _ := GenerateKey()
+	other, _, _ := GenerateKey()
+	_, untrusted, _ := GenerateKey()
+
+	c, err := Issue(authority, subject, "node-1", []string{"relay"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !c.HasRole("relay") || c.HasRole("storage") {
+		t.Errorf("expected only relay role granted")
+	}
+
+	raw, _ := c.Marshal()
+	rogue, _ := Issue(untrusted, subject, "node-1", nil, time.Hour)
+	rogueRaw, _ := rogue.Marshal()
+
+	tampered := *c
+	tampered.Roles = []string{"admin"}
+	tamperedRaw, _ := tampered.Marshal()
+
+	verifier := NewVerifier(authorityPub)
+	cases := []struct {
+		name     string
+		raw      []byte
+		subject  []byte
+		expected error
+	}{
+		{"valid", raw, subject, nil},
+		{"missing", nil, subject, ErrMissingCertificate},
+		{"malformed", []byte("{"), subject, ErrMalformedCertificate},
+		{"subject mismatch", raw, other, ErrSubjectMismatch},
+		{"untrusted", rogueRaw, subject, ErrUntrustedAuthority},
+		{"tampered", tamperedRaw, subject, ErrInvalidSignature},
+	}
+
+	for _, e := range cases {
+		t.Run(e.name, func(t *testing.T) {
+			if err := verifier.Verify(e.raw, e.subject); !errors.Is(err, e.expected) {
+				t.Errorf("expected %v, got %v", e.expected, err)
+			}
+		})
+	}
+
+	if err := verifier.VerifyCertificate(c, subject, c.NotAfter.Add(time.Second)); !errors.Is(err, ErrExpiredCertificate) {
+		t.Errorf("expected expired certificate, got %v", err)
+	}
+
+	verifier.Revoke(c.Serial)
+	if err := verifier.Verify(raw, subject); !errors.Is(err, ErrRevokedCertificate) {
+		t.Errorf("expected revoked certificate, got %v", err)
+	}
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	dir := t.TempDir()
+	subject, key, _ := GenerateKey()
+	if err := SaveKey(filepath.Join(dir, "node.key"), key); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadKey(filepath.Join(dir, "node.key"))
+	if err != nil || !loaded.Equal(key) {
+		t.Fatalf("expected key restored from file, got %v", err)
+	}
+
+	c, _ := Issue(key, subject, "self", nil, time.Hour)
+	if err := Save(filepath.Join(dir, "node.cert"), c); err != nil {
+		t.Fatal(err)
+	}
+
+	restored, err := Load(filepath.Join(dir, "node.cert"))
+	if err != nil || restored.Serial != c.Serial || !restored.NotAfter.Equal(c.NotAfter) {
+		t.Errorf("expected certificate restored from file, got %v", err)
+	}
+}
@@ -0,0 +1,23 @@
+package noise
+
+// [CertificateVerifier] validates the certificate presented by remote peers during handshake.
+// Please see cert.Verifier for an authority based implementation.
+type CertificateVerifier interface {
+	// Verify returns an error if the certificate is missing, invalid or not issued for the key.
+	Verify(cert []byte, key PublicKey) error
+}
+
+// SetCertificateVerifier sets the verifier used to validate remote certificates after handshake.
+// Peers without a valid certificate are rejected. A nil verifier allows any peer.
+func (n *Node) SetCertificateVerifier(verifier CertificateVerifier) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.verifier = verifier
+}
+
+// certificateVerifier returns the current certificate verifier.
+func (n *Node) certificateVerifier() CertificateVerifier {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.verifier
+}
@@ -0,0 +1,100 @@
+package noise
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/geolffreym/p2p-noise/cert"
+	"github.com/geolffreym/p2p-noise/config"
+)
+
+// certifiedNode creates a listening node with identity key and optional certificate.
+func certifiedNode(t *testing.T, authority PrivateKey, validity time.Duration) (*Node, *cert.Certificate) {
+	public, identity, _ := cert.GenerateKey()
+	configuration := config.New()
+	configuration.Write(
+		config.SetSelfListeningAddress("127.0.0.1:"),
+		config.SetIdentityKey(identity),
+	)
+
+	var c *cert.Certificate
+	if authority != nil {
+		c, _ = cert.Issue(authority, public, "node", nil, validity)
+		raw, _ := c.Marshal()
+		configuration.Write(config.SetCertificate(raw))
+	}
+
+	node := New(configuration)
+	t.Cleanup(func() { node.Close() })
+	<-whenReadyForIncomingDial(node)
+
+	if node.ID() != newBlake2ID(public) {
+		t.Fatalf("expected node ID derived from identity key")
+	}
+
+	return node, c
+}
+
+func TestCertificateVerification(t *testing.T) {
+	authorityPub, authority, _ := cert.GenerateKey()
+	_, untrusted, _ := cert.GenerateKey()
+
+	verifier := cert.NewVerifier(authorityPub)
+	nodeB := New(config.New())
+	defer nodeB.Close()
+	nodeB.SetCertificateVerifier(verifier)
+
+	valid, _ := certifiedNode(t, authority, time.Hour)
+	missing, _ := certifiedNode(t, nil, 0)
+	rogue, _ := certifiedNode(t, untrusted, time.Hour)
+	expired, _ := certifiedNode(t, authority, -time.Hour)
+	revoked, c := certifiedNode(t, authority, time.Hour)
+	verifier.Revoke(c.Serial)
+
+	cases := []struct {
+		name     string
+		node     *Node
+		expected error
+	}{
+		{"valid", valid, nil},
+		{"missing", missing, cert.ErrMissingCertificate},
+		{"untrusted", rogue, cert.ErrUntrustedAuthority},
+		{"expired", expired, cert.ErrExpiredCertificate},
+		{"revoked", revoked, cert.ErrRevokedCertificate},
+	}
+
+	for _, e := range cases {
+		t.Run(e.name, func(t *testing.T) {
+			err := nodeB.Dial(e.node.LocalAddr().String())
+			if e.expected == nil && err != nil {
+				t.Fatalf("expected certified peer allowed, got %v", err)
+			}
+
+			var secErr *SecError
+			if e.expected != nil && (!errors.As(err, &secErr) || !errors.Is(err, e.expected)) {
+				t.Errorf("expected %v, got %v", e.expected, err)
+			}
+		})
+	}
+}
+
+func TestCertificateWithoutIdentity(t *testing.T) {
+	configuration := config.New()
+	configuration.Write(
+		config.SetSelfListeningAddress("127.0.0.1:"),
+		config.SetCertificate([]byte("certificate")),
+	)
+
+	node := New(configuration)
+	defer node.Close()
+
+	expected := errCertificateWithoutIdentity().Error()
+	if err := node.Listen(); err == nil || err.Error() != expected {
+		t.Errorf("expected listen error %q, got %v", expected, err)
+	}
+
+	if err := node.Dial("127.0.0.1:9"); err == nil || err.Error() != expected {
+		t.Errorf("expected dial error %q, got %v", expected, err)
+	}
+}
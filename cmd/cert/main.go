@@ -0,0 +1,146 @@
+// Command cert manages the keys and certificates of a closed network.
+//
+//	cert keygen -out authority.key
+//	cert keygen -out node.key
+//	cert issue -ca authority.key -key node.key -name node-1 -roles relay,storage -out node.cert
+//	cert show node.cert
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/geolffreym/p2p-noise/cert"
+)
+
+const usage = `usage: cert <command> [flags]
+
+commands:
+  keygen  generate a new authority or node identity key
+  issue   issue a certificate for a node identity signed by an authority
+  show    print a certificate`
+
+func main() {
+	log.SetFlags(0)
+	if len(os.Args) < 2 {
+		log.Fatal(usage)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "keygen":
+		err = keygen(os.Args[2:])
+	case "issue":
+		err = issue(os.Args[2:])
+	case "show":
+		err = show(os.Args[2:])
+	default:
+		log.Fatal(usage)
+	}
+
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// keygen writes a new private key to file and prints the public key.
+func keygen(args []string) error {
+	flags := flag.NewFlagSet("keygen", flag.ExitOnError)
+	out := flags.String("out", "", "private key output file")
+	flags.Parse(args)
+
+	if *out == "" {
+		return fmt.Errorf("missing -out flag")
+	}
+
+	public, private, err := cert.GenerateKey()
+	if err != nil {
+		return err
+	}
+
+	if err := cert.SaveKey(*out, private); err != nil {
+		return err
+	}
+
+	fmt.Println(hex.EncodeToString(public))
+	return nil
+}
+
+// issue signs a new certificate for the subject key with the authority key.
+func issue(args []string) error {
+	flags := flag.NewFlagSet("issue", flag.ExitOnError)
+	ca := flags.String("ca", "", "authority private key file")
+	key := flags.String("key", "", "node private key file")
+	subject := flags.String("subject", "", "hex encoded node public key, instead of -key")
+	name := flags.String("name", "", "node name")
+	roles := flags.String("roles", "", "comma separated node roles")
+	validity := flags.Duration("validity", 365*24*time.Hour, "certificate validity")
+	out := flags.String("out", "", "certificate output file")
+	flags.Parse(args)
+
+	if *ca == "" || *out == "" {
+		return fmt.Errorf("missing -ca or -out flag")
+	}
+
+	authority, err := cert.LoadKey(*ca)
+	if err != nil {
+		return err
+	}
+
+	var public ed25519.PublicKey
+	switch {
+	case *key != "":
+		private, err := cert.LoadKey(*key)
+		if err != nil {
+			return err
+		}
+
+		public = private.Public().(ed25519.PublicKey)
+	case *subject != "":
+		public, err = hex.DecodeString(*subject)
+		if err != nil {
+			return fmt.Errorf("invalid subject: %w", err)
+		}
+	default:
+		return fmt.Errorf("missing -key or -subject flag")
+	}
+
+	var granted []string
+	if *roles != "" {
+		granted = strings.Split(*roles, ",")
+	}
+
+	c, err := cert.Issue(authority, public, *name, granted, *validity)
+	if err != nil {
+		return err
+	}
+
+	return cert.Save(*out, c)
+}
+
+// show prints the certificate fields.
+func show(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: cert show <file>")
+	}
+
+	c, err := cert.Load(args[0])
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("serial:     %s\n", c.Serial)
+	fmt.Printf("subject:    %s\n", hex.EncodeToString(c.Subject))
+	fmt.Printf("name:       %s\n", c.Name)
+	fmt.Printf("roles:      %s\n", strings.Join(c.Roles, ","))
+	fmt.Printf("not before: %s\n", c.NotBefore.Format(time.RFC3339))
+	fmt.Printf("not after:  %s\n", c.NotAfter.Format(time.RFC3339))
+	fmt.Printf("issuer:     %s\n", hex.EncodeToString(c.Issuer))
+	return nil
+}
@@ -6,9 +6,36 @@ package config
 
 import "time"
 
+// Policies applied to incoming messages when a rate limit is exceeded.
+const (
+	// PolicyDelay stops reading from peer until the rate allows a new message.
+	// The remote peer is pushed back by the transport flow control.
+	PolicyDelay uint8 = iota
+	// PolicyDrop discards the messages exceeding the rate.
+	PolicyDrop
+	// PolicyDisconnect closes the connection with the peer exceeding the rate.
+	PolicyDisconnect
+)
+
+// Trust modes for the identity of dialed peers.
+const (
+	// TrustOff accepts any identity for dialed addresses.
+	TrustOff uint8 = iota
+	// TrustOnFirstUse pins the identity the first time an address is dialed,
+	// later dials fail if the identity changed.
+	TrustOnFirstUse
+	// TrustStrict only allows dialing addresses with an already pinned identity.
+	TrustStrict
+)
+
+// DiscoveryGroup is a site-local multicast group suggested to discover peers in local network.
+const DiscoveryGroup = "239.255.42.99:4242"
+
 // Functional options
 type Config struct {
-	maxPeersConnected    uint8
+	maxPeersConnected    uint32
+	lowWatermark         uint32
+	highWatermark        uint32
 	lingerTime           int
 	poolBufferSize       int
 	protocol             string
@@ -16,6 +43,47 @@ type Config struct {
 	keepAlivePeriod      time.Duration
 	dialTimeout          time.Duration
 	idleTimeout          time.Duration
+	handshakeTimeout     time.Duration
+	reconnectBackoff     time.Duration
+	maxReconnectBackoff  time.Duration
+	maxReconnectAttempts int
+	gracePeriod          time.Duration
+	banDuration          time.Duration
+	banListPath          string
+	maxBadSignatures     int
+	maxOversizedFrames   int
+	maxHandshakeFailures int
+	peerMessageRate      int
+	peerByteRate         int
+	globalMessageRate    int
+	globalByteRate       int
+	rateLimitPolicy      uint8
+	maxPendingHandshakes uint32
+	maxHandshakesPerIP   uint32
+	trustMode            uint8
+	knownPeersPath       string
+	identityKey          []byte
+	certificate          []byte
+	dhtBucketSize        int
+	dhtConcurrency       int
+	dhtRefreshInterval   time.Duration
+	recordTTL            time.Duration
+	recordRepublish      time.Duration
+	maxRecords           int
+	maxPublisherRecords  int
+	targetDegree         int
+	maxAddrsPerSource    int
+	bootstrapPeers       []string
+	minBootstrapPeers    int
+	discoveryAddress     string
+	discoveryInterface   string
+	discoveryInterval    time.Duration
+	peerStorePath        string
+	redialKnownPeers     int
+	pubsubDegree         int
+	pubsubHeartbeat      time.Duration
+	forwarding           bool
+	maxHops              int
 }
 
 type Setter func(*Config)
@@ -34,10 +102,130 @@ func New() *Config {
 		// Max peer consecutively connected.
 		// Each of this peers is equivalent to one routine, limit this is a performance consideration.
 		maxPeersConnected: 100,
+		// When the number of peers exceeds the high watermark the connection manager
+		// closes the less valuable peers until the low watermark is reached.
+		// Default 0 = connection manager disabled.
+		lowWatermark:  0,
+		highWatermark: 0,
+		// Fresh connections are not closed by connection manager during grace period.
+		// Default 30 seconds
+		gracePeriod: 30 * time.Second,
+		// How long misbehaving peers are banned.
+		// Default 1 hour
+		banDuration: 1 * time.Hour,
+		// File to persist bans across restarts.
+		// Default "" = no persistence
+		banListPath: "",
+		// Misbehaviour thresholds to automatically ban peers.
+		// 0 means never ban for the misbehaviour.
+		maxBadSignatures:     3,
+		maxOversizedFrames:   3,
+		maxHandshakeFailures: 10,
+		// Incoming messages and bytes per second allowed for each peer and for all peers.
+		// Default 0 = unlimited
+		peerMessageRate:   0,
+		peerByteRate:      0,
+		globalMessageRate: 0,
+		globalByteRate:    0,
+		// What to do when a rate limit is exceeded.
+		// Default PolicyDelay
+		rateLimitPolicy: PolicyDelay,
+		// Max incoming handshakes in progress at the same time.
+		// Default 128
+		maxPendingHandshakes: 128,
+		// Max incoming handshakes in progress from the same IP address.
+		// Default 8
+		maxHandshakesPerIP: 8,
+		// How the identity of dialed peers is verified.
+		// Default TrustOff
+		trustMode: TrustOff,
+		// File to persist the pinned identities.
+		// Default "" = no persistence
+		knownPeersPath: "",
+		// Persistent ED25519 private key used as node identity.
+		// Default nil = new identity generated on every run
+		identityKey: nil,
+		// Encoded certificate presented to remote peers during handshake.
+		// Default nil = no certificate
+		certificate: nil,
+		// Max contacts kept in each DHT k-bucket and returned for each lookup.
+		// Default 20
+		dhtBucketSize: 20,
+		// Concurrent requests sent during DHT iterative lookups.
+		// Default 3
+		dhtConcurrency: 3,
+		// Interval between DHT buckets refresh.
+		// Default 10 minutes, 0 = refresh disabled
+		dhtRefreshInterval: 10 * time.Minute,
+		// How long published DHT records live before expiration.
+		// Default 24 hours
+		recordTTL: 24 * time.Hour,
+		// Interval between published DHT records republish.
+		// Default 1 hour, 0 = republish disabled
+		recordRepublish: 1 * time.Hour,
+		// Max number of DHT records stored for remote peers.
+		// Default 4096
+		maxRecords: 4096,
+		// Max number of DHT records stored for each publisher.
+		// Default 16
+		maxPublisherRecords: 16,
+		// Number of connected peers the node tries to keep dialing peers learned through peer exchange.
+		// Default 0 = peer exchange disabled
+		targetDegree: 0,
+		// Max addresses accepted from each peer during peer exchange.
+		// Default 8
+		maxAddrsPerSource: 8,
+		// Addresses dialed when the node starts listening.
+		// Default nil = no bootstrap
+		bootstrapPeers: nil,
+		// Connected peers needed to consider the node bootstrapped.
+		// Default 1
+		minBootstrapPeers: 1,
+		// Multicast group used to discover peers in local network, eg. DiscoveryGroup.
+		// Default "" = discovery disabled
+		discoveryAddress: "",
+		// Network interface used to join the discovery group.
+		// Default "" = system default interface
+		discoveryInterface: "",
+		// Interval between announcements to the discovery group.
+		// Default 10 seconds
+		discoveryInterval: 10 * time.Second,
+		// File used to persist the known peers addresses and metadata.
+		// Default "" = kept in memory
+		peerStorePath: "",
+		// Max number of known peers dialed when the node starts listening.
+		// Default 8
+		redialKnownPeers: 8,
+		// Number of peers in each topic mesh.
+		// Default 6
+		pubsubDegree: 6,
+		// Interval between topic mesh maintenance and gossip.
+		// Default 1 second
+		pubsubHeartbeat: 1 * time.Second,
+		// Relay messages for peers without direct connection.
+		// Default false
+		forwarding: false,
+		// Max number of hops a forwarded message can travel.
+		// Default 8
+		maxHops: 8,
 		// Max time waiting for dial to complete.
 		// Default 5 seconds
 		// ref: https://pkg.go.dev/net#DialTimeout
 		dialTimeout: 5 * time.Second,
+		// Max time waiting for handshake to complete for dialed or accepted connections.
+		// A remote peer that stalls during handshake is dropped after this time.
+		// Default 10 seconds
+		handshakeTimeout: 10 * time.Second,
+		// Initial delay before redial a persistent peer.
+		// The delay grows exponentially with every failed attempt.
+		// Default 1 second
+		reconnectBackoff: 1 * time.Second,
+		// Max delay between redial attempts for persistent peers.
+		// Default 60 seconds
+		maxReconnectBackoff: 60 * time.Second,
+		// Max consecutive failed redial attempts before giving up with a persistent peer.
+		// Default 10 attempts
+		maxReconnectAttempts: 10,
 		// Max time waiting for I/O or peer interaction. After this time the connection will timeout and considered inactive.
 		// After every received/send message a new deadline is refreshed using this value.
 		// When the Keep Alive Interval is greater than the Idle Timeout, the BIG-IP system never sends TCP Keep-Alive packets as the connections are removed when reaching the TCP Idle Timeout .
@@ -79,10 +267,205 @@ func (c *Config) SelfListeningAddress() string {
 }
 
 // MaxPeersConnected returns the max number of connections.
-func (c *Config) MaxPeersConnected() uint8 {
+func (c *Config) MaxPeersConnected() uint32 {
 	return c.maxPeersConnected
 }
 
+// LowWatermark returns the number of peers to keep when the connection manager trims connections.
+func (c *Config) LowWatermark() uint32 {
+	return c.lowWatermark
+}
+
+// HighWatermark returns the number of peers that triggers the connection manager trimming.
+func (c *Config) HighWatermark() uint32 {
+	return c.highWatermark
+}
+
+// GracePeriod returns how long fresh connections are protected from trimming.
+func (c *Config) GracePeriod() time.Duration {
+	return c.gracePeriod
+}
+
+// BanDuration returns how long misbehaving peers are banned.
+func (c *Config) BanDuration() time.Duration {
+	return c.banDuration
+}
+
+// BanListPath returns the file used to persist bans.
+func (c *Config) BanListPath() string {
+	return c.banListPath
+}
+
+// MaxBadSignatures returns the number of invalid signed or encrypted messages allowed before ban a peer.
+func (c *Config) MaxBadSignatures() int {
+	return c.maxBadSignatures
+}
+
+// MaxOversizedFrames returns the number of oversized messages allowed before ban a peer.
+func (c *Config) MaxOversizedFrames() int {
+	return c.maxOversizedFrames
+}
+
+// MaxHandshakeFailures returns the number of failed handshakes allowed before ban an IP address.
+func (c *Config) MaxHandshakeFailures() int {
+	return c.maxHandshakeFailures
+}
+
+// PeerMessageRate returns the incoming messages per second allowed for each peer.
+func (c *Config) PeerMessageRate() int {
+	return c.peerMessageRate
+}
+
+// PeerByteRate returns the incoming bytes per second allowed for each peer.
+func (c *Config) PeerByteRate() int {
+	return c.peerByteRate
+}
+
+// GlobalMessageRate returns the incoming messages per second allowed for all peers.
+func (c *Config) GlobalMessageRate() int {
+	return c.globalMessageRate
+}
+
+// GlobalByteRate returns the incoming bytes per second allowed for all peers.
+func (c *Config) GlobalByteRate() int {
+	return c.globalByteRate
+}
+
+// RateLimitPolicy returns the policy applied when a rate limit is exceeded.
+func (c *Config) RateLimitPolicy() uint8 {
+	return c.rateLimitPolicy
+}
+
+// MaxPendingHandshakes returns the max incoming handshakes in progress at the same time.
+func (c *Config) MaxPendingHandshakes() uint32 {
+	return c.maxPendingHandshakes
+}
+
+// MaxHandshakesPerIP returns the max incoming handshakes in progress from the same IP address.
+func (c *Config) MaxHandshakesPerIP() uint32 {
+	return c.maxHandshakesPerIP
+}
+
+// TrustMode returns how the identity of dialed peers is verified.
+func (c *Config) TrustMode() uint8 {
+	return c.trustMode
+}
+
+// KnownPeersPath returns the file used to persist the pinned identities.
+func (c *Config) KnownPeersPath() string {
+	return c.knownPeersPath
+}
+
+// IdentityKey returns the ED25519 private key used as node identity.
+func (c *Config) IdentityKey() []byte {
+	return c.identityKey
+}
+
+// Certificate returns the encoded certificate presented to remote peers.
+func (c *Config) Certificate() []byte {
+	return c.certificate
+}
+
+// DHTBucketSize returns the max contacts kept in each DHT k-bucket.
+func (c *Config) DHTBucketSize() int {
+	return c.dhtBucketSize
+}
+
+// DHTConcurrency returns the concurrent requests sent during DHT lookups.
+func (c *Config) DHTConcurrency() int {
+	return c.dhtConcurrency
+}
+
+// DHTRefreshInterval returns the interval between DHT buckets refresh.
+func (c *Config) DHTRefreshInterval() time.Duration {
+	return c.dhtRefreshInterval
+}
+
+// RecordTTL returns how long published DHT records live before expiration.
+func (c *Config) RecordTTL() time.Duration {
+	return c.recordTTL
+}
+
+// RecordRepublishInterval returns the interval between published DHT records republish.
+func (c *Config) RecordRepublishInterval() time.Duration {
+	return c.recordRepublish
+}
+
+// MaxRecords returns the max number of DHT records stored for remote peers.
+func (c *Config) MaxRecords() int {
+	return c.maxRecords
+}
+
+// MaxPublisherRecords returns the max number of DHT records stored for each publisher.
+func (c *Config) MaxPublisherRecords() int {
+	return c.maxPublisherRecords
+}
+
+// TargetDegree returns the number of connected peers the node tries to keep.
+func (c *Config) TargetDegree() int {
+	return c.targetDegree
+}
+
+// MaxAddressesPerSource returns the max addresses accepted from each peer during peer exchange.
+func (c *Config) MaxAddressesPerSource() int {
+	return c.maxAddrsPerSource
+}
+
+// BootstrapPeers returns the addresses dialed when the node starts listening.
+func (c *Config) BootstrapPeers() []string {
+	return c.bootstrapPeers
+}
+
+// MinBootstrapPeers returns the connected peers needed to consider the node bootstrapped.
+func (c *Config) MinBootstrapPeers() int {
+	return c.minBootstrapPeers
+}
+
+// DiscoveryAddress returns the multicast group used to discover peers in local network.
+func (c *Config) DiscoveryAddress() string {
+	return c.discoveryAddress
+}
+
+// DiscoveryInterface returns the network interface used to join the discovery group.
+func (c *Config) DiscoveryInterface() string {
+	return c.discoveryInterface
+}
+
+// DiscoveryInterval returns the interval between announcements to the discovery group.
+func (c *Config) DiscoveryInterval() time.Duration {
+	return c.discoveryInterval
+}
+
+// PeerStorePath returns the file used to persist the known peers addresses and metadata.
+func (c *Config) PeerStorePath() string {
+	return c.peerStorePath
+}
+
+// RedialKnownPeers returns the max number of known peers dialed when the node starts listening.
+func (c *Config) RedialKnownPeers() int {
+	return c.redialKnownPeers
+}
+
+// PubSubDegree returns the number of peers in each topic mesh.
+func (c *Config) PubSubDegree() int {
+	return c.pubsubDegree
+}
+
+// PubSubHeartbeat returns the interval between topic mesh maintenance and gossip.
+func (c *Config) PubSubHeartbeat() time.Duration {
+	return c.pubsubHeartbeat
+}
+
+// Forwarding returns true if messages are forwarded through connected peers.
+func (c *Config) Forwarding() bool {
+	return c.forwarding
+}
+
+// MaxHops returns the max number of hops a forwarded message can travel.
+func (c *Config) MaxHops() int {
+	return c.maxHops
+}
+
 // PoolBufferSize returns the max payload size allowed to received from peers.
 func (c *Config) PoolBufferSize() int {
 	return c.poolBufferSize
@@ -98,6 +481,26 @@ func (c *Config) IdleTimeout() time.Duration {
 	return c.idleTimeout
 }
 
+// HandshakeTimeout returns max time waiting for handshake to complete.
+func (c *Config) HandshakeTimeout() time.Duration {
+	return c.handshakeTimeout
+}
+
+// ReconnectBackoff returns the initial delay before redial a persistent peer.
+func (c *Config) ReconnectBackoff() time.Duration {
+	return c.reconnectBackoff
+}
+
+// MaxReconnectBackoff returns the max delay between redial attempts for persistent peers.
+func (c *Config) MaxReconnectBackoff() time.Duration {
+	return c.maxReconnectBackoff
+}
+
+// MaxReconnectAttempts returns the max consecutive failed redial attempts for persistent peers.
+func (c *Config) MaxReconnectAttempts() int {
+	return c.maxReconnectAttempts
+}
+
 // SetKeepAlive set the flag to keep alive or not the TCP connection.
 func SetKeepAlive(ka time.Duration) Setter {
 	return func(conf *Config) {
@@ -128,12 +531,29 @@ func SetSelfListeningAddress(address string) Setter {
 
 // SetMaxPeersConnected sets the maximum number of connections allowed for routing.
 // If the number of connections > MaxPeersConnected then router drop new connections.
-func SetMaxPeersConnected(maxPeers uint8) Setter {
+func SetMaxPeersConnected(maxPeers uint32) Setter {
 	return func(conf *Config) {
 		conf.maxPeersConnected = maxPeers
 	}
 }
 
+// SetWatermarks sets the low and high watermarks for the connection manager.
+// If the number of connections > high then the less valuable peers are closed until the number of connections = low.
+// A high watermark equal to 0 disables the connection manager.
+func SetWatermarks(low, high uint32) Setter {
+	return func(conf *Config) {
+		conf.lowWatermark = low
+		conf.highWatermark = high
+	}
+}
+
+// SetGracePeriod sets how long fresh connections are protected from connection manager trimming.
+func SetGracePeriod(grace time.Duration) Setter {
+	return func(conf *Config) {
+		conf.gracePeriod = grace
+	}
+}
+
 // SetPoolBufferSize sets the maximum bytes size received from peers.
 func SetPoolBufferSize(maxPayloadSize int) Setter {
 	return func(conf *Config) {
@@ -168,3 +588,299 @@ func SetDialTimeout(timeout time.Duration) Setter {
 		conf.dialTimeout = timeout
 	}
 }
+
+// SetHandshakeTimeout sets how long the node will wait for a handshake to complete.
+// It applies to both dialed and accepted connections.
+// 0 means no handshake deadline.
+func SetHandshakeTimeout(timeout time.Duration) Setter {
+	return func(conf *Config) {
+		conf.handshakeTimeout = timeout
+	}
+}
+
+// SetReconnectBackoff sets the initial delay before redial a persistent peer.
+// The delay is doubled after every failed attempt up to MaxReconnectBackoff.
+func SetReconnectBackoff(backoff time.Duration) Setter {
+	return func(conf *Config) {
+		conf.reconnectBackoff = backoff
+	}
+}
+
+// SetMaxReconnectBackoff sets the max delay between redial attempts for persistent peers.
+func SetMaxReconnectBackoff(backoff time.Duration) Setter {
+	return func(conf *Config) {
+		conf.maxReconnectBackoff = backoff
+	}
+}
+
+// SetMaxReconnectAttempts sets the max consecutive failed redial attempts before giving up with a persistent peer.
+// 0 means no limit.
+func SetMaxReconnectAttempts(attempts int) Setter {
+	return func(conf *Config) {
+		conf.maxReconnectAttempts = attempts
+	}
+}
+
+// SetBanDuration sets how long misbehaving peers are banned.
+// 0 means banned forever.
+func SetBanDuration(duration time.Duration) Setter {
+	return func(conf *Config) {
+		conf.banDuration = duration
+	}
+}
+
+// SetBanListPath sets the file used to persist bans across restarts.
+func SetBanListPath(path string) Setter {
+	return func(conf *Config) {
+		conf.banListPath = path
+	}
+}
+
+// SetMaxBadSignatures sets the number of invalid signed or encrypted messages allowed before ban a peer.
+// 0 disables the automatic ban.
+func SetMaxBadSignatures(max int) Setter {
+	return func(conf *Config) {
+		conf.maxBadSignatures = max
+	}
+}
+
+// SetMaxOversizedFrames sets the number of oversized messages allowed before ban a peer.
+// 0 disables the automatic ban.
+func SetMaxOversizedFrames(max int) Setter {
+	return func(conf *Config) {
+		conf.maxOversizedFrames = max
+	}
+}
+
+// SetMaxHandshakeFailures sets the number of failed handshakes allowed before ban an IP address.
+// 0 disables the automatic ban.
+func SetMaxHandshakeFailures(max int) Setter {
+	return func(conf *Config) {
+		conf.maxHandshakeFailures = max
+	}
+}
+
+// SetPeerRateLimit sets the incoming messages and bytes per second allowed for each peer.
+// 0 means unlimited.
+func SetPeerRateLimit(messages, bytes int) Setter {
+	return func(conf *Config) {
+		conf.peerMessageRate = messages
+		conf.peerByteRate = bytes
+	}
+}
+
+// SetGlobalRateLimit sets the incoming messages and bytes per second allowed for all peers.
+// 0 means unlimited.
+func SetGlobalRateLimit(messages, bytes int) Setter {
+	return func(conf *Config) {
+		conf.globalMessageRate = messages
+		conf.globalByteRate = bytes
+	}
+}
+
+// SetRateLimitPolicy sets the policy applied when a rate limit is exceeded.
+// eg. PolicyDelay, PolicyDrop or PolicyDisconnect.
+func SetRateLimitPolicy(policy uint8) Setter {
+	return func(conf *Config) {
+		conf.rateLimitPolicy = policy
+	}
+}
+
+// SetMaxPendingHandshakes sets the max incoming handshakes in progress at the same time.
+// Connections exceeding the limit are closed before handshake.
+func SetMaxPendingHandshakes(max uint32) Setter {
+	return func(conf *Config) {
+		conf.maxPendingHandshakes = max
+	}
+}
+
+// SetMaxHandshakesPerIP sets the max incoming handshakes in progress from the same IP address.
+// Connections exceeding the limit are closed before handshake.
+func SetMaxHandshakesPerIP(max uint32) Setter {
+	return func(conf *Config) {
+		conf.maxHandshakesPerIP = max
+	}
+}
+
+// SetTrustMode sets how the identity of dialed peers is verified.
+// eg. TrustOff, TrustOnFirstUse or TrustStrict.
+func SetTrustMode(mode uint8) Setter {
+	return func(conf *Config) {
+		conf.trustMode = mode
+	}
+}
+
+// SetKnownPeersPath sets the file used to persist the pinned identities, similar to ssh known_hosts.
+func SetKnownPeersPath(path string) Setter {
+	return func(conf *Config) {
+		conf.knownPeersPath = path
+	}
+}
+
+// SetIdentityKey sets a persistent ED25519 private key used as node identity.
+// The key must be 64 bytes long, eg. a key generated with cert.GenerateKey.
+func SetIdentityKey(key []byte) Setter {
+	return func(conf *Config) {
+		conf.identityKey = key
+	}
+}
+
+// SetCertificate sets the encoded certificate presented to remote peers during handshake.
+// The certificate must be issued for the identity key, see cert.Issue.
+func SetCertificate(cert []byte) Setter {
+	return func(conf *Config) {
+		conf.certificate = cert
+	}
+}
+
+// SetDHTBucketSize sets the max contacts kept in each DHT k-bucket, known as k in Kademlia.
+// It is also the number of closest contacts returned by lookups.
+func SetDHTBucketSize(k int) Setter {
+	return func(conf *Config) {
+		conf.dhtBucketSize = k
+	}
+}
+
+// SetDHTConcurrency sets the concurrent requests sent during DHT lookups, known as alpha in Kademlia.
+func SetDHTConcurrency(alpha int) Setter {
+	return func(conf *Config) {
+		conf.dhtConcurrency = alpha
+	}
+}
+
+// SetDHTRefreshInterval sets the interval between DHT buckets refresh.
+// Buckets without lookups during the interval are refreshed with a lookup for a random ID in the bucket range.
+// 0 means refresh disabled.
+func SetDHTRefreshInterval(interval time.Duration) Setter {
+	return func(conf *Config) {
+		conf.dhtRefreshInterval = interval
+	}
+}
+
+// SetRecordTTL sets how long published DHT records live before expiration.
+// Peers reject records living more than 7 days, longer TTLs are capped to 7 days.
+func SetRecordTTL(ttl time.Duration) Setter {
+	return func(conf *Config) {
+		conf.recordTTL = ttl
+	}
+}
+
+// SetRecordRepublishInterval sets the interval between published DHT records republish.
+// Republished records get a renewed expiration, the interval should be lower than record TTL.
+// 0 means republish disabled.
+func SetRecordRepublishInterval(interval time.Duration) Setter {
+	return func(conf *Config) {
+		conf.recordRepublish = interval
+	}
+}
+
+// SetRecordLimits sets the max number of DHT records stored in total and for each publisher.
+// When the store is full the records closer to expiration are evicted first, 0 means unlimited.
+func SetRecordLimits(max, perPublisher int) Setter {
+	return func(conf *Config) {
+		conf.maxRecords = max
+		conf.maxPublisherRecords = perPublisher
+	}
+}
+
+// SetTargetDegree sets the number of connected peers the node tries to keep.
+// Connected peers are asked for their known peers and the learned addresses are dialed until the target is reached.
+// 0 means peer exchange disabled.
+func SetTargetDegree(degree int) Setter {
+	return func(conf *Config) {
+		conf.targetDegree = degree
+	}
+}
+
+// SetMaxAddressesPerSource sets the max addresses accepted from each peer during peer exchange.
+// It limits how much a single peer can influence the connections of the node.
+func SetMaxAddressesPerSource(max int) Setter {
+	return func(conf *Config) {
+		conf.maxAddrsPerSource = max
+	}
+}
+
+// SetBootstrapPeers sets the addresses dialed when the node starts listening.
+// Failed addresses are redialed using the reconnect backoff settings.
+func SetBootstrapPeers(addrs ...string) Setter {
+	return func(conf *Config) {
+		conf.bootstrapPeers = addrs
+	}
+}
+
+// SetMinBootstrapPeers sets the connected peers needed to consider the node bootstrapped.
+// A Bootstrapped signal is emitted when the minimum is reached.
+func SetMinBootstrapPeers(min int) Setter {
+	return func(conf *Config) {
+		conf.minBootstrapPeers = min
+	}
+}
+
+// SetDiscoveryAddress sets the multicast group used to discover peers in local network, eg. DiscoveryGroup.
+// Nodes in the same group announce their listening address and dial each other automatically.
+func SetDiscoveryAddress(group string) Setter {
+	return func(conf *Config) {
+		conf.discoveryAddress = group
+	}
+}
+
+// SetDiscoveryInterface sets the network interface name used to join the discovery group eg. eth0.
+func SetDiscoveryInterface(name string) Setter {
+	return func(conf *Config) {
+		conf.discoveryInterface = name
+	}
+}
+
+// SetDiscoveryInterval sets the interval between announcements to the discovery group.
+func SetDiscoveryInterval(interval time.Duration) Setter {
+	return func(conf *Config) {
+		conf.discoveryInterval = interval
+	}
+}
+
+// SetPeerStorePath sets the file used to persist the known peers addresses and metadata.
+func SetPeerStorePath(path string) Setter {
+	return func(conf *Config) {
+		conf.peerStorePath = path
+	}
+}
+
+// SetRedialKnownPeers sets the max number of known peers dialed when the node starts listening.
+// The most recently seen peers are dialed first, use 0 to disable.
+func SetRedialKnownPeers(max int) Setter {
+	return func(conf *Config) {
+		conf.redialKnownPeers = max
+	}
+}
+
+// SetPubSubDegree sets the number of peers in each topic mesh.
+// The mesh is refilled below 2/3 of degree and trimmed above twice the degree.
+func SetPubSubDegree(degree int) Setter {
+	return func(conf *Config) {
+		conf.pubsubDegree = degree
+	}
+}
+
+// SetPubSubHeartbeat sets the interval between topic mesh maintenance and gossip.
+func SetPubSubHeartbeat(interval time.Duration) Setter {
+	return func(conf *Config) {
+		conf.pubsubHeartbeat = interval
+	}
+}
+
+// SetForwarding sets if messages are forwarded through connected peers.
+// If enabled, messages for peers without direct connection are sent through the closest connected peer
+// encrypted end-to-end, and messages from other peers are relayed to their destination.
+func SetForwarding(enabled bool) Setter {
+	return func(conf *Config) {
+		conf.forwarding = enabled
+	}
+}
+
+// SetMaxHops sets the max number of hops a forwarded message can travel.
+// The hops are bounded to 16.
+func SetMaxHops(hops int) Setter {
+	return func(conf *Config) {
+		conf.maxHops = hops
+	}
+}
@@ -9,7 +9,7 @@ func TestWrite(t *testing.T) {
 
 	settings := []struct {
 		Name              string
-		MaxPeersConnected uint8
+		MaxPeersConnected uint32
 	}{{
 		Name:              "10",
 		MaxPeersConnected: 10,
@@ -25,6 +25,12 @@ func TestWrite(t *testing.T) {
 	}, {
 		Name:              "255",
 		MaxPeersConnected: 255,
+	}, {
+		Name:              "1000",
+		MaxPeersConnected: 1000,
+	}, {
+		Name:              "10000",
+		MaxPeersConnected: 10000,
 	}}
 
 	myLib := func(c ...Setter) *Config {
@@ -111,3 +117,278 @@ func TestDialTimeout(t *testing.T) {
 		t.Errorf("expected DialTimeout %#v, got settings %v", expected, settings.DialTimeout())
 	}
 }
+
+func TestHandshakeTimeout(t *testing.T) {
+	settings := New()
+	expected := time.Second * 3
+	callable := SetHandshakeTimeout(expected)
+	callable(settings)
+
+	if settings.HandshakeTimeout() != expected {
+		t.Errorf("expected HandshakeTimeout %#v, got settings %v", expected, settings.HandshakeTimeout())
+	}
+}
+
+func TestReconnectSettings(t *testing.T) {
+	settings := New()
+	settings.Write(
+		SetReconnectBackoff(2*time.Second),
+		SetMaxReconnectBackoff(time.Minute),
+		SetMaxReconnectAttempts(3),
+	)
+
+	if settings.ReconnectBackoff() != 2*time.Second {
+		t.Errorf("expected ReconnectBackoff %v, got settings %v", 2*time.Second, settings.ReconnectBackoff())
+	}
+
+	if settings.MaxReconnectBackoff() != time.Minute {
+		t.Errorf("expected MaxReconnectBackoff %v, got settings %v", time.Minute, settings.MaxReconnectBackoff())
+	}
+
+	if settings.MaxReconnectAttempts() != 3 {
+		t.Errorf("expected MaxReconnectAttempts %v, got settings %v", 3, settings.MaxReconnectAttempts())
+	}
+}
+
+func TestWatermarks(t *testing.T) {
+	settings := New()
+	settings.Write(SetWatermarks(10, 20), SetGracePeriod(time.Second))
+
+	if settings.LowWatermark() != 10 || settings.HighWatermark() != 20 {
+		t.Errorf("expected watermarks %d-%d, got settings %d-%d", 10, 20, settings.LowWatermark(), settings.HighWatermark())
+	}
+
+	if settings.GracePeriod() != time.Second {
+		t.Errorf("expected GracePeriod %v, got settings %v", time.Second, settings.GracePeriod())
+	}
+}
+
+func TestBanSettings(t *testing.T) {
+	settings := New()
+	settings.Write(
+		SetBanDuration(time.Minute),
+		SetBanListPath("bans.json"),
+		SetMaxBadSignatures(1),
+		SetMaxOversizedFrames(2),
+		SetMaxHandshakeFailures(3),
+	)
+
+	if settings.BanDuration() != time.Minute || settings.BanListPath() != "bans.json" {
+		t.Errorf("expected ban duration and path settings, got %v %s", settings.BanDuration(), settings.BanListPath())
+	}
+
+	if settings.MaxBadSignatures() != 1 || settings.MaxOversizedFrames() != 2 || settings.MaxHandshakeFailures() != 3 {
+		t.Errorf("expected misbehaviour thresholds 1, 2, 3")
+	}
+}
+
+func TestRateLimitSettings(t *testing.T) {
+	settings := New()
+	if settings.RateLimitPolicy() != PolicyDelay || settings.PeerMessageRate() != 0 {
+		t.Errorf("expected unlimited rate with delay policy by default")
+	}
+
+	settings.Write(
+		SetPeerRateLimit(10, 1024),
+		SetGlobalRateLimit(100, 8192),
+		SetRateLimitPolicy(PolicyDrop),
+	)
+
+	if settings.PeerMessageRate() != 10 || settings.PeerByteRate() != 1024 {
+		t.Errorf("expected peer rate limit 10 messages and 1024 bytes")
+	}
+
+	if settings.GlobalMessageRate() != 100 || settings.GlobalByteRate() != 8192 {
+		t.Errorf("expected global rate limit 100 messages and 8192 bytes")
+	}
+
+	if settings.RateLimitPolicy() != PolicyDrop {
+		t.Errorf("expected drop policy")
+	}
+}
+
+func TestHandshakeLimitSettings(t *testing.T) {
+	settings := New()
+	settings.Write(
+		SetMaxPendingHandshakes(16),
+		SetMaxHandshakesPerIP(2),
+	)
+
+	if settings.MaxPendingHandshakes() != 16 || settings.MaxHandshakesPerIP() != 2 {
+		t.Errorf("expected handshake limits 16 and 2, got %d and %d", settings.MaxPendingHandshakes(), settings.MaxHandshakesPerIP())
+	}
+}
+
+func TestTrustSettings(t *testing.T) {
+	settings := New()
+	if settings.TrustMode() != TrustOff {
+		t.Errorf("expected trust mode off by default")
+	}
+
+	settings.Write(
+		SetTrustMode(TrustStrict),
+		SetKnownPeersPath("known_peers"),
+	)
+
+	if settings.TrustMode() != TrustStrict || settings.KnownPeersPath() != "known_peers" {
+		t.Errorf("expected strict trust mode with known peers file")
+	}
+}
+
+func TestIdentitySettings(t *testing.T) {
+	settings := New()
+	if settings.IdentityKey() != nil || settings.Certificate() != nil {
+		t.Errorf("expected no identity key and certificate by default")
+	}
+
+	settings.Write(
+		SetIdentityKey([]byte("key")),
+		SetCertificate([]byte("cert")),
+	)
+
+	if string(settings.IdentityKey()) != "key" || string(settings.Certificate()) != "cert" {
+		t.Errorf("expected identity key and certificate set")
+	}
+}
+
+func TestDHTSettings(t *testing.T) {
+	settings := New()
+	if settings.DHTBucketSize() != 20 || settings.DHTConcurrency() != 3 || settings.DHTRefreshInterval() != 10*time.Minute {
+		t.Errorf("expected default DHT settings k=20, alpha=3 and 10 minutes refresh")
+	}
+
+	settings.Write(
+		SetDHTBucketSize(8),
+		SetDHTConcurrency(1),
+		SetDHTRefreshInterval(0),
+	)
+
+	if settings.DHTBucketSize() != 8 || settings.DHTConcurrency() != 1 || settings.DHTRefreshInterval() != 0 {
+		t.Errorf("expected DHT settings k=8, alpha=1 and refresh disabled")
+	}
+}
+
+func TestRecordSettings(t *testing.T) {
+	settings := New()
+	if settings.RecordTTL() != 24*time.Hour || settings.RecordRepublishInterval() != time.Hour {
+		t.Errorf("expected default record TTL 24 hours and republish every hour")
+	}
+
+	settings.Write(
+		SetRecordTTL(time.Hour),
+		SetRecordRepublishInterval(0),
+	)
+
+	if settings.RecordTTL() != time.Hour || settings.RecordRepublishInterval() != 0 {
+		t.Errorf("expected record TTL 1 hour and republish disabled")
+	}
+}
+
+func TestRecordLimitsSettings(t *testing.T) {
+	settings := New()
+	if settings.MaxRecords() != 4096 || settings.MaxPublisherRecords() != 16 {
+		t.Errorf("expected 4096 records and 16 records per publisher by default")
+	}
+
+	settings.Write(SetRecordLimits(10, 2))
+	if settings.MaxRecords() != 10 || settings.MaxPublisherRecords() != 2 {
+		t.Errorf("expected 10 records and 2 records per publisher")
+	}
+}
+
+func TestPeerExchangeSettings(t *testing.T) {
+	settings := New()
+	if settings.TargetDegree() != 0 || settings.MaxAddressesPerSource() != 8 {
+		t.Errorf("expected peer exchange disabled and 8 addresses per source by default")
+	}
+
+	settings.Write(
+		SetTargetDegree(6),
+		SetMaxAddressesPerSource(2),
+	)
+
+	if settings.TargetDegree() != 6 || settings.MaxAddressesPerSource() != 2 {
+		t.Errorf("expected target degree 6 and 2 addresses per source")
+	}
+}
+
+func TestBootstrapSettings(t *testing.T) {
+	settings := New()
+	if settings.BootstrapPeers() != nil || settings.MinBootstrapPeers() != 1 {
+		t.Errorf("expected no bootstrap peers and 1 min bootstrap peer by default")
+	}
+
+	settings.Write(
+		SetBootstrapPeers("127.0.0.1:2379", "127.0.0.1:2380"),
+		SetMinBootstrapPeers(2),
+	)
+
+	if len(settings.BootstrapPeers()) != 2 || settings.MinBootstrapPeers() != 2 {
+		t.Errorf("expected 2 bootstrap peers and 2 min bootstrap peers")
+	}
+}
+
+func TestDiscoverySettings(t *testing.T) {
+	settings := New()
+	if settings.DiscoveryAddress() != "" || settings.DiscoveryInterface() != "" || settings.DiscoveryInterval() != 10*time.Second {
+		t.Errorf("expected discovery disabled with 10 seconds interval by default")
+	}
+
+	settings.Write(
+		SetDiscoveryAddress(DiscoveryGroup),
+		SetDiscoveryInterface("eth0"),
+		SetDiscoveryInterval(time.Second),
+	)
+
+	if settings.DiscoveryAddress() != DiscoveryGroup || settings.DiscoveryInterface() != "eth0" || settings.DiscoveryInterval() != time.Second {
+		t.Errorf("expected discovery in default group using eth0 every second")
+	}
+}
+
+func TestPeerStoreSettings(t *testing.T) {
+	settings := New()
+	if settings.PeerStorePath() != "" || settings.RedialKnownPeers() != 8 {
+		t.Errorf("expected in-memory peerstore redialing 8 peers by default")
+	}
+
+	settings.Write(
+		SetPeerStorePath("peers.json"),
+		SetRedialKnownPeers(0),
+	)
+
+	if settings.PeerStorePath() != "peers.json" || settings.RedialKnownPeers() != 0 {
+		t.Errorf("expected peerstore in peers.json with redial disabled")
+	}
+}
+
+func TestPubSubSettings(t *testing.T) {
+	settings := New()
+	if settings.PubSubDegree() != 6 || settings.PubSubHeartbeat() != time.Second {
+		t.Errorf("expected pubsub degree 6 with 1 second heartbeat by default")
+	}
+
+	settings.Write(
+		SetPubSubDegree(3),
+		SetPubSubHeartbeat(100*time.Millisecond),
+	)
+
+	if settings.PubSubDegree() != 3 || settings.PubSubHeartbeat() != 100*time.Millisecond {
+		t.Errorf("expected pubsub degree 3 with 100ms heartbeat")
+	}
+}
+
+func TestForwardingSettings(t *testing.T) {
+	settings := New()
+	if settings.Forwarding() || settings.MaxHops() != 8 {
+		t.Errorf("expected forwarding disabled with 8 max hops by default")
+	}
+
+	settings.Write(
+		SetForwarding(true),
+		SetMaxHops(4),
+	)
+
+	if !settings.Forwarding() || settings.MaxHops() != 4 {
+		t.Errorf("expected forwarding enabled with 4 max hops")
+	}
+}
@@ -0,0 +1,137 @@
+package noise
+
+import (
+	"errors"
+	"io"
+	"net"
+)
+
+// frame identify the kind of packet exchanged between peers.
+// Data frames hold application messages, the other frames are used to control the connection.
+type frame uint8
+
+const (
+	// Application message.
+	dataFrame frame = iota
+	// Notify to remote peer that the connection is going to be closed.
+	goodbyeFrame
+	// Kademlia DHT requests and responses.
+	dhtFrame
+	// Peer exchange requests and responses.
+	pexFrame
+	// Pubsub subscriptions, mesh control and published messages.
+	pubsubFrame
+	// Messages flooded to the whole network.
+	broadcastFrame
+	// Messages relayed to peers without direct connection.
+	forwardFrame
+)
+
+// String returns the protocol name for frame.
+func (f frame) String() string {
+	switch f {
+	case dataFrame:
+		return "data"
+	case goodbyeFrame:
+		return "goodbye"
+	case dhtFrame:
+		return "dht"
+	case pexFrame:
+		return "pex"
+	case pubsubFrame:
+		return "pubsub"
+	case broadcastFrame:
+		return "broadcast"
+	case forwardFrame:
+		return "forward"
+	default:
+		return "unknown"
+	}
+}
+
+// [Reason] aliases for uint8 type.
+// It describes why a peer connection was closed.
+type Reason uint8
+
+const (
+	// Connection closed without explanation.
+	ReasonUnknown Reason = iota
+	// The node is shutting down.
+	ReasonShutdown
+	// Connection closed by local node.
+	ReasonLocalClose
+	// Connection closed by remote peer.
+	ReasonRemoteClose
+	// No I/O activity with peer during idle timeout.
+	ReasonIdleTimeout
+	// Peer sent invalid data eg. invalid signature or undecryptable message.
+	ReasonProtocolError
+	// Peer is not allowed to connect.
+	ReasonBanned
+	// Another connection with the same peer was kept.
+	ReasonDuplicate
+	// Connection closed by connection manager to keep the number of peers within watermarks.
+	ReasonTrimmed
+	// Peer exceeded the incoming messages or bandwidth rate limits.
+	ReasonRateLimited
+	// Remote identity doesn't match the identity pinned for the dialed address.
+	ReasonUntrusted
+)
+
+// String return a human readable representation for reason.
+func (r Reason) String() string {
+	switch r {
+	case ReasonShutdown:
+		return "shutdown"
+	case ReasonLocalClose:
+		return "local close"
+	case ReasonRemoteClose:
+		return "remote close"
+	case ReasonIdleTimeout:
+		return "idle timeout"
+	case ReasonProtocolError:
+		return "protocol error"
+	case ReasonBanned:
+		return "banned"
+	case ReasonDuplicate:
+		return "duplicate"
+	case ReasonTrimmed:
+		return "trimmed"
+	case ReasonRateLimited:
+		return "rate limited"
+	case ReasonUntrusted:
+		return "untrusted"
+	default:
+		return "unknown"
+	}
+}
+
+// reasonFromBytes decode the reason bundled in a goodbye frame.
+func reasonFromBytes(b []byte) Reason {
+	if len(b) == 0 {
+		return ReasonUnknown
+	}
+
+	return Reason(b[0])
+}
+
+// reasonFromError infer the reason for a connection closed with error.
+func reasonFromError(err error) Reason {
+	// Deadline exceeded without I/O activity.
+	if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+		return ReasonIdleTimeout
+	}
+
+	// Local connection closed
+	if errors.Is(err, net.ErrClosed) {
+		return ReasonLocalClose
+	}
+
+	// Remote peer closed or dropped the connection, even in the middle of a message.
+	if _, isNetError := err.(*net.OpError); isNetError || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return ReasonRemoteClose
+	}
+
+	// Anything else is related to invalid incoming data.
+	return ReasonProtocolError
+}
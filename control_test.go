@@ -0,0 +1,74 @@
+package noise
+
+import (
+	"errors"
+	"io"
+	"net"
+	"os"
+	"testing"
+)
+
+func TestReasonString(t *testing.T) {
+	reasons := []struct {
+		reason   Reason
+		expected string
+	}{
+		{ReasonUnknown, "unknown"},
+		{ReasonShutdown, "shutdown"},
+		{ReasonLocalClose, "local close"},
+		{ReasonRemoteClose, "remote close"},
+		{ReasonIdleTimeout, "idle timeout"},
+		{ReasonProtocolError, "protocol error"},
+		{ReasonBanned, "banned"},
+		{ReasonDuplicate, "duplicate"},
+		{ReasonTrimmed, "trimmed"},
+		{ReasonRateLimited, "rate limited"},
+		{ReasonUntrusted, "untrusted"},
+	}
+
+	for _, e := range reasons {
+		t.Run(e.expected, func(t *testing.T) {
+			if e.reason.String() != e.expected {
+				t.Errorf("expected reason %s, got %s", e.expected, e.reason.String())
+			}
+		})
+	}
+}
+
+func TestReasonFromBytes(t *testing.T) {
+	if reasonFromBytes(nil) != ReasonUnknown {
+		t.Errorf("expected unknown reason for empty goodbye")
+	}
+
+	if reasonFromBytes([]byte{byte(ReasonBanned)}) != ReasonBanned {
+		t.Errorf("expected banned reason decoded from goodbye")
+	}
+}
+
+func TestReasonFromError(t *testing.T) {
+	timeout := &net.OpError{Op: "read", Err: os.ErrDeadlineExceeded}
+	closed := &net.OpError{Op: "read", Err: net.ErrClosed}
+	reset := &net.OpError{Op: "read", Err: errors.New("connection reset by peer")}
+
+	errs := []struct {
+		name     string
+		err      error
+		expected Reason
+	}{
+		{"timeout", timeout, ReasonIdleTimeout},
+		{"closed", closed, ReasonLocalClose},
+		{"reset", reset, ReasonRemoteClose},
+		{"eof", io.EOF, ReasonRemoteClose},
+		{"truncated", io.ErrUnexpectedEOF, ReasonRemoteClose},
+		{"oversized", errOversizedMessage(2048, 1024), ReasonProtocolError},
+		{"signature", errVerifyingSignature(errors.New("invalid")), ReasonProtocolError},
+	}
+
+	for _, e := range errs {
+		t.Run(e.name, func(t *testing.T) {
+			if got := reasonFromError(e.err); got != e.expected {
+				t.Errorf("expected reason %s, got %s", e.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,712 @@
+package noise
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"log"
+	"math/bits"
+	"net"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// dhtRequestTimeout is the max time waiting for a response to a DHT request.
+const dhtRequestTimeout = 5 * time.Second
+
+// maxLookupDials is the max number of contacts dialed at the same time for DHT requests.
+// Contact addresses are supplied by remote peers, so the dials are bounded to avoid being used to scan hosts.
+const maxLookupDials = 8
+
+// maxRefreshCpl is the max common prefix length refreshed, deeper buckets are rarely populated.
+const maxRefreshCpl = 15
+
+// dhtKind identify the kind of message exchanged by the Kademlia DHT.
+type dhtKind uint8
+
+const (
+	// Announce the listening address to a new connected peer.
+	dhtPing dhtKind = iota
+	// Request the closest contacts to target.
+	dhtFindNode
+	// Request the value stored for target or the closest contacts to target.
+	dhtFindValue
+	// Response with the closest contacts and the value if found.
+	dhtNodes
+	// Request to store the record in message.
+	dhtStore
+	// Response to a store request with the rejection error if any.
+	dhtStored
+)
+
+// contact holds the information needed to reach a peer.
+type contact struct {
+	ID   ID
+	Addr string // listening address
+}
+
+// dhtMessage is the request and response exchanged between peers in dht frames.
+type dhtMessage struct {
+	Kind     dhtKind
+	Nonce    uint64 // match responses with requests
+	Addr     string // sender listening address
+	Target   ID
+	Contacts []contact
+	Value    []byte
+	Error    string
+}
+
+// encodeDHT encode a dht message to bytes.
+func encodeDHT(m dhtMessage) []byte {
+	var buffer bytes.Buffer
+	gob.NewEncoder(&buffer).Encode(m)
+	return buffer.Bytes()
+}
+
+// decodeDHT decode incoming bytes to a dht message.
+func decodeDHT(b []byte) (dhtMessage, error) {
+	var m dhtMessage
+	err := gob.NewDecoder(bytes.NewReader(b)).Decode(&m)
+	return m, err
+}
+
+// distance returns the Kademlia XOR distance between IDs.
+func distance(a, b ID) ID {
+	var d ID
+	for i := range a {
+		d[i] = a[i] ^ b[i]
+	}
+
+	return d
+}
+
+// commonPrefixLen returns the number of leading bits shared by IDs.
+// It is used as the bucket index for a contact.
+func commonPrefixLen(a, b ID) int {
+	d := distance(a, b)
+	for i, x := range d {
+		if x != 0 {
+			return i*8 + bits.LeadingZeros8(x)
+		}
+	}
+
+	return len(d) * 8
+}
+
+// closer returns true if a is closer than b to target.
+func closer(target, a, b ID) bool {
+	da, db := distance(target, a), distance(target, b)
+	return bytes.Compare(da[:], db[:]) < 0
+}
+
+// randomIDWithCpl returns a random ID sharing exactly cpl leading bits with self.
+func randomIDWithCpl(self ID, cpl int) ID {
+	var id ID
+	rand.Read(id[:])
+	// Copy the whole prefix bytes and the remaining prefix bits.
+	full, rest := cpl/8, cpl%8
+	copy(id[:full], self[:full])
+	mask := byte(0xff) << (8 - rest)
+	id[full] = self[full]&mask | id[full]&^mask
+	// The next bit must differ from self to leave the prefix.
+	bit := byte(0x80) >> rest
+	id[full] = id[full]&^bit | ^self[full]&bit
+	return id
+}
+
+// kbucket holds the contacts sharing the same common prefix length with local node.
+type kbucket struct {
+	contacts  []contact // least recently seen first
+	refreshed time.Time // last lookup in bucket range
+}
+
+// table implements the Kademlia routing table with one k-bucket for each bit in ID.
+type table struct {
+	mu      sync.Mutex
+	self    ID
+	k       int
+	buckets [len(ID{}) * 8]kbucket
+}
+
+func newTable(self ID, k int) *table {
+	return &table{self: self, k: k}
+}
+
+// Add inserts or refreshes the contact as the most recently seen in its bucket.
+// If the bucket is full the least recently seen contact is evicted unless it is still connected.
+// Contacts without address are only refreshed since they can't be dialed.
+// It returns true if the contact is in table after the call.
+func (t *table) Add(c contact, connected func(ID) bool) bool {
+	if c.ID == t.self {
+		return false
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	b := &t.buckets[commonPrefixLen(t.self, c.ID)]
+	for i, existing := range b.contacts {
+		if existing.ID != c.ID {
+			continue
+		}
+
+		// Move to the tail as the most recently seen.
+		if c.Addr == "" {
+			c.Addr = existing.Addr
+		}
+
+		b.contacts = append(append(b.contacts[:i:i], b.contacts[i+1:]...), c)
+		return true
+	}
+
+	if c.Addr == "" {
+		return false
+	}
+
+	if len(b.contacts) < t.k {
+		b.contacts = append(b.contacts, c)
+		return true
+	}
+
+	// Kademlia prefers long-lived contacts.
+	if connected(b.contacts[0].ID) {
+		return false
+	}
+
+	b.contacts = append(b.contacts[1:], c)
+	return true
+}
+
+// Remove deletes the contact from table.
+func (t *table) Remove(id ID) {
+	if id == t.self {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	b := &t.buckets[commonPrefixLen(t.self, id)]
+	for i, c := range b.contacts {
+		if c.ID == id {
+			b.contacts = append(b.contacts[:i:i], b.contacts[i+1:]...)
+			return
+		}
+	}
+}
+
+// Lookup returns the contact for id.
+func (t *table) Lookup(id ID) (contact, bool) {
+	if id == t.self {
+		return contact{}, false
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, c := range t.buckets[commonPrefixLen(t.self, id)].contacts {
+		if c.ID == id {
+			return c, true
+		}
+	}
+
+	return contact{}, false
+}
+
+// Closest returns up to count contacts ordered by distance to target.
+func (t *table) Closest(target ID, count int) []contact {
+	t.mu.Lock()
+	var contacts []contact
+	for _, b := range t.buckets {
+		contacts = append(contacts, b.contacts...)
+	}
+	t.mu.Unlock()
+
+	sortByDistance(target, contacts)
+	if len(contacts) > count {
+		contacts = contacts[:count]
+	}
+
+	return contacts
+}
+
+// Len returns the number of contacts in table.
+func (t *table) Len() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var n int
+	for _, b := range t.buckets {
+		n += len(b.contacts)
+	}
+
+	return n
+}
+
+// Refreshed marks the bucket for target as refreshed by a lookup.
+func (t *table) Refreshed(target ID) {
+	if target == t.self {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.buckets[commonPrefixLen(t.self, target)].refreshed = time.Now()
+}
+
+// Stale returns the buckets index without lookups during interval.
+// Only buckets up to the deepest populated bucket are considered, capped to maxRefreshCpl.
+func (t *table) Stale(interval time.Duration) []int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	deepest := -1
+	for i, b := range t.buckets {
+		if len(b.contacts) > 0 {
+			deepest = i
+		}
+	}
+
+	if deepest > maxRefreshCpl {
+		deepest = maxRefreshCpl
+	}
+
+	var stale []int
+	for i := 0; i <= deepest; i++ {
+		if time.Since(t.buckets[i].refreshed) >= interval {
+			stale = append(stale, i)
+		}
+	}
+
+	return stale
+}
+
+// sortByDistance orders contacts by distance to target, closest first.
+func sortByDistance(target ID, contacts []contact) {
+	sort.Slice(contacts, func(i, j int) bool {
+		return closer(target, contacts[i].ID, contacts[j].ID)
+	})
+}
+
+// dhtRequest is a request waiting for a response from a peer.
+type dhtRequest struct {
+	from ID
+	ch   chan dhtMessage
+}
+
+// dht holds the Kademlia state for node.
+type dht struct {
+	once         sync.Once
+	table        *table
+	started      atomic.Bool // refresh routine started
+	republishing atomic.Bool // republish routine started
+	nonce        atomic.Uint64
+	mu           sync.Mutex // guard pending requests and published records
+	pending      map[uint64]dhtRequest
+	published    map[ID]Record // records published by local node
+	dials        chan struct{} // in-flight contact dials
+}
+
+func newDHT() *dht {
+	return &dht{
+		pending:   make(map[uint64]dhtRequest),
+		published: make(map[ID]Record),
+		dials:     make(chan struct{}, maxLookupDials),
+	}
+}
+
+// routingTable returns the Kademlia routing table.
+// The table is created the first time it is needed since it depends on local ID.
+func (n *Node) routingTable() *table {
+	n.dht.once.Do(func() {
+		n.dht.table = newTable(n.ID(), n.config.DHTBucketSize())
+	})
+
+	return n.dht.table
+}
+
+// connected returns true if the peer is routed.
+func (n *Node) connected(id ID) bool {
+	_, ok := n.router.Query(id)
+	return ok
+}
+
+// advertisedAddr returns the listening address shared with remote peers.
+// If node is not listening an empty address is returned.
+func (n *Node) advertisedAddr() string {
+	if addr := n.LocalAddr(); addr != nil {
+		return addr.String()
+	}
+
+	return ""
+}
+
+// resolveAddr returns the dialable address for an advertised listening address.
+// Unspecified hosts eg. 0.0.0.0 or [::] are replaced by the remote connection IP.
+func resolveAddr(advertised string, remote net.Addr) string {
+	host, port, err := net.SplitHostPort(advertised)
+	if err != nil {
+		return ""
+	}
+
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		observed := hostIP(remote.String())
+		if observed == nil {
+			return ""
+		}
+
+		host = observed.String()
+	}
+
+	return net.JoinHostPort(host, port)
+}
+
+// discovered adds a new connected peer to the routing table and announces the local listening address.
+// The dialed address is known for outbound peers, inbound peers are added when their announcement arrives.
+// The first discovered peer starts the buckets refresh routine.
+func (n *Node) discovered(peer *peer, addr string) {
+	if peer.outbound {
+		n.routingTable().Add(contact{peer.ID(), addr}, n.connected)
+	}
+
+	ping := dhtMessage{Kind: dhtPing, Addr: n.advertisedAddr()}
+	if _, err := peer.send(dhtFrame, encodeDHT(ping)); err != nil {
+		log.Printf("error announcing to peer: %v", err)
+	}
+
+	interval := n.config.DHTRefreshInterval()
+	if interval > 0 && n.dht.started.CompareAndSwap(false, true) && n.track() {
+		go n.refreshLoop(interval)
+	}
+}
+
+// handleDHT process an incoming dht message from peer.
+// Any message refreshes the peer in routing table, requests are answered with the closest contacts to target.
+func (n *Node) handleDHT(peer *peer, msg []byte) {
+	m, err := decodeDHT(msg)
+	if err != nil {
+		log.Printf("error decoding dht message: %v", err)
+		return
+	}
+
+	addr := resolveAddr(m.Addr, peer.s.RemoteAddr())
+	t := n.routingTable()
+	// The listening address is announced once per connection.
+	// Announcements are not authenticated, the address is remembered once a dial proves it, see dialContact.
+	t.Add(contact{peer.ID(), addr}, n.connected)
+
+	switch m.Kind {
+	case dhtFindNode, dhtFindValue:
+		res := dhtMessage{Kind: dhtNodes, Nonce: m.Nonce, Addr: n.advertisedAddr()}
+		if m.Kind == dhtFindValue {
+			res.Value = n.localValue(m.Target)
+		}
+
+		if res.Value == nil {
+			// Never return the requester to itself.
+			for _, c := range t.Closest(m.Target, n.config.DHTBucketSize()+1) {
+				if c.ID != peer.ID() && len(res.Contacts) < n.config.DHTBucketSize() {
+					res.Contacts = append(res.Contacts, c)
+				}
+			}
+		}
+
+		if _, err := peer.send(dhtFrame, encodeDHT(res)); err != nil {
+			log.Printf("error answering dht request: %v", err)
+		}
+	case dhtStore:
+		res := dhtMessage{Kind: dhtStored, Nonce: m.Nonce, Addr: n.advertisedAddr()}
+		if err := n.storeRecord(m.Target, m.Value); err != nil {
+			log.Printf("record rejected: %v", err)
+			res.Error = err.Error()
+		}
+
+		if _, err := peer.send(dhtFrame, encodeDHT(res)); err != nil {
+			log.Printf("error answering dht request: %v", err)
+		}
+	case dhtNodes, dhtStored:
+		n.dht.mu.Lock()
+		req, ok := n.dht.pending[m.Nonce]
+		n.dht.mu.Unlock()
+		// Only the requested peer can answer.
+		if ok && req.from == peer.ID() {
+			select {
+			case req.ch <- m:
+			default:
+			}
+		}
+	}
+}
+
+// request sends a dht request to contact and waits for the response.
+// If the contact is not connected the contact address is dialed first, up to maxLookupDials contacts
+// are dialed at the same time. A connection opened only for requests is closed once no request uses it.
+// Unreachable contacts are removed from routing table.
+func (n *Node) request(ctx context.Context, c contact, req dhtMessage) (dhtMessage, error) {
+	peer, ok := n.router.Query(c.ID)
+	var opened bool
+	if !ok {
+		var err error
+		if peer, opened, err = n.dialContact(ctx, c); err != nil {
+			n.routingTable().Remove(c.ID)
+			return dhtMessage{}, err
+		}
+
+		// Another peer could be connected at the address, only a connection opened here is closed.
+		if peer.ID() != c.ID {
+			n.routingTable().Remove(c.ID)
+			if opened {
+				n.hangUp(peer)
+			}
+
+			return dhtMessage{}, errDHTRequest(fmt.Errorf("unexpected identity at %s", c.Addr))
+		}
+	}
+
+	peer.BeginRequest(opened)
+	defer func() {
+		if peer.EndRequest() {
+			n.hangUp(peer)
+		}
+	}()
+
+	pending := dhtRequest{c.ID, make(chan dhtMessage, 1)}
+	req.Nonce = n.dht.nonce.Add(1)
+	req.Addr = n.advertisedAddr()
+
+	n.dht.mu.Lock()
+	n.dht.pending[req.Nonce] = pending
+	n.dht.mu.Unlock()
+	defer func() {
+		n.dht.mu.Lock()
+		delete(n.dht.pending, req.Nonce)
+		n.dht.mu.Unlock()
+	}()
+
+	if _, err := peer.send(dhtFrame, encodeDHT(req)); err != nil {
+		return dhtMessage{}, err
+	}
+
+	timeout := time.NewTimer(dhtRequestTimeout)
+	defer timeout.Stop()
+
+	select {
+	case res := <-pending.ch:
+		return res, nil
+	case <-peer.Done():
+		return dhtMessage{}, errDHTRequest(errors.New("peer disconnected"))
+	case <-timeout.C:
+		return dhtMessage{}, errDHTRequest(errors.New("request timeout"))
+	case <-ctx.Done():
+		return dhtMessage{}, ctx.Err()
+	case <-n.done:
+		return dhtMessage{}, errNodeClosed()
+	}
+}
+
+// dialContact dials the contact address waiting for a free dial slot.
+// The contact address is remembered if the handshake proves the contact identity.
+// It returns true if the connection was opened by this call.
+func (n *Node) dialContact(ctx context.Context, c contact) (*peer, bool, error) {
+	select {
+	case n.dht.dials <- struct{}{}:
+	case <-ctx.Done():
+		return nil, false, ctx.Err()
+	case <-n.done:
+		return nil, false, errNodeClosed()
+	}
+
+	defer func() { <-n.dht.dials }()
+	peer, opened, err := n.connect(ctx, c.Addr)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if peer.ID() == c.ID {
+		n.rememberAddr(c.ID, c.Addr, SourceDHT)
+	}
+
+	return peer, opened, nil
+}
+
+// confirmContact dials the contact address and returns true if the handshake proves the contact identity.
+// A connection opened only for the confirmation is closed.
+func (n *Node) confirmContact(ctx context.Context, c contact) bool {
+	peer, opened, err := n.dialContact(ctx, c)
+	if err != nil {
+		log.Printf("error confirming dht contact: %v", err)
+		return false
+	}
+
+	peer.BeginRequest(opened)
+	if peer.EndRequest() {
+		n.hangUp(peer)
+	}
+
+	return peer.ID() == c.ID
+}
+
+// hangUp closes a connection opened only for a request, eg. a dht request or an unexpected exchanged peer.
+// Protected peers and connections used beyond requests are kept connected.
+func (n *Node) hangUp(peer *peer) {
+	if n.manager.Protected(peer.ID()) || peer.Kept() {
+		return
+	}
+
+	if err := n.ClosePeer(peer.ID().String(), ReasonLocalClose); err != nil {
+		log.Printf("error hanging up peer: %v", err)
+	}
+}
+
+// lookup runs an iterative Kademlia lookup for target.
+// Up to DHTConcurrency contacts are queried at the same time, starting from the closest known contacts
+// and following the closer contacts returned until the DHTBucketSize closest contacts were queried.
+// For dhtFindNode lookups it stops as soon as the target peer is found at an address proved by handshake,
+// for dhtFindValue lookups it stops as soon as a valid record is found.
+// It returns the closest contacts answering the lookup and the value if found.
+func (n *Node) lookup(ctx context.Context, target ID, kind dhtKind) ([]contact, []byte) {
+	k, alpha := n.config.DHTBucketSize(), n.config.DHTConcurrency()
+	t := n.routingTable()
+	t.Refreshed(target)
+
+	shortlist := t.Closest(target, k)
+	seen := map[ID]bool{n.ID(): true}
+	queried := make(map[ID]bool)
+	confirmed := make(map[string]bool) // target addresses already dialed
+	for _, c := range shortlist {
+		seen[c.ID] = true
+	}
+
+	type result struct {
+		c   contact
+		res dhtMessage
+		err error
+	}
+
+	for ctx.Err() == nil {
+		var batch []contact
+		for _, c := range shortlist {
+			if !queried[c.ID] && len(batch) < alpha {
+				batch = append(batch, c)
+			}
+		}
+
+		if len(batch) == 0 {
+			break
+		}
+
+		results := make(chan result, len(batch))
+		for _, c := range batch {
+			queried[c.ID] = true
+			go func(c contact) {
+				res, err := n.request(ctx, c, dhtMessage{Kind: kind, Target: target})
+				results <- result{c, res, err}
+			}(c)
+		}
+
+		failed := make(map[ID]bool)
+		for range batch {
+			r := <-results
+			if r.err != nil {
+				log.Printf("dht request failed: %v", r.err)
+				failed[r.c.ID] = true
+				continue
+			}
+
+			// Invalid values are ignored, a peer can't stop the lookup with a forged record.
+			if kind == dhtFindValue && r.res.Value != nil {
+				if _, err := checkRecord(target, r.res.Value); err == nil {
+					return shortlist, r.res.Value
+				}
+
+				log.Printf("invalid dht value from peer: %x", r.c.ID.Bytes())
+			}
+
+			for _, c := range r.res.Contacts {
+				// The target peer was found, no need to ask it for closer peers.
+				// Any peer could answer with a forged address, keep looking until the identity is proved.
+				if kind == dhtFindNode && c.ID == target && c.Addr != "" && !confirmed[c.Addr] {
+					confirmed[c.Addr] = true
+					if n.confirmContact(ctx, c) {
+						return []contact{c}, nil
+					}
+
+					continue
+				}
+
+				if !seen[c.ID] && c.Addr != "" {
+					seen[c.ID] = true
+					shortlist = append(shortlist, c)
+				}
+			}
+		}
+
+		// Keep the k closest responsive contacts.
+		alive := shortlist[:0]
+		for _, c := range shortlist {
+			if !failed[c.ID] {
+				alive = append(alive, c)
+			}
+		}
+
+		shortlist = alive
+		sortByDistance(target, shortlist)
+		if len(shortlist) > k {
+			shortlist = shortlist[:k]
+		}
+	}
+
+	return shortlist, nil
+}
+
+// refresh runs a lookup for the local ID to find the closest neighbours and
+// a lookup for a random ID in each bucket without lookups during interval.
+func (n *Node) refresh(ctx context.Context, interval time.Duration) {
+	n.lookup(ctx, n.ID(), dhtFindNode)
+	for _, cpl := range n.routingTable().Stale(interval) {
+		n.lookup(ctx, randomIDWithCpl(n.ID(), cpl), dhtFindNode)
+	}
+}
+
+// refreshLoop keeps the routing table fresh until node is shutting down.
+func (n *Node) refreshLoop(interval time.Duration) {
+	defer n.wg.Done()
+	ctx, cancel := n.background()
+	defer cancel()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		n.refresh(ctx, interval)
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// FindPeer returns the addresses for peer ID using the Kademlia DHT.
+// Known peers are returned from the local routing table, otherwise an iterative lookup is started
+// asking the closest known peers for closer peers until the peer is found.
+// It returns an error if the peer can't be found or the context is canceled.
+func (n *Node) FindPeer(ctx context.Context, rawID string) ([]string, error) {
+	id := newIDFromString(rawID)
+	t := n.routingTable()
+	if c, ok := t.Lookup(id); ok {
+		return []string{c.Addr}, nil
+	}
+
+	contacts, _ := n.lookup(ctx, id, dhtFindNode)
+	for _, c := range contacts {
+		if c.ID == id {
+			return []string{c.Addr}, nil
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return nil, errPeerNotFound(id)
+}
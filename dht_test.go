@@ -0,0 +1,281 @@
+package noise
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/geolffreym/p2p-noise/config"
+)
+
+func TestCommonPrefixLen(t *testing.T) {
+	var self ID
+	for cpl := 0; cpl < len(self)*8; cpl += 7 {
+		id := randomIDWithCpl(self, cpl)
+		if commonPrefixLen(self, id) != cpl {
+			t.Errorf("expected common prefix length %d, got %d", cpl, commonPrefixLen(self, id))
+		}
+	}
+
+	if commonPrefixLen(self, self) != len(self)*8 {
+		t.Errorf("expected full common prefix for same ID")
+	}
+}
+
+func TestTableClosest(t *testing.T) {
+	var self ID
+	table := newTable(self, 2)
+	connected := func(ID) bool { return false }
+
+	contacts := []struct {
+		id       byte
+		expected bool
+	}{
+		{0x80, true},  // bucket 0
+		{0x90, true},  // bucket 0
+		{0xa0, true},  // bucket 0 full, least recently seen 0x80 evicted
+		{0x01, true},  // bucket 7
+		{0x02, true},  // bucket 6
+		{0x00, false}, // self
+	}
+
+	for _, e := range contacts {
+		c := contact{ID{e.id}, MOCK_ADDRESS}
+		if table.Add(c, connected) != e.expected {
+			t.Errorf("expected add %x = %v", e.id, e.expected)
+		}
+	}
+
+	if _, ok := table.Lookup(ID{0x80}); ok {
+		t.Errorf("expected least recently seen contact evicted from full bucket")
+	}
+
+	closest := table.Closest(ID{0x03}, 3)
+	expected := []ID{{0x02}, {0x01}, {0x90}}
+	for i, c := range closest {
+		if c.ID != expected[i] {
+			t.Errorf("expected contact %x at %d, got %x", expected[i][0], i, c.ID[0])
+		}
+	}
+
+	// Connected contacts are never evicted.
+	table.Add(contact{ID{0xb0}, MOCK_ADDRESS}, func(ID) bool { return true })
+	if _, ok := table.Lookup(ID{0xb0}); ok {
+		t.Errorf("expected new contact dropped when least recently seen is connected")
+	}
+
+	// Contacts without address can't be added.
+	if table.Add(contact{ID{0x04}, ""}, connected) {
+		t.Errorf("expected contact without address dropped")
+	}
+
+	table.Remove(ID{0x01})
+	if table.Len() != 3 {
+		t.Errorf("expected 3 contacts after remove, got %d", table.Len())
+	}
+}
+
+func TestResolveAddr(t *testing.T) {
+	remote := &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 5000}
+	cases := []struct {
+		advertised string
+		expected   string
+	}{
+		{"127.0.0.1:2379", "127.0.0.1:2379"},
+		{"[::]:2379", "10.0.0.1:2379"},
+		{"0.0.0.0:2379", "10.0.0.1:2379"},
+		{":2379", "10.0.0.1:2379"},
+		{"", ""},
+	}
+
+	for _, e := range cases {
+		if addr := resolveAddr(e.advertised, remote); addr != e.expected {
+			t.Errorf("expected %q for %q, got %q", e.expected, e.advertised, addr)
+		}
+	}
+}
+
+func TestFindPeer(t *testing.T) {
+	nodes := make([]*Node, 3)
+	for i := range nodes {
+		configuration := config.New()
+		// No refresh lookups dialing other nodes.
+		configuration.Write(
+			config.SetSelfListeningAddress("127.0.0.1:"),
+			config.SetDHTRefreshInterval(0),
+		)
+		nodes[i] = New(configuration)
+		defer nodes[i].Close()
+		<-whenReadyForIncomingDial(nodes[i])
+	}
+
+	nodeA, nodeB, nodeC := nodes[0], nodes[1], nodes[2]
+	// A and C only know B.
+	if err := nodeA.Dial(nodeB.LocalAddr().String()); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := nodeC.Dial(nodeB.LocalAddr().String()); err != nil {
+		t.Fatal(err)
+	}
+
+	timeout := time.After(2 * time.Second)
+	for _, ok := nodeB.routingTable().Lookup(nodeC.ID()); !ok; _, ok = nodeB.routingTable().Lookup(nodeC.ID()) {
+		select {
+		case <-timeout:
+			t.Fatalf("expected C announced to B")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	addrs, err := nodeA.FindPeer(ctx, nodeC.ID().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(addrs) != 1 || addrs[0] != nodeC.LocalAddr().String() {
+		t.Errorf("expected address %s, got %v", nodeC.LocalAddr(), addrs)
+	}
+
+	var unknown ID
+	unknown[0] = 0xff
+	if _, err := nodeA.FindPeer(ctx, unknown.String()); err == nil {
+		t.Errorf("expected error finding unknown peer")
+	}
+
+	// C was dialed only to ask for closer peers.
+	if nodeA.connected(nodeC.ID()) {
+		t.Errorf("expected connection opened for lookup closed")
+	}
+}
+
+func TestRequestKeepsConnectedPeer(t *testing.T) {
+	nodes := make([]*Node, 2)
+	for i := range nodes {
+		configuration := config.New()
+		configuration.Write(
+			config.SetSelfListeningAddress("127.0.0.1:"),
+			config.SetDHTRefreshInterval(0),
+		)
+		nodes[i] = New(configuration)
+		defer nodes[i].Close()
+		<-whenReadyForIncomingDial(nodes[i])
+	}
+
+	nodeA, nodeB := nodes[0], nodes[1]
+	if err := nodeA.Dial(nodeB.LocalAddr().String()); err != nil {
+		t.Fatal(err)
+	}
+
+	// A contact pairing a fake identity with the address of a connected peer.
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	fake := contact{ID{0xff}, nodeB.LocalAddr().String()}
+	if _, err := nodeA.request(ctx, fake, dhtMessage{Kind: dhtFindNode, Target: ID{1}}); err == nil {
+		t.Errorf("expected error for unexpected identity")
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if !nodeA.connected(nodeB.ID()) {
+		t.Errorf("expected connected peer kept")
+	}
+}
+
+func TestFindPeerForgedAddress(t *testing.T) {
+	nodes := make([]*Node, 3)
+	for i := range nodes {
+		configuration := config.New()
+		configuration.Write(
+			config.SetSelfListeningAddress("127.0.0.1:"),
+			config.SetDHTRefreshInterval(0),
+		)
+		nodes[i] = New(configuration)
+		defer nodes[i].Close()
+		<-whenReadyForIncomingDial(nodes[i])
+	}
+
+	nodeA, nodeB, nodeC := nodes[0], nodes[1], nodes[2]
+	if err := nodeA.Dial(nodeB.LocalAddr().String()); err != nil {
+		t.Fatal(err)
+	}
+
+	// B answers with the address of C for an unknown target.
+	var target ID
+	target[0] = 0xff
+	nodeB.routingTable().Add(contact{target, nodeC.LocalAddr().String()}, func(ID) bool { return true })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if addrs, err := nodeA.FindPeer(ctx, target.String()); err == nil {
+		t.Errorf("expected forged address rejected, got %v", addrs)
+	}
+}
+
+func TestAnnouncedAddressVerified(t *testing.T) {
+	nodes := make([]*Node, 3)
+	for i := range nodes {
+		configuration := config.New()
+		configuration.Write(
+			config.SetSelfListeningAddress("127.0.0.1:"),
+			config.SetDHTRefreshInterval(0),
+		)
+		nodes[i] = New(configuration)
+		defer nodes[i].Close()
+	}
+
+	nodeA, nodeB, nodeC := nodes[0], nodes[1], nodes[2]
+	signalsA, cancel := nodeA.Signals()
+	defer cancel()
+
+	go nodeA.Listen()
+	if _, ok := waitFor(signalsA, SelfListening, 2*time.Second); !ok {
+		t.Fatalf("expected node listening")
+	}
+
+	<-whenReadyForIncomingDial(nodeB)
+	<-whenReadyForIncomingDial(nodeC)
+	if err := nodeB.Dial(nodeA.LocalAddr().String()); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := waitFor(signalsA, NewPeerDetected, 2*time.Second); !ok {
+		t.Fatalf("expected inbound peer detected")
+	}
+
+	// B announces the address where C is listening.
+	forged := nodeC.LocalAddr().String()
+	peerB, _ := nodeA.router.Query(nodeB.ID())
+	nodeA.handleDHT(peerB, encodeDHT(dhtMessage{Kind: dhtPing, Addr: forged}))
+
+	remembered := func(addr string) bool {
+		info, _ := nodeA.PeerInfo(nodeB.ID().String())
+		for _, a := range info.Addrs {
+			if a.Addr == addr {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	if remembered(forged) {
+		t.Errorf("expected announced address not remembered before handshake")
+	}
+
+	ctx, cancelDial := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancelDial()
+
+	// A dial proves the identity of B only at its own address.
+	for _, addr := range []string{forged, nodeB.LocalAddr().String()} {
+		if _, _, err := nodeA.dialContact(ctx, contact{nodeB.ID(), addr}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if remembered(forged) || !remembered(nodeB.LocalAddr().String()) {
+		t.Errorf("expected only the address proved by handshake remembered")
+	}
+}
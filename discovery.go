@@ -0,0 +1,238 @@
+package noise
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"errors"
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+// discoveryMagic prefixes announcements to ignore unrelated traffic in the multicast group.
+var discoveryMagic = []byte("p2p-noise/1")
+
+// maxAnnouncementSize is the max size for announcements read from the multicast group.
+const maxAnnouncementSize = 512
+
+// maxDiscoveryDials is the number of discovered peers dialed concurrently.
+const maxDiscoveryDials = 8
+
+// maxDiscoveredPeers is the number of discovered peers dialed every discovery interval.
+const maxDiscoveredPeers = 64
+
+// maxDiscoveredPerSource is the number of discovered peers dialed every discovery interval
+// for announcements received from the same source address.
+const maxDiscoveredPerSource = 4
+
+// announcement is sent periodically to the multicast group to be discovered by peers in the local network.
+type announcement struct {
+	ID   ID
+	Addr string // listening address
+}
+
+// encodeAnnouncement encode an announcement prefixed by the discovery magic.
+func encodeAnnouncement(a announcement) []byte {
+	buffer := bytes.NewBuffer(append([]byte{}, discoveryMagic...))
+	gob.NewEncoder(buffer).Encode(a)
+	return buffer.Bytes()
+}
+
+// decodeAnnouncement decode an announcement received from the multicast group.
+func decodeAnnouncement(b []byte) (announcement, error) {
+	var a announcement
+	if !bytes.HasPrefix(b, discoveryMagic) {
+		return a, errors.New("unknown announcement")
+	}
+
+	err := gob.NewDecoder(bytes.NewReader(b[len(discoveryMagic):])).Decode(&a)
+	return a, err
+}
+
+// listenDiscovery joins the multicast group and returns the connections used to receive and send announcements.
+// If the interface name is empty the system default interface is used.
+func listenDiscovery(group, name string) (*net.UDPConn, *net.UDPConn, error) {
+	addr, err := net.ResolveUDPAddr("udp", group)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var ifi *net.Interface
+	if name != "" {
+		if ifi, err = net.InterfaceByName(name); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	conn, err := net.ListenMulticastUDP("udp", ifi, addr)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sender, err := net.DialUDP("udp", nil, addr)
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+
+	return conn, sender, nil
+}
+
+// discovered tracks the peers dialed from announcements during the current discovery interval.
+// Each peer is dialed at most once every interval and the dials for each source address are limited.
+type discovered struct {
+	interval time.Duration
+	start    time.Time
+	dialed   map[ID]time.Time
+	sources  map[string]int
+}
+
+// newDiscovered creates a new tracker for discovery interval.
+func newDiscovered(interval time.Duration) *discovered {
+	return &discovered{
+		interval: interval,
+		start:    time.Now(),
+		dialed:   make(map[ID]time.Time),
+		sources:  make(map[string]int),
+	}
+}
+
+// Accept returns true and records the dial if peer ID announced from source should be dialed.
+func (d *discovered) Accept(id ID, source string) bool {
+	now := time.Now()
+	if now.Sub(d.start) >= d.interval {
+		d.prune(now)
+	}
+
+	if now.Sub(d.dialed[id]) < d.interval {
+		return false
+	}
+
+	if len(d.dialed) >= maxDiscoveredPeers || d.sources[source] >= maxDiscoveredPerSource {
+		return false
+	}
+
+	d.dialed[id] = now
+	d.sources[source]++
+	return true
+}
+
+// prune drops the peers dialed before the last interval and starts a new interval.
+func (d *discovered) prune(now time.Time) {
+	for id, last := range d.dialed {
+		if now.Sub(last) >= d.interval {
+			delete(d.dialed, id)
+		}
+	}
+
+	d.sources = make(map[string]int)
+	d.start = now
+}
+
+// discover announces the local node to the multicast group every discovery interval
+// and dials the peers announced by other nodes until the context is canceled or the node is shutting down.
+func (n *Node) discover(ctx context.Context, group string) {
+	defer n.wg.Done()
+	conn, sender, err := listenDiscovery(group, n.config.DiscoveryInterface())
+	if err != nil {
+		log.Printf("error joining discovery group %s: %v", group, err)
+		return
+	}
+
+	defer sender.Close()
+	received := make(chan struct{})
+	go func() {
+		defer close(received)
+		n.receiveAnnouncements(ctx, conn)
+	}()
+
+	ticker := time.NewTicker(n.config.DiscoveryInterval())
+	defer ticker.Stop()
+
+	self := announcement{n.ID(), n.advertisedAddr()}
+	for {
+		if _, err := sender.Write(encodeAnnouncement(self)); err != nil {
+			log.Printf("error sending announcement: %v", err)
+		}
+
+		select {
+		case <-ticker.C:
+			continue
+		case <-ctx.Done():
+		case <-n.done:
+		}
+
+		// Closing the connection unblocks the receiving routine.
+		conn.Close()
+		<-received
+		return
+	}
+}
+
+// receiveAnnouncements reads the announcements from multicast group and dials the new discovered peers.
+// A PeerDiscovered signal is emitted for every discovered peer not connected.
+// Each peer is dialed at most once every discovery interval and up to maxDiscoveryDials peers are dialed concurrently.
+func (n *Node) receiveAnnouncements(ctx context.Context, conn *net.UDPConn) {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	tracker := newDiscovered(n.config.DiscoveryInterval())
+	dialing := make(chan struct{}, maxDiscoveryDials)
+	buffer := make([]byte, maxAnnouncementSize)
+	for {
+		size, from, err := conn.ReadFromUDP(buffer)
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				log.Printf("error reading announcement: %v", err)
+			}
+
+			return
+		}
+
+		a, err := decodeAnnouncement(buffer[:size])
+		if err != nil || a.ID == n.ID() || n.connected(a.ID) {
+			continue
+		}
+
+		addr := resolveAddr(a.Addr, from)
+		if addr == "" {
+			continue
+		}
+
+		// Skip the announcement while busy, the peer is announced again next interval.
+		select {
+		case dialing <- struct{}{}:
+		default:
+			continue
+		}
+
+		if !tracker.Accept(a.ID, from.IP.String()) {
+			<-dialing
+			continue
+		}
+
+		log.Printf("peer discovered in local network: %s", addr)
+		n.events.PeerDiscovered(addr)
+
+		wg.Add(1)
+		go func(a announcement) {
+			defer wg.Done()
+			defer func() { <-dialing }()
+			peer, err := n.dial(ctx, addr)
+			if err != nil {
+				log.Printf("error dialing discovered peer: %v", err)
+				return
+			}
+
+			// Announcements are not authenticated, the handshake is.
+			if peer.ID() != a.ID {
+				log.Printf("discovered peer identity mismatch at %s", addr)
+				return
+			}
+
+			n.rememberAddr(a.ID, addr, SourceLAN)
+		}(a)
+	}
+}
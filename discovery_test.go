@@ -0,0 +1,104 @@
+package noise
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/geolffreym/p2p-noise/config"
+)
+
+func TestAnnouncementEncoding(t *testing.T) {
+	a := announcement{peerA.ID(), MOCK_ADDRESS}
+	decoded, err := decodeAnnouncement(encodeAnnouncement(a))
+	if err != nil || decoded != a {
+		t.Errorf("expected announcement decoded, got %v", err)
+	}
+
+	if _, err := decodeAnnouncement([]byte("unrelated traffic")); err == nil {
+		t.Errorf("expected error decoding unrelated traffic")
+	}
+}
+
+func TestDiscoveredLimits(t *testing.T) {
+	d := newDiscovered(time.Minute)
+	if !d.Accept(ID{1}, "10.0.0.1") || d.Accept(ID{1}, "10.0.0.2") {
+		t.Errorf("expected peer dialed once every interval")
+	}
+
+	for i := 2; i <= maxDiscoveredPerSource; i++ {
+		if !d.Accept(ID{byte(i)}, "10.0.0.1") {
+			t.Fatalf("expected peer %d accepted", i)
+		}
+	}
+
+	if d.Accept(ID{0xff}, "10.0.0.1") {
+		t.Errorf("expected max %d peers dialed from same source", maxDiscoveredPerSource)
+	}
+
+	for i := 0; i < maxDiscoveredPeers; i++ {
+		d.Accept(ID{byte(i), 1}, fmt.Sprintf("10.0.1.%d", i))
+	}
+
+	if len(d.dialed) != maxDiscoveredPeers {
+		t.Errorf("expected max %d peers dialed every interval, got %d", maxDiscoveredPeers, len(d.dialed))
+	}
+
+	// A new interval forgets the previous dials.
+	d.start = d.start.Add(-time.Minute)
+	for id := range d.dialed {
+		d.dialed[id] = d.dialed[id].Add(-time.Minute)
+	}
+
+	if !d.Accept(ID{1}, "10.0.0.1") || len(d.dialed) != 1 {
+		t.Errorf("expected dialed peers pruned after interval, got %d", len(d.dialed))
+	}
+}
+
+func TestLocalDiscovery(t *testing.T) {
+	// A random port avoids mixing announcements with other nodes in the network.
+	group := fmt.Sprintf("239.255.42.99:%d", 20000+rand.Intn(20000))
+	nodes := make([]*Node, 2)
+	signals := make([]<-chan Signal, 2)
+	for i := range nodes {
+		configuration := config.New()
+		configuration.Write(
+			config.SetSelfListeningAddress("127.0.0.1:"),
+			config.SetDiscoveryAddress(group),
+			config.SetDiscoveryInterval(100*time.Millisecond),
+		)
+
+		nodes[i] = New(configuration)
+		defer nodes[i].Close()
+		var cancel func()
+		signals[i], cancel = nodes[i].Signals()
+		defer cancel()
+		go nodes[i].Listen()
+	}
+
+	timeout := time.After(3 * time.Second)
+	for nodes[0].router.Len() != 1 || nodes[1].router.Len() != 1 {
+		select {
+		case <-timeout:
+			t.Skip("multicast not available in this network")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	// Only one side dials when the other side is discovered first.
+	for {
+		select {
+		case signal := <-signals[0]:
+			if signal.Type() == PeerDiscovered && signal.Payload() == nodes[1].LocalAddr().String() {
+				return
+			}
+		case signal := <-signals[1]:
+			if signal.Type() == PeerDiscovered && signal.Payload() == nodes[0].LocalAddr().String() {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("expected discovery signal")
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package noise
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+)
+
+// [Envelope] is a message signed and encoded once to be sent to many peers.
+// Only the session encryption is done for each peer.
+// Please see [Node.Seal] to create an envelope.
+type Envelope struct {
+	packed []byte
+}
+
+// Len returns the size of encoded message in bytes.
+func (e Envelope) Len() int {
+	return len(e.packed)
+}
+
+// Seal signs the message with the node identity and encodes it once to be sent with [Node.SendEnvelope].
+// It returns an error if the identity key is invalid.
+func (n *Node) Seal(message []byte) (Envelope, error) {
+	kr, err := n.keyRing()
+	if err != nil {
+		return Envelope{}, err
+	}
+
+	// Every session signs with the node identity, the signed packet is the same for every peer.
+	sig := ed25519.Sign(kr.sv.Private, message)
+	packed := marshall(packet{sig, message, dataFrame, 0})
+	return Envelope{packed.Bytes()}, nil
+}
+
+// SendEnvelope sends a sealed message to peer encrypting it with the peer session keys.
+// If the peer ID doesn't exist or the peer is not connected, it returns an error.
+func (n *Node) SendEnvelope(rawID string, envelope Envelope) (uint32, error) {
+	if envelope.Len() == 0 {
+		return 0, errSendingMessage(errors.New("empty envelope"))
+	}
+
+	id := newIDFromString(rawID)
+	peer, ok := n.router.Query(id)
+	if !ok {
+		err := fmt.Errorf("remote peer disconnected: %s", id.String())
+		return 0, errSendingMessage(err)
+	}
+
+	bytes, err := peer.sendPacked(envelope.packed)
+	if err != nil {
+		return 0, err
+	}
+
+	// An idle timeout can be implemented by repeatedly extending
+	// the deadline after successful Read or Write calls.
+	idle := futureDeadLine(n.config.IdleTimeout())
+	peer.SetDeadline(idle)
+	return bytes, nil
+}
@@ -0,0 +1,59 @@
+package noise
+
+import (
+	"testing"
+	"time"
+
+	"github.com/geolffreym/p2p-noise/config"
+)
+
+func TestSendEnvelope(t *testing.T) {
+	configurationA := config.New()
+	configurationB := config.New()
+	configurationA.Write(config.SetSelfListeningAddress("127.0.0.1:"))
+	configurationB.Write(config.SetSelfListeningAddress("127.0.0.1:"))
+
+	nodeA := New(configurationA)
+	nodeB := New(configurationB)
+	defer nodeA.Close()
+	defer nodeB.Close()
+
+	<-whenReadyForIncomingDial(nodeA)
+	signalsB, cancel := nodeB.Signals()
+	defer cancel()
+	go nodeB.Listen()
+
+	if _, ok := waitFor(signalsB, SelfListening, 2*time.Second); !ok {
+		t.Fatalf("expected node listening")
+	}
+
+	if err := nodeA.Dial(nodeB.LocalAddr().String()); err != nil {
+		t.Fatal(err)
+	}
+
+	envelope, err := nodeA.Seal([]byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := nodeA.SendEnvelope(nodeB.ID().String(), envelope); err != nil {
+		t.Fatal(err)
+	}
+
+	received, ok := waitFor(signalsB, MessageReceived, 2*time.Second)
+	if !ok {
+		t.Fatalf("expected sealed message received")
+	}
+
+	if received.Payload() != "hello" {
+		t.Errorf("expected sealed message, got %q", received.Payload())
+	}
+
+	if _, err := nodeA.SendEnvelope(nodeB.ID().String(), Envelope{}); err == nil {
+		t.Errorf("expected error sending empty envelope")
+	}
+
+	if _, err := nodeA.SendEnvelope(ID{1}.String(), envelope); err == nil {
+		t.Errorf("expected error sending envelope to unknown peer")
+	}
+}
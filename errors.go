@@ -1,6 +1,7 @@
 package noise
 
 import (
+	"crypto/ed25519"
 	"errors"
 	"fmt"
 )
@@ -16,6 +17,11 @@ func (e NetError) Error() string {
 	return fmt.Sprintf("net: %s -> %v", e.Context, e.Err)
 }
 
+// Unwrap returns the underlying error.
+func (e NetError) Unwrap() error {
+	return e.Err
+}
+
 // [OperationalError] represents an error that occurred when an operation in node failed.
 // eg. Send a new message to invalid or not connected peer.
 // eg. Error during Handshake.
@@ -29,6 +35,11 @@ func (e OperationalError) Error() string {
 	return fmt.Sprintf("ops: %s -> %v", e.Context, e.Err)
 }
 
+// Unwrap returns the underlying error.
+func (e OperationalError) Unwrap() error {
+	return e.Err
+}
+
 // [OverflowError] error represents a problem with the maximum setting of a parameter being exceeded.
 // eg. MaxPeersConnected exceeded for incoming connections.
 type OverflowError struct {
@@ -41,6 +52,11 @@ func (e OverflowError) Error() string {
 	return fmt.Sprintf("overflow: %s -> %v", e.Context, e.Err)
 }
 
+// Unwrap returns the underlying error.
+func (e OverflowError) Unwrap() error {
+	return e.Err
+}
+
 // [SecError] represents errors related to network security.
 type SecError struct {
 	Context string
@@ -52,10 +68,70 @@ func (e SecError) Error() string {
 	return fmt.Sprintf("sec: %s -> %v", e.Context, e.Err)
 }
 
+// Unwrap returns the underlying error.
+func (e SecError) Unwrap() error {
+	return e.Err
+}
+
 func errVerifyingSignature(err error) error {
 	return &SecError{"error verifying signature", err}
 }
 
+// errDecryptingMessage error represent a message that cannot be authenticated with the session keys.
+func errDecryptingMessage(err error) error {
+	return &SecError{"error decrypting message", err}
+}
+
+// errDecodingMessage error represent an authenticated message with invalid encoding.
+func errDecodingMessage(err error) error {
+	return &SecError{"error decoding message", err}
+}
+
+// errBannedPeer error represent a connection with a banned peer or address.
+func errBannedPeer(err error) error {
+	return &SecError{"peer is banned", err}
+}
+
+// errInvalidPublicKey error represent a remote public key with invalid size received during handshake.
+func errInvalidPublicKey(size int) error {
+	return &SecError{"invalid remote public key", fmt.Errorf("expected %d bytes, got %d", ed25519.PublicKeySize, size)}
+}
+
+// errKeyChanged error represent a dialed address answered by a different identity than the pinned one.
+func errKeyChanged(addr string) error {
+	return &SecError{"remote peer identity changed", fmt.Errorf("possible impersonation of %s", addr)}
+}
+
+// errUnknownPeer error represent a dialed address without pinned identity in strict trust mode.
+func errUnknownPeer(addr string) error {
+	return &SecError{"unknown peer", fmt.Errorf("no pinned identity for %s", addr)}
+}
+
+// errInvalidIdentityProof error represent a remote signature key not bound to the handshake static key.
+func errInvalidIdentityProof() error {
+	return &SecError{"invalid identity proof", errors.New("static key not signed by remote signature key")}
+}
+
+// errInvalidIdentityKey error represent a local identity key with invalid size.
+func errInvalidIdentityKey(size int) error {
+	return &OperationalError{"invalid identity key", fmt.Errorf("expected %d bytes, got %d", ed25519.PrivateKeySize, size)}
+}
+
+// errCertificateWithoutIdentity error represent a local certificate configured without the identity key it certifies.
+func errCertificateWithoutIdentity() error {
+	return &OperationalError{"invalid identity key", errors.New("certificate set without identity key")}
+}
+
+// errInvalidCertificate error represent a remote peer certificate rejected by verifier.
+func errInvalidCertificate(err error) error {
+	return &SecError{"invalid peer certificate", err}
+}
+
+// errConnectionGated error represent a connection rejected by connection gater.
+func errConnectionGated(err error) error {
+	return &SecError{"connection not allowed", err}
+}
+
 // errDialingNode error represent an issue trying to dial a node address.
 func errDialingNode(err error) error {
 	return &NetError{"error during dialing", err}
@@ -66,8 +142,16 @@ func errBindingConnection(err error) error {
 	return &NetError{"connection closed or cannot be established", err}
 }
 
+// errOversizedMessage error represent an incoming message bigger than max buffer size.
+func errOversizedMessage(size, max int) error {
+	return &OverflowError{
+		fmt.Sprintf("message size %d exceeds max size %d", size, max),
+		errors.New("oversized message"),
+	}
+}
+
 // errExceededMaxPeers error represent an issue if number of active connections exceed max peer connected.
-func errExceededMaxPeers(max uint8) error {
+func errExceededMaxPeers(max uint32) error {
 	return &OverflowError{
 		fmt.Sprintf("it is not possible to accept more than %d connections", max),
 		errors.New("max peers exceeded"),
@@ -84,7 +168,87 @@ func errSendingMessage(err error) error {
 	return &OperationalError{"error sending message", err}
 }
 
+// errClosingPeer error represent an issue trying to close a peer connection.
+func errClosingPeer(err error) error {
+	return &OperationalError{"error closing peer", err}
+}
+
+// errNodeClosed error represent an issue trying to use a node after shutdown.
+func errNodeClosed() error {
+	return &OperationalError{"node is closed", errors.New("node shutting down")}
+}
+
 // errDuringHandshake error represent an issue during handshake with peer.
 func errDuringHandshake(err error) error {
 	return &OperationalError{"error during handshake", err}
 }
+
+// errPeerNotFound error represent a peer not found in the DHT.
+func errPeerNotFound(id ID) error {
+	return &OperationalError{"peer not found", fmt.Errorf("no address known for %x", id.Bytes())}
+}
+
+// errDHTRequest error represent a DHT request without response.
+func errDHTRequest(err error) error {
+	return &OperationalError{"error during dht request", err}
+}
+
+// errInvalidRecord error represent a DHT record rejected by validation.
+func errInvalidRecord(err error) error {
+	return &SecError{"invalid record", err}
+}
+
+// errRecordNotFound error represent a DHT record not found.
+func errRecordNotFound(key string) error {
+	return &OperationalError{"record not found", fmt.Errorf("no valid record for key %q", key)}
+}
+
+// errRecordLimit error represent a DHT record rejected by the record store limits.
+func errRecordLimit(err error) error {
+	return &OperationalError{"record store limit reached", err}
+}
+
+// errRecordNotStored error represent a DHT record not stored by any peer.
+func errRecordNotStored(err error) error {
+	return &OperationalError{"record not stored", err}
+}
+
+// errInvalidTopic error represent an invalid pubsub topic name.
+func errInvalidTopic(topic string) error {
+	return &OperationalError{"invalid topic", fmt.Errorf("topic length %d must be between 1 and %d", len(topic), maxTopicLength)}
+}
+
+// errTopicJoined error represent a pubsub topic already joined.
+func errTopicJoined(topic string) error {
+	return &OperationalError{"topic already joined", fmt.Errorf("topic %q", topic)}
+}
+
+// errTopicClosed error represent a publish attempt in a left pubsub topic.
+func errTopicClosed(topic string) error {
+	return &OperationalError{"topic closed", fmt.Errorf("topic %q was left", topic)}
+}
+
+// errInvalidMessage error represent a pubsub message rejected by validation.
+func errInvalidMessage(err error) error {
+	return &SecError{"invalid pubsub message", err}
+}
+
+// errInvalidTTL error represent a broadcast hop limit out of range.
+func errInvalidTTL(ttl uint8) error {
+	return &OperationalError{"invalid ttl", fmt.Errorf("ttl %d must be between 1 and %d", ttl, maxBroadcastTTL)}
+}
+
+// errInvalidBroadcast error represent a broadcast message rejected by validation.
+func errInvalidBroadcast(err error) error {
+	return &SecError{"invalid broadcast", err}
+}
+
+// errNoRoute error represent a forwarded message without next hop to destination.
+func errNoRoute(id ID) error {
+	return &OperationalError{"no route to peer", fmt.Errorf("no connected peer to forward to %x", id.Bytes())}
+}
+
+// errInvalidForward error represent a forwarded message rejected by validation.
+func errInvalidForward(err error) error {
+	return &SecError{"invalid forwarded message", err}
+}
@@ -3,6 +3,7 @@ package noise
 import (
 	"errors"
 	"fmt"
+	"strings"
 	"testing"
 )
 
@@ -82,3 +83,236 @@ func TestErrDuringHandshake(t *testing.T) {
 		t.Errorf(STATEMENT, expected, output)
 	}
 }
+
+func TestErrClosingPeer(t *testing.T) {
+	err := errors.New("fail")
+	output := errClosingPeer(err)
+	expected := "ops: error closing peer -> fail"
+
+	if output.Error() != expected {
+		t.Errorf(STATEMENT, expected, output)
+	}
+}
+
+func TestErrNodeClosed(t *testing.T) {
+	output := errNodeClosed()
+	expected := "ops: node is closed -> node shutting down"
+
+	if output.Error() != expected {
+		t.Errorf(STATEMENT, expected, output)
+	}
+}
+
+func TestErrOversizedMessage(t *testing.T) {
+	output := errOversizedMessage(2048, 1024)
+	expected := "overflow: message size 2048 exceeds max size 1024 -> oversized message"
+
+	if output.Error() != expected {
+		t.Errorf(STATEMENT, expected, output)
+	}
+}
+
+func TestErrBannedPeer(t *testing.T) {
+	output := errBannedPeer(errors.New("127.0.0.1"))
+	expected := "sec: peer is banned -> 127.0.0.1"
+
+	if output.Error() != expected {
+		t.Errorf(STATEMENT, expected, output)
+	}
+}
+
+func TestErrInvalidPublicKey(t *testing.T) {
+	output := errInvalidPublicKey(31)
+	expected := "sec: invalid remote public key -> expected 32 bytes, got 31"
+
+	if output.Error() != expected {
+		t.Errorf(STATEMENT, expected, output)
+	}
+}
+
+func TestErrKeyChanged(t *testing.T) {
+	output := errKeyChanged(MOCK_ADDRESS)
+	expected := fmt.Sprintf("sec: remote peer identity changed -> possible impersonation of %s", MOCK_ADDRESS)
+
+	if output.Error() != expected {
+		t.Errorf(STATEMENT, expected, output)
+	}
+}
+
+func TestErrInvalidIdentityProof(t *testing.T) {
+	output := errInvalidIdentityProof()
+	expected := "sec: invalid identity proof -> static key not signed by remote signature key"
+
+	if output.Error() != expected {
+		t.Errorf(STATEMENT, expected, output)
+	}
+}
+
+func TestErrInvalidIdentityKey(t *testing.T) {
+	output := errInvalidIdentityKey(32)
+	expected := "ops: invalid identity key -> expected 64 bytes, got 32"
+
+	if output.Error() != expected {
+		t.Errorf(STATEMENT, expected, output)
+	}
+}
+
+func TestErrCertificateWithoutIdentity(t *testing.T) {
+	output := errCertificateWithoutIdentity()
+	expected := "ops: invalid identity key -> certificate set without identity key"
+
+	if output.Error() != expected {
+		t.Errorf(STATEMENT, expected, output)
+	}
+}
+
+func TestErrInvalidCertificate(t *testing.T) {
+	output := errInvalidCertificate(errors.New("expired"))
+	expected := "sec: invalid peer certificate -> expired"
+
+	if output.Error() != expected {
+		t.Errorf(STATEMENT, expected, output)
+	}
+}
+
+func TestErrUnwrap(t *testing.T) {
+	err := errors.New("fail")
+	wrapped := []error{
+		&NetError{"net", err},
+		&OperationalError{"ops", err},
+		&OverflowError{"overflow", err},
+		&SecError{"sec", err},
+	}
+
+	for _, e := range wrapped {
+		if !errors.Is(e, err) {
+			t.Errorf("expected %v to wrap %v", e, err)
+		}
+	}
+}
+
+func TestErrPeerNotFound(t *testing.T) {
+	output := errPeerNotFound(ID{0xff})
+	expected := fmt.Sprintf("ops: peer not found -> no address known for ff%s", strings.Repeat("00", 31))
+
+	if output.Error() != expected {
+		t.Errorf(STATEMENT, expected, output)
+	}
+}
+
+func TestErrDHTRequest(t *testing.T) {
+	output := errDHTRequest(errors.New("request timeout"))
+	expected := "ops: error during dht request -> request timeout"
+
+	if output.Error() != expected {
+		t.Errorf(STATEMENT, expected, output)
+	}
+}
+
+func TestErrInvalidRecord(t *testing.T) {
+	output := errInvalidRecord(errors.New("invalid signature"))
+	expected := "sec: invalid record -> invalid signature"
+
+	if output.Error() != expected {
+		t.Errorf(STATEMENT, expected, output)
+	}
+}
+
+func TestErrRecordNotFound(t *testing.T) {
+	output := errRecordNotFound("svc")
+	expected := `ops: record not found -> no valid record for key "svc"`
+
+	if output.Error() != expected {
+		t.Errorf(STATEMENT, expected, output)
+	}
+}
+
+func TestErrRecordLimit(t *testing.T) {
+	output := errRecordLimit(errors.New("store reached 10 records"))
+	expected := "ops: record store limit reached -> store reached 10 records"
+
+	if output.Error() != expected {
+		t.Errorf(STATEMENT, expected, output)
+	}
+}
+
+func TestErrRecordNotStored(t *testing.T) {
+	output := errRecordNotStored(errors.New("no peers available"))
+	expected := "ops: record not stored -> no peers available"
+
+	if output.Error() != expected {
+		t.Errorf(STATEMENT, expected, output)
+	}
+}
+
+func TestErrInvalidTopic(t *testing.T) {
+	output := errInvalidTopic("")
+	expected := "ops: invalid topic -> topic length 0 must be between 1 and 256"
+
+	if output.Error() != expected {
+		t.Errorf(STATEMENT, expected, output)
+	}
+}
+
+func TestErrTopicJoined(t *testing.T) {
+	output := errTopicJoined("news")
+	expected := `ops: topic already joined -> topic "news"`
+
+	if output.Error() != expected {
+		t.Errorf(STATEMENT, expected, output)
+	}
+}
+
+func TestErrTopicClosed(t *testing.T) {
+	output := errTopicClosed("news")
+	expected := `ops: topic closed -> topic "news" was left`
+
+	if output.Error() != expected {
+		t.Errorf(STATEMENT, expected, output)
+	}
+}
+
+func TestErrInvalidMessage(t *testing.T) {
+	output := errInvalidMessage(errors.New("invalid signature"))
+	expected := "sec: invalid pubsub message -> invalid signature"
+
+	if output.Error() != expected {
+		t.Errorf(STATEMENT, expected, output)
+	}
+}
+
+func TestErrInvalidTTL(t *testing.T) {
+	output := errInvalidTTL(0)
+	expected := "ops: invalid ttl -> ttl 0 must be between 1 and 16"
+
+	if output.Error() != expected {
+		t.Errorf(STATEMENT, expected, output)
+	}
+}
+
+func TestErrInvalidBroadcast(t *testing.T) {
+	output := errInvalidBroadcast(errors.New("invalid signature"))
+	expected := "sec: invalid broadcast -> invalid signature"
+
+	if output.Error() != expected {
+		t.Errorf(STATEMENT, expected, output)
+	}
+}
+
+func TestErrNoRoute(t *testing.T) {
+	output := errNoRoute(ID{1})
+	expected := fmt.Sprintf("ops: no route to peer -> no connected peer to forward to %x", ID{1}.Bytes())
+
+	if output.Error() != expected {
+		t.Errorf(STATEMENT, expected, output)
+	}
+}
+
+func TestErrInvalidForward(t *testing.T) {
+	output := errInvalidForward(errors.New("invalid signature"))
+	expected := "sec: invalid forwarded message -> invalid signature"
+
+	if output.Error() != expected {
+		t.Errorf(STATEMENT, expected, output)
+	}
+}
@@ -25,6 +25,22 @@ const (
 	PeerDisconnected
 	// Emitted when the node is ready to accept incoming connections
 	SelfListening
+	// Emitted before every redial attempt to a persistent peer
+	PeerReconnecting
+	// Emitted when a persistent peer is connected again
+	PeerReconnected
+	// Emitted when a dialed address answers with a different identity than the pinned one
+	PeerKeyChanged
+	// Emitted when the minimum number of peers is connected while bootstrapping, incoming peers included
+	Bootstrapped
+	// Emitted when a new peer is discovered in local network
+	PeerDiscovered
+	// On new broadcast message received event
+	BroadcastReceived
+	// On new message received from a peer without direct connection
+	RelayedMessageReceived
+	// Emitted when a forwarded message couldn't be delivered to destination
+	DeliveryFailed
 )
 
 // events handle event exchange between [Node] and network.
@@ -36,14 +52,22 @@ type events struct {
 func newEvents() *events {
 	subscriber := newSubscriber()
 	// !IMPORTANT if new events are added the size should be equal to new events number.
-	// we need only 4 spaces one for each event, adding this avoids potential map growth.
+	// we need only 12 spaces one for each event, adding this avoids potential map growth.
 	// https://100go.co/#inefficient-map-initialization-27
-	broker := newBroker(4)
+	broker := newBroker(12)
 	// register default events
 	broker.Register(NewPeerDetected, subscriber)
 	broker.Register(MessageReceived, subscriber)
 	broker.Register(PeerDisconnected, subscriber)
 	broker.Register(SelfListening, subscriber)
+	broker.Register(PeerReconnecting, subscriber)
+	broker.Register(PeerReconnected, subscriber)
+	broker.Register(PeerKeyChanged, subscriber)
+	broker.Register(Bootstrapped, subscriber)
+	broker.Register(PeerDiscovered, subscriber)
+	broker.Register(BroadcastReceived, subscriber)
+	broker.Register(RelayedMessageReceived, subscriber)
+	broker.Register(DeliveryFailed, subscriber)
 
 	return &events{
 		broker,
@@ -60,16 +84,17 @@ func (e *events) Listen(ctx context.Context, ch chan<- Signal) {
 func (e *events) PeerConnected(peer *peer) {
 	// Emit new notification
 	body := peer.ID().String()
-	header := header{peer, NewPeerDetected}
+	header := header{peer, NewPeerDetected, ReasonUnknown, ID{}}
 	signal := Signal{header, body}
 	e.broker.Publish(signal)
 }
 
 // PeerDisconnected dispatch event when peer get disconnected.
-func (e *events) PeerDisconnected(peer *peer) {
+// The reason for disconnection is bundled in signal.
+func (e *events) PeerDisconnected(peer *peer, reason Reason) {
 	// Emit new notification
 	body := peer.ID().String()
-	header := header{peer, PeerDisconnected}
+	header := header{peer, PeerDisconnected, reason, ID{}}
 	signal := Signal{header, body}
 	e.broker.Publish(signal)
 }
@@ -77,7 +102,51 @@ func (e *events) PeerDisconnected(peer *peer) {
 // SelfListening dispatch event when node is ready.
 func (e *events) SelfListening(addr string) {
 	// Emit new notification
-	header := header{nil, SelfListening}
+	header := header{nil, SelfListening, ReasonUnknown, ID{}}
+	signal := Signal{header, addr}
+	e.broker.Publish(signal)
+}
+
+// PeerReconnecting dispatch event before redial a persistent peer address.
+func (e *events) PeerReconnecting(addr string) {
+	// Emit new notification
+	header := header{nil, PeerReconnecting, ReasonUnknown, ID{}}
+	signal := Signal{header, addr}
+	e.broker.Publish(signal)
+}
+
+// PeerReconnected dispatch event when a persistent peer address is connected again.
+func (e *events) PeerReconnected(peer *peer, addr string) {
+	// Emit new notification
+	header := header{peer, PeerReconnected, ReasonUnknown, ID{}}
+	signal := Signal{header, addr}
+	e.broker.Publish(signal)
+}
+
+// PeerKeyChanged dispatch event when a dialed address answers with a different identity than the pinned one.
+// The peer in signal holds the new identity and the body holds the dialed address.
+func (e *events) PeerKeyChanged(peer *peer, addr string) {
+	// Emit new notification
+	header := header{peer, PeerKeyChanged, ReasonUnknown, ID{}}
+	signal := Signal{header, addr}
+	e.broker.Publish(signal)
+}
+
+// Bootstrapped dispatch event when the node is connected to the minimum number of peers after bootstrap.
+// The peer in signal is the peer completing the minimum and the body holds its ID.
+func (e *events) Bootstrapped(peer *peer) {
+	// Emit new notification
+	body := peer.ID().String()
+	header := header{peer, Bootstrapped, ReasonUnknown, ID{}}
+	signal := Signal{header, body}
+	e.broker.Publish(signal)
+}
+
+// PeerDiscovered dispatch event when a new peer is discovered in local network.
+// The body holds the discovered peer address.
+func (e *events) PeerDiscovered(addr string) {
+	// Emit new notification
+	header := header{nil, PeerDiscovered, ReasonUnknown, ID{}}
 	signal := Signal{header, addr}
 	e.broker.Publish(signal)
 }
@@ -86,7 +155,36 @@ func (e *events) SelfListening(addr string) {
 func (e *events) NewMessage(peer *peer, msg []byte) {
 	// Emit new notification
 	message := bytesToString(msg)
-	header := header{peer, MessageReceived}
+	header := header{peer, MessageReceived, ReasonUnknown, ID{}}
+	signal := Signal{header, message}
+	e.broker.Publish(signal)
+}
+
+// Broadcast dispatch event when a new broadcast message is received.
+// The peer in signal is the relaying peer and the origin holds the broadcast originator.
+func (e *events) Broadcast(peer *peer, origin ID, msg []byte) {
+	// Emit new notification
+	message := bytesToString(msg)
+	header := header{peer, BroadcastReceived, ReasonUnknown, origin}
 	signal := Signal{header, message}
 	e.broker.Publish(signal)
 }
+
+// RelayedMessage dispatch event when a forwarded message for the node is received.
+// The peer in signal is the last relaying peer and the origin holds the message sender.
+func (e *events) RelayedMessage(peer *peer, origin ID, msg []byte) {
+	// Emit new notification
+	message := bytesToString(msg)
+	header := header{peer, RelayedMessageReceived, ReasonUnknown, origin}
+	signal := Signal{header, message}
+	e.broker.Publish(signal)
+}
+
+// DeliveryFailed dispatch event when a forwarded message couldn't be delivered.
+// The origin holds the peer reporting the failure and the body holds the destination ID.
+func (e *events) DeliveryFailed(peer *peer, origin ID, target ID) {
+	// Emit new notification
+	header := header{peer, DeliveryFailed, ReasonUnknown, origin}
+	signal := Signal{header, target.String()}
+	e.broker.Publish(signal)
+}
@@ -43,7 +43,7 @@ func main() {
 				log.Printf("New Peer connected: %x \n", signal.Payload())
 				ping := []byte("ping")
 				signal.Reply(ping) // start game
-				// TODO exchange peers?
+				// Peers are exchanged automatically if target degree is set, see config.SetTargetDegree.
 				// TODO discovery module in action here?
 
 			case noise.MessageReceived:
@@ -0,0 +1,528 @@
+package noise
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// maxForwardHops is the max hop limit accepted for forwarded messages.
+const maxForwardHops = 16
+
+// maxForwardAge is the max clock difference accepted for forwarded messages.
+// It's shorter than seenTTL so a message can't be replayed once its ID is forgotten.
+const maxForwardAge = seenTTL / 4
+
+// routeTTL is the time a learned route is kept without being refreshed.
+const routeTTL = 5 * time.Minute
+
+// staticKeyRecord is the record key used to publish the node static DH key in the DHT.
+// Peers never connected with the node use it to encrypt forwarded messages.
+const staticKeyRecord = "noise/static"
+
+// forwardDomain separates the forwarded message signatures from other messages signed with the node identity.
+const forwardDomain = "p2p-noise/forward"
+
+// sealedDomain separates the signatures of sealed messages from the forwarded message signatures.
+const sealedDomain = "p2p-noise/sealed"
+
+// forwardKind identify the kind of forwarded message.
+type forwardKind uint8
+
+const (
+	// Message encrypted end-to-end to destination.
+	forwardData forwardKind = iota
+	// Notify the origin that a message couldn't be delivered.
+	forwardFailure
+)
+
+// forwardMessage is a message relayed by peers to a destination without direct connection.
+// The Hops are not covered by signature since every relay decrements them, they are bounded by maxForwardHops
+// and never used to choose routes.
+type forwardMessage struct {
+	Kind        forwardKind
+	Destination ID
+	Sent        int64  // unix time in nanoseconds when the message was signed
+	Hops        uint8  // remaining hops
+	Ephemeral   []byte // origin ephemeral DH public key
+	Payload     []byte // encrypted message or encoded delivery failure
+	Publisher   PublicKey
+	Signature   []byte
+}
+
+// ID returns the forwarded message ID used to suppress duplicates.
+func (f forwardMessage) ID() ID {
+	return newBlake2ID(append(f.signedBytes(), f.Publisher...))
+}
+
+// From returns the origin ID.
+func (f forwardMessage) From() ID {
+	return newBlake2ID(f.Publisher)
+}
+
+// signedBytes returns the forwarded message fields covered by signature.
+func (f forwardMessage) signedBytes() []byte {
+	var buffer bytes.Buffer
+	buffer.WriteString(forwardDomain)
+	buffer.WriteByte(byte(f.Kind))
+	buffer.Write(f.Destination.Bytes())
+	binary.Write(&buffer, binary.BigEndian, f.Sent)
+	binary.Write(&buffer, binary.BigEndian, uint32(len(f.Ephemeral)))
+	buffer.Write(f.Ephemeral)
+	binary.Write(&buffer, binary.BigEndian, uint32(len(f.Payload)))
+	buffer.Write(f.Payload)
+	return buffer.Bytes()
+}
+
+// encodeForward encode a forwarded message to bytes.
+func encodeForward(f forwardMessage) []byte {
+	var buffer bytes.Buffer
+	gob.NewEncoder(&buffer).Encode(f)
+	return buffer.Bytes()
+}
+
+// decodeForward decode incoming bytes to a forwarded message.
+func decodeForward(b []byte) (forwardMessage, error) {
+	var f forwardMessage
+	err := gob.NewDecoder(bytes.NewReader(b)).Decode(&f)
+	return f, err
+}
+
+// validateForward checks the hop limit, the message age and the origin signature.
+func validateForward(f forwardMessage) error {
+	if f.Hops == 0 || f.Hops > maxForwardHops {
+		return errInvalidForward(errors.New("invalid hops"))
+	}
+
+	if age := time.Since(time.Unix(0, f.Sent)); age > maxForwardAge || age < -maxForwardAge {
+		return errInvalidForward(errors.New("expired message"))
+	}
+
+	if len(f.Publisher) != ed25519.PublicKeySize {
+		return errInvalidForward(errors.New("invalid publisher key"))
+	}
+
+	if !ed25519.Verify(f.Publisher, f.signedBytes(), f.Signature) {
+		return errInvalidForward(errors.New("invalid signature"))
+	}
+
+	return nil
+}
+
+// sealedMessage is the message encrypted end-to-end to destination.
+// The origin signs the message together with the destination inside the encryption,
+// so relays can't replace the outer signature to impersonate the origin.
+type sealedMessage struct {
+	Destination ID
+	Message     []byte
+	Publisher   PublicKey
+	Signature   []byte
+}
+
+// From returns the origin ID.
+func (s sealedMessage) From() ID {
+	return newBlake2ID(s.Publisher)
+}
+
+// signedBytes returns the sealed message fields covered by signature.
+func (s sealedMessage) signedBytes() []byte {
+	var buffer bytes.Buffer
+	buffer.WriteString(sealedDomain)
+	buffer.Write(s.Destination.Bytes())
+	binary.Write(&buffer, binary.BigEndian, uint32(len(s.Message)))
+	buffer.Write(s.Message)
+	return buffer.Bytes()
+}
+
+// encodeSealed encode a sealed message to bytes.
+func encodeSealed(s sealedMessage) []byte {
+	var buffer bytes.Buffer
+	gob.NewEncoder(&buffer).Encode(s)
+	return buffer.Bytes()
+}
+
+// decodeSealed decode a decrypted message and checks the origin signature for destination.
+func decodeSealed(b []byte, destination ID) (sealedMessage, error) {
+	var s sealedMessage
+	if err := gob.NewDecoder(bytes.NewReader(b)).Decode(&s); err != nil {
+		return s, errInvalidForward(err)
+	}
+
+	if s.Destination != destination {
+		return s, errInvalidForward(errors.New("invalid destination"))
+	}
+
+	if len(s.Publisher) != ed25519.PublicKeySize || !ed25519.Verify(s.Publisher, s.signedBytes(), s.Signature) {
+		return s, errInvalidForward(errors.New("invalid sealed signature"))
+	}
+
+	return s, nil
+}
+
+// deliveryFailure is the payload of a failure notification sent back to origin.
+type deliveryFailure struct {
+	Message ID // failed message ID
+	Target  ID // failed message destination
+	Reason  string
+}
+
+// sealTo encrypts the message to the destination static DH key using a new ephemeral key.
+// It returns the ephemeral public key needed by destination to decrypt the message.
+func sealTo(static, message []byte) ([]byte, []byte, error) {
+	ephemeral, err := CipherSuite.GenerateKeypair(rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	shared, err := CipherSuite.DH(ephemeral.Private, static)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var key [32]byte
+	copy(key[:], blake2(append(append(shared, ephemeral.Public...), static...)))
+	// Every key is used for a single message so the nonce can be zero.
+	return ephemeral.Public, CipherSuite.Cipher(key).Encrypt(nil, 0, nil, message), nil
+}
+
+// openFrom decrypts a message sealed to the local static DH key.
+func openFrom(kp DHKey, ephemeral, ciphertext []byte) ([]byte, error) {
+	shared, err := CipherSuite.DH(kp.Private, ephemeral)
+	if err != nil {
+		return nil, err
+	}
+
+	var key [32]byte
+	copy(key[:], blake2(append(append(shared, ephemeral...), kp.Public...)))
+	return CipherSuite.Cipher(key).Decrypt(nil, 0, nil, ciphertext)
+}
+
+// route is a next hop to destination learned from forwarded messages.
+type route struct {
+	next    ID
+	updated time.Time
+}
+
+// forwarder holds the multi-hop routing state for node.
+type forwarder struct {
+	announced atomic.Bool // static key published in DHT
+	seen      *seenCache
+	sent      *seenCache // messages sent by local node, a failure is accepted once for each
+	mu        sync.Mutex // guard routes
+	routes    map[ID]route
+}
+
+func newForwarder() *forwarder {
+	return &forwarder{seen: newSeenCache(), sent: newSeenCache(), routes: make(map[ID]route)}
+}
+
+// Learn registers that destination is reachable through next hop.
+// The current route is kept until it expires, it's only refreshed by messages from the same next hop.
+func (f *forwarder) Learn(destination, next ID) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	current, ok := f.routes[destination]
+	if ok && current.next != next && time.Since(current.updated) < routeTTL {
+		return
+	}
+
+	f.routes[destination] = route{next, time.Now()}
+}
+
+// Route returns the next hop learned for destination.
+func (f *forwarder) Route(destination ID) (ID, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	r, ok := f.routes[destination]
+	if !ok || time.Since(r.updated) > routeTTL {
+		delete(f.routes, destination)
+		return ID{}, false
+	}
+
+	return r.next, true
+}
+
+// Forget removes the routes through a disconnected peer.
+func (f *forwarder) Forget(next ID) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for destination, r := range f.routes {
+		if r.next == next || destination == next {
+			delete(f.routes, destination)
+		}
+	}
+}
+
+// nextHop returns the connected peer to forward a message for destination.
+// The destination itself is preferred, then the learned route, then the connected peer closest to
+// destination in the DHT key space. The previous hop is never returned to avoid bouncing messages.
+func (n *Node) nextHop(destination, previous ID) (*peer, bool) {
+	if peer, ok := n.router.Query(destination); ok {
+		return peer, true
+	}
+
+	if next, ok := n.forward.Route(destination); ok && next != previous {
+		if peer, ok := n.router.Query(next); ok {
+			return peer, true
+		}
+	}
+
+	var best *peer
+	for peer := range n.router.Table() {
+		if peer.ID() == previous {
+			continue
+		}
+
+		if best == nil || closer(destination, peer.ID(), best.ID()) {
+			best = peer
+		}
+	}
+
+	return best, best != nil
+}
+
+// announceKey publishes the node static DH key in the DHT once the node is connected.
+// If the record can't be stored it's published again with the next connected peer.
+func (n *Node) announceKey() {
+	if !n.config.Forwarding() || !n.forward.announced.CompareAndSwap(false, true) || !n.track() {
+		return
+	}
+
+	go func() {
+		defer n.wg.Done()
+		ctx, cancel := n.background()
+		defer cancel()
+
+		kr, err := n.keyRing()
+		if err == nil {
+			err = n.PutRecord(ctx, staticKeyRecord, kr.kp.Public)
+		}
+
+		if err != nil {
+			log.Printf("error announcing static key: %v", err)
+			n.forward.announced.Store(false)
+		}
+	}()
+}
+
+// staticKey returns the static DH key for peer from peerstore or the DHT.
+func (n *Node) staticKey(ctx context.Context, id ID) ([]byte, error) {
+	if info, ok := n.peerStore().Get(id); ok && len(info.StaticKey) == CipherSuite.DHLen() {
+		return info.StaticKey, nil
+	}
+
+	r, err := n.GetRecord(ctx, id.String(), staticKeyRecord)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(r.Value) != CipherSuite.DHLen() {
+		return nil, errInvalidRecord(fmt.Errorf("invalid static key size %d", len(r.Value)))
+	}
+
+	n.remember(id, func(info *PeerInfo) bool {
+		info.StaticKey = r.Value
+		return true
+	})
+
+	return r.Value, nil
+}
+
+// signForward signs a forwarded message with the node identity.
+func (n *Node) signForward(f forwardMessage) (forwardMessage, error) {
+	kr, err := n.keyRing()
+	if err != nil {
+		return f, err
+	}
+
+	f.Sent = time.Now().UnixNano()
+	f.Publisher = kr.sv.Public
+	f.Signature = ed25519.Sign(kr.sv.Private, f.signedBytes())
+	return f, nil
+}
+
+// forwardTo encrypts the message end-to-end to destination and sends it to the next hop.
+func (n *Node) forwardTo(ctx context.Context, destination ID, message []byte) (uint32, error) {
+	static, err := n.staticKey(ctx, destination)
+	if err != nil {
+		return 0, errSendingMessage(err)
+	}
+
+	kr, err := n.keyRing()
+	if err != nil {
+		return 0, err
+	}
+
+	sealed := sealedMessage{Destination: destination, Message: message, Publisher: kr.sv.Public}
+	sealed.Signature = ed25519.Sign(kr.sv.Private, sealed.signedBytes())
+	ephemeral, ciphertext, err := sealTo(static, encodeSealed(sealed))
+	if err != nil {
+		return 0, errSendingMessage(err)
+	}
+
+	hops := n.config.MaxHops()
+	if hops <= 0 || hops > maxForwardHops {
+		hops = maxForwardHops
+	}
+
+	f, err := n.signForward(forwardMessage{
+		Kind:        forwardData,
+		Destination: destination,
+		Hops:        uint8(hops),
+		Ephemeral:   ephemeral,
+		Payload:     ciphertext,
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	// The message coming back from peers is ignored.
+	n.forward.seen.Add(f.ID())
+	n.forward.sent.Add(f.ID())
+	peer, ok := n.nextHop(destination, ID{})
+	if !ok {
+		return 0, errNoRoute(destination)
+	}
+
+	return peer.send(forwardFrame, encodeForward(f))
+}
+
+// reportFailure notifies the origin that the forwarded message couldn't be delivered.
+// Failures of failure notifications are not reported.
+func (n *Node) reportFailure(f forwardMessage, reason string) {
+	log.Printf("forwarded message not delivered: %s", reason)
+	if f.Kind == forwardFailure {
+		return
+	}
+
+	origin := f.From()
+	failure := deliveryFailure{f.ID(), f.Destination, reason}
+	var buffer bytes.Buffer
+	gob.NewEncoder(&buffer).Encode(failure)
+
+	report, err := n.signForward(forwardMessage{
+		Kind:        forwardFailure,
+		Destination: origin,
+		Hops:        maxForwardHops,
+		Payload:     buffer.Bytes(),
+	})
+
+	if err != nil {
+		log.Printf("error signing delivery failure: %v", err)
+		return
+	}
+
+	n.forward.seen.Add(report.ID())
+	peer, ok := n.nextHop(origin, ID{})
+	if !ok {
+		return
+	}
+
+	if _, err := peer.send(forwardFrame, encodeForward(report)); err != nil {
+		log.Printf("error reporting delivery failure: %v", err)
+	}
+}
+
+// handleForward process an incoming forwarded message from peer.
+// Messages for the local node are verified and decrypted, other messages are relayed to the next hop
+// if forwarding is enabled. The route back to origin is learned from every message.
+// The rate limits are applied by watch, so a throttled message is never marked as seen.
+func (n *Node) handleForward(peer *peer, msg []byte) {
+	f, err := decodeForward(msg)
+	if err != nil {
+		log.Printf("error decoding forwarded message: %v", err)
+		return
+	}
+
+	if err := validateForward(f); err != nil {
+		log.Printf("forwarded message rejected: %v", err)
+		return
+	}
+
+	origin := f.From()
+	if origin == n.ID() || !n.forward.seen.Add(f.ID()) {
+		return
+	}
+
+	n.forward.Learn(origin, peer.ID())
+	if f.Destination == n.ID() {
+		n.receiveForward(peer, f)
+		return
+	}
+
+	if !n.config.Forwarding() {
+		n.reportFailure(f, "forwarding disabled")
+		return
+	}
+
+	if f.Hops--; f.Hops == 0 {
+		n.reportFailure(f, "hop limit reached")
+		return
+	}
+
+	next, ok := n.nextHop(f.Destination, peer.ID())
+	if !ok {
+		n.reportFailure(f, "no route to destination")
+		return
+	}
+
+	if _, err := next.send(forwardFrame, encodeForward(f)); err != nil {
+		n.reportFailure(f, err.Error())
+	}
+}
+
+// receiveForward delivers a forwarded message addressed to the local node.
+func (n *Node) receiveForward(peer *peer, f forwardMessage) {
+	switch f.Kind {
+	case forwardData:
+		kr, err := n.keyRing()
+		if err != nil {
+			return
+		}
+
+		message, err := openFrom(kr.kp, f.Ephemeral, f.Payload)
+		if err != nil {
+			log.Printf("error decrypting forwarded message: %v", err)
+			return
+		}
+
+		sealed, err := decodeSealed(message, n.ID())
+		if err != nil {
+			log.Printf("forwarded message rejected: %v", err)
+			return
+		}
+
+		// The outer signature could be replaced by any relay.
+		if sealed.From() != f.From() {
+			log.Printf("forwarded message rejected: origin mismatch")
+			return
+		}
+
+		n.events.RelayedMessage(peer, sealed.From(), sealed.Message)
+	case forwardFailure:
+		var failure deliveryFailure
+		if err := gob.NewDecoder(bytes.NewReader(f.Payload)).Decode(&failure); err != nil {
+			log.Printf("error decoding delivery failure: %v", err)
+			return
+		}
+
+		// Any node can sign a failure, only failures for messages sent by local node are accepted.
+		if !n.forward.sent.Remove(failure.Message) {
+			log.Printf("delivery failure rejected: unknown message")
+			return
+		}
+
+		log.Printf("message not delivered: %s", failure.Reason)
+		n.events.DeliveryFailed(peer, f.From(), failure.Target)
+	}
+}
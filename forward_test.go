@@ -0,0 +1,236 @@
+package noise
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"github.com/geolffreym/p2p-noise/config"
+)
+
+func TestSealTo(t *testing.T) {
+	kp, _ := CipherSuite.GenerateKeypair(rand.Reader)
+	ephemeral, ciphertext, err := sealTo(kp.Public, []byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bytes.Contains(ciphertext, []byte("hello")) {
+		t.Errorf("expected message encrypted")
+	}
+
+	message, err := openFrom(kp, ephemeral, ciphertext)
+	if err != nil || string(message) != "hello" {
+		t.Errorf("expected message decrypted, got %q: %v", message, err)
+	}
+
+	other, _ := CipherSuite.GenerateKeypair(rand.Reader)
+	if _, err := openFrom(other, ephemeral, ciphertext); err == nil {
+		t.Errorf("expected error decrypting with another key")
+	}
+}
+
+func TestValidateForward(t *testing.T) {
+	public, private, _ := ed25519.GenerateKey(nil)
+	sign := func(f forwardMessage) forwardMessage {
+		f.Publisher = public
+		f.Signature = ed25519.Sign(private, f.signedBytes())
+		return f
+	}
+
+	signed := func(hops uint8) forwardMessage {
+		return sign(forwardMessage{Destination: ID{1}, Sent: time.Now().UnixNano(), Hops: hops, Payload: []byte("hello")})
+	}
+
+	valid := signed(3)
+	tampered := valid
+	tampered.Payload = []byte("evil")
+	redirected := valid
+	redirected.Destination = ID{2}
+	relayed := valid
+	relayed.Hops--
+	replayed := valid
+	replayed.Sent = time.Now().Add(-2 * maxForwardAge).UnixNano()
+
+	cases := []struct {
+		name    string
+		forward forwardMessage
+		valid   bool
+	}{
+		{"valid", valid, true},
+		{"relayed", relayed, true},
+		{"tampered", tampered, false},
+		{"redirected", redirected, false},
+		{"replayed", replayed, false},
+		{"stale", sign(forwardMessage{Destination: ID{1}, Sent: replayed.Sent, Hops: 3}), false},
+		{"future", sign(forwardMessage{Destination: ID{1}, Sent: time.Now().Add(2 * maxForwardAge).UnixNano(), Hops: 3}), false},
+		{"expired hops", signed(0), false},
+		{"hops too long", signed(maxForwardHops + 1), false},
+		{"invalid publisher", forwardMessage{Hops: 1}, false},
+	}
+
+	for _, e := range cases {
+		t.Run(e.name, func(t *testing.T) {
+			if err := validateForward(e.forward); (err == nil) != e.valid {
+				t.Errorf("expected valid = %v, got %v", e.valid, err)
+			}
+		})
+	}
+
+	if valid.ID() != relayed.ID() {
+		t.Errorf("expected same ID for relayed message")
+	}
+}
+
+func TestForwarderRoutes(t *testing.T) {
+	f := newForwarder()
+	f.Learn(ID{1}, ID{2})
+	f.Learn(ID{1}, ID{3})
+	if next, ok := f.Route(ID{1}); !ok || next != (ID{2}) {
+		t.Errorf("expected current route kept, got %v", next)
+	}
+
+	f.routes[ID{1}] = route{ID{2}, time.Now().Add(-2 * routeTTL)}
+	f.Learn(ID{1}, ID{3})
+	if next, _ := f.Route(ID{1}); next != (ID{3}) {
+		t.Errorf("expected expired route replaced, got %v", next)
+	}
+
+	f.routes[ID{4}] = route{ID{2}, time.Now().Add(-2 * routeTTL)}
+	if _, ok := f.Route(ID{4}); ok {
+		t.Errorf("expected expired route removed")
+	}
+
+	f.Learn(ID{5}, ID{3})
+	f.Forget(ID{3})
+	if len(f.routes) != 0 {
+		t.Errorf("expected routes through forgotten peer removed, got %d", len(f.routes))
+	}
+}
+
+func TestDecodeSealed(t *testing.T) {
+	public, private, _ := ed25519.GenerateKey(nil)
+	sealed := sealedMessage{Destination: ID{1}, Message: []byte("hello"), Publisher: public}
+	sealed.Signature = ed25519.Sign(private, sealed.signedBytes())
+
+	decoded, err := decodeSealed(encodeSealed(sealed), ID{1})
+	if err != nil || string(decoded.Message) != "hello" || decoded.From() != newBlake2ID(public) {
+		t.Errorf("expected sealed message decoded, got %v", err)
+	}
+
+	if _, err := decodeSealed(encodeSealed(sealed), ID{2}); err == nil {
+		t.Errorf("expected error for message sealed to another destination")
+	}
+
+	// A relay can't sign the message as its own without the origin key.
+	relay, _, _ := ed25519.GenerateKey(nil)
+	sealed.Publisher = relay
+	if _, err := decodeSealed(encodeSealed(sealed), ID{1}); err == nil {
+		t.Errorf("expected error for message signed by another key")
+	}
+}
+
+func TestSendNoRoute(t *testing.T) {
+	configuration := config.New()
+	configuration.Write(config.SetForwarding(true))
+	node := New(configuration)
+	defer node.Close()
+
+	kp, _ := CipherSuite.GenerateKeypair(rand.Reader)
+	id := ID{1}
+	node.remember(id, func(info *PeerInfo) bool {
+		info.StaticKey = kp.Public
+		return true
+	})
+
+	_, err := node.Send(id.String(), []byte("hello"))
+	if err == nil || err.Error() != errNoRoute(id).Error() {
+		t.Errorf("expected no route error, got %v", err)
+	}
+}
+
+func TestForward(t *testing.T) {
+	nodes := make([]*Node, 3)
+	signals := make([]<-chan Signal, len(nodes))
+	for i := range nodes {
+		configuration := config.New()
+		configuration.Write(
+			config.SetSelfListeningAddress("127.0.0.1:"),
+			config.SetDHTRefreshInterval(0),
+			config.SetForwarding(true),
+		)
+
+		nodes[i] = New(configuration)
+		defer nodes[i].Close()
+		var cancel func()
+		signals[i], cancel = nodes[i].Signals()
+		defer cancel()
+		go nodes[i].Listen()
+	}
+
+	for i := range nodes {
+		if _, ok := waitFor(signals[i], SelfListening, 2*time.Second); !ok {
+			t.Fatalf("expected node listening")
+		}
+	}
+
+	// A line topology: A -> B -> C
+	for i := 0; i < len(nodes)-1; i++ {
+		if err := nodes[i].Dial(nodes[i+1].LocalAddr().String()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The DHT lookup of the static key would dial C, share the key known by B instead.
+	destination := nodes[2].ID().String()
+	info, ok := nodes[1].PeerInfo(destination)
+	if !ok || len(info.StaticKey) != CipherSuite.DHLen() {
+		t.Fatalf("expected static key of C remembered by B")
+	}
+
+	nodes[0].remember(nodes[2].ID(), func(known *PeerInfo) bool {
+		known.StaticKey = info.StaticKey
+		return true
+	})
+
+	if _, err := nodes[0].Send(destination, []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+
+	signal, ok := waitFor(signals[2], RelayedMessageReceived, 2*time.Second)
+	if !ok {
+		t.Fatalf("expected relayed message received by C")
+	}
+
+	if signal.Payload() != "hello" || signal.Origin() != nodes[0].ID().String() {
+		t.Errorf("expected relayed message from A, got %q", signal.Payload())
+	}
+
+	// Failures for messages not sent by A are dropped.
+	forged, _ := nodes[0].signForward(forwardMessage{Kind: forwardData, Destination: nodes[2].ID(), Hops: 1})
+	nodes[1].reportFailure(forged, "forged")
+	if _, ok := waitFor(signals[0], DeliveryFailed, 500*time.Millisecond); ok {
+		t.Errorf("expected failure for unknown message dropped")
+	}
+
+	// C is gone, B can't deliver the message and notifies A.
+	nodes[2].Close()
+	if _, ok := waitFor(signals[1], PeerDisconnected, 2*time.Second); !ok {
+		t.Fatalf("expected C disconnected from B")
+	}
+
+	if _, err := nodes[0].Send(destination, []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+
+	signal, ok = waitFor(signals[0], DeliveryFailed, 2*time.Second)
+	if !ok {
+		t.Fatalf("expected delivery failure notified to A")
+	}
+
+	if signal.Payload() != destination || signal.Origin() != nodes[1].ID().String() {
+		t.Errorf("expected failure for C reported by B, got %q", signal.Payload())
+	}
+}
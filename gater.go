@@ -0,0 +1,232 @@
+package noise
+
+import (
+	"net"
+	"strings"
+	"sync"
+)
+
+// [ConnectionGater] decides if a connection is allowed at every stage of the connection lifecycle.
+// The node consults the gater before dialing, on accepting incoming connections and after handshake.
+// Return false to reject the connection.
+type ConnectionGater interface {
+	// InterceptDial is called before dialing the remote address.
+	InterceptDial(addr string) bool
+	// InterceptAccept is called for incoming connections before handshake.
+	InterceptAccept(addr net.Addr) bool
+	// InterceptSecured is called after handshake when the remote peer identity and the negotiated protocol are known.
+	// eg. protocol = tcp/Noise_XX_25519_ChaChaPoly_BLAKE2b
+	InterceptSecured(id ID, addr net.Addr, protocol string) bool
+}
+
+// [ListGater] implements a [ConnectionGater] backed by IP/CIDR and peer ID allow/deny lists.
+// Deny lists have priority over allow lists.
+// If an allow list is not empty only the matching IPs or IDs are allowed.
+// The lists can be changed at runtime.
+type ListGater struct {
+	mu        sync.RWMutex
+	allowNets map[string]*net.IPNet
+	denyNets  map[string]*net.IPNet
+	allowIDs  map[ID]struct{}
+	denyIDs   map[ID]struct{}
+	protocols map[string]struct{}
+}
+
+// NewListGater creates a new gater with empty lists, allowing any connection.
+func NewListGater() *ListGater {
+	return &ListGater{
+		allowNets: make(map[string]*net.IPNet),
+		denyNets:  make(map[string]*net.IPNet),
+		allowIDs:  make(map[ID]struct{}),
+		denyIDs:   make(map[ID]struct{}),
+		protocols: make(map[string]struct{}),
+	}
+}
+
+// parseCIDR parses CIDR notation or single IP address eg. 10.0.0.0/8 or 192.168.1.10.
+func parseCIDR(cidr string) (*net.IPNet, error) {
+	if !strings.Contains(cidr, "/") {
+		ip := net.ParseIP(cidr)
+		if ip == nil {
+			return nil, &net.ParseError{Type: "IP address", Text: cidr}
+		}
+
+		bits := 8 * net.IPv6len
+		if ip.To4() != nil {
+			ip, bits = ip.To4(), 8*net.IPv4len
+		}
+
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+
+	_, network, err := net.ParseCIDR(cidr)
+	return network, err
+}
+
+// AllowCIDR adds the network or IP address to the allow list.
+func (g *ListGater) AllowCIDR(cidr string) error {
+	network, err := parseCIDR(cidr)
+	if err != nil {
+		return err
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.allowNets[cidr] = network
+	return nil
+}
+
+// DenyCIDR adds the network or IP address to the deny list.
+func (g *ListGater) DenyCIDR(cidr string) error {
+	network, err := parseCIDR(cidr)
+	if err != nil {
+		return err
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.denyNets[cidr] = network
+	return nil
+}
+
+// RemoveCIDR removes the network or IP address from allow and deny lists.
+func (g *ListGater) RemoveCIDR(cidr string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.allowNets, cidr)
+	delete(g.denyNets, cidr)
+}
+
+// AllowPeer adds the peer ID to the allow list.
+func (g *ListGater) AllowPeer(rawID string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.allowIDs[newIDFromString(rawID)] = struct{}{}
+}
+
+// DenyPeer adds the peer ID to the deny list.
+func (g *ListGater) DenyPeer(rawID string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.denyIDs[newIDFromString(rawID)] = struct{}{}
+}
+
+// RemovePeer removes the peer ID from allow and deny lists.
+func (g *ListGater) RemovePeer(rawID string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	id := newIDFromString(rawID)
+	delete(g.allowIDs, id)
+	delete(g.denyIDs, id)
+}
+
+// AllowProtocol adds the protocol to the allowed protocols.
+// If no protocols are added any protocol is allowed.
+func (g *ListGater) AllowProtocol(protocol string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.protocols[protocol] = struct{}{}
+}
+
+// RemoveProtocol removes the protocol from allowed protocols.
+func (g *ListGater) RemoveProtocol(protocol string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.protocols, protocol)
+}
+
+// contains returns true if any network contains the IP.
+func contains(networks map[string]*net.IPNet, ip net.IP) bool {
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// filtering returns true if any network list is not empty.
+func (g *ListGater) filtering() bool {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return len(g.allowNets) > 0 || len(g.denyNets) > 0
+}
+
+// allowedIP check the IP against the networks lists.
+// A nil IP, eg. a not resolved hostname, is only allowed if both networks lists are empty.
+func (g *ListGater) allowedIP(ip net.IP) bool {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	if ip == nil {
+		return len(g.allowNets) == 0 && len(g.denyNets) == 0
+	}
+
+	if contains(g.denyNets, ip) {
+		return false
+	}
+
+	return len(g.allowNets) == 0 || contains(g.allowNets, ip)
+}
+
+// hostIP returns the IP for address in "host:port" format.
+// If the host isn't an IP address nil is returned.
+func hostIP(addr string) net.IP {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+
+	return net.ParseIP(host)
+}
+
+// InterceptDial check the remote address against the networks lists.
+// If any network list is set the hostnames are resolved, every resolved IP must be allowed.
+func (g *ListGater) InterceptDial(addr string) bool {
+	if ip := hostIP(addr); ip != nil || !g.filtering() {
+		return g.allowedIP(ip)
+	}
+
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+
+	ips, err := net.LookupIP(host)
+	if err != nil || len(ips) == 0 {
+		return false
+	}
+
+	for _, ip := range ips {
+		if !g.allowedIP(ip) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// InterceptAccept check the remote address against the networks lists.
+func (g *ListGater) InterceptAccept(addr net.Addr) bool {
+	return g.allowedIP(hostIP(addr.String()))
+}
+
+// InterceptSecured check the peer ID against the peers lists and the protocol against allowed protocols.
+func (g *ListGater) InterceptSecured(id ID, addr net.Addr, protocol string) bool {
+	if !g.allowedIP(hostIP(addr.String())) {
+		return false
+	}
+
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	if _, denied := g.denyIDs[id]; denied {
+		return false
+	}
+
+	if _, allowed := g.allowIDs[id]; len(g.allowIDs) > 0 && !allowed {
+		return false
+	}
+
+	_, allowed := g.protocols[protocol]
+	return len(g.protocols) == 0 || allowed
+}
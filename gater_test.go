@@ -0,0 +1,154 @@
+package noise
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/geolffreym/p2p-noise/config"
+)
+
+func TestListGaterCIDR(t *testing.T) {
+	gater := NewListGater()
+	if err := gater.DenyCIDR("10.0.0.0/8"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := gater.DenyCIDR("invalid"); err == nil {
+		t.Errorf("expected error for invalid CIDR")
+	}
+
+	addresses := []struct {
+		addr     string
+		expected bool
+	}{
+		{"10.1.2.3:8010", false},
+		{"192.168.1.1:8010", true},
+		{"localhost:8010", true},
+		{"host.invalid:8010", false},
+	}
+
+	for _, e := range addresses {
+		if gater.InterceptDial(e.addr) != e.expected {
+			t.Errorf("expected dial %s allowed = %v", e.addr, e.expected)
+		}
+	}
+
+	// Hostnames are checked by the resolved address.
+	gater.DenyCIDR("127.0.0.1")
+	if gater.InterceptDial("localhost:8010") {
+		t.Errorf("expected dial to denied hostname rejected")
+	}
+
+	gater.RemoveCIDR("127.0.0.1")
+
+	// Allow list restrict to matching addresses only.
+	gater.AllowCIDR("192.168.1.1")
+	if gater.InterceptDial("192.168.1.2:8010") || !gater.InterceptDial("192.168.1.1:8010") {
+		t.Errorf("expected only allowed address accepted")
+	}
+
+	// Changes at runtime
+	gater.RemoveCIDR("10.0.0.0/8")
+	gater.RemoveCIDR("192.168.1.1")
+	if !gater.InterceptAccept(&net.TCPAddr{IP: net.ParseIP("10.1.2.3"), Port: 8010}) {
+		t.Errorf("expected address allowed after remove from lists")
+	}
+}
+
+func TestListGaterPeers(t *testing.T) {
+	gater := NewListGater()
+	addr := &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 8010}
+	protocol := protocolName("tcp")
+
+	gater.DenyPeer(peerA.ID().String())
+	if gater.InterceptSecured(peerA.ID(), addr, protocol) {
+		t.Errorf("expected denied peer rejected")
+	}
+
+	gater.AllowPeer(peerB.ID().String())
+	if !gater.InterceptSecured(peerB.ID(), addr, protocol) || gater.InterceptSecured(peerC.ID(), addr, protocol) {
+		t.Errorf("expected only allowed peers accepted")
+	}
+
+	gater.AllowProtocol("udp/other")
+	if gater.InterceptSecured(peerB.ID(), addr, protocol) {
+		t.Errorf("expected not allowed protocol rejected")
+	}
+
+	gater.RemoveProtocol("udp/other")
+	gater.RemovePeer(peerA.ID().String())
+	gater.RemovePeer(peerB.ID().String())
+	if !gater.InterceptSecured(peerA.ID(), addr, protocol) {
+		t.Errorf("expected peer allowed after remove from lists")
+	}
+}
+
+func TestGaterDial(t *testing.T) {
+	gater := NewListGater()
+	gater.DenyCIDR("127.0.0.1")
+
+	node := New(config.New())
+	node.SetConnectionGater(gater)
+
+	if err := node.Dial("127.0.0.1:9999"); err == nil {
+		t.Errorf("expected dial rejected by gater")
+	}
+}
+
+func TestGaterAccept(t *testing.T) {
+	configurationA := config.New()
+	configurationA.Write(config.SetSelfListeningAddress("127.0.0.1:"))
+
+	gater := NewListGater()
+	gater.DenyCIDR("127.0.0.0/8")
+	nodeA := New(configurationA)
+	nodeA.SetConnectionGater(gater)
+	nodeB := New(config.New())
+	defer nodeA.Close()
+	defer nodeB.Close()
+
+	<-whenReadyForIncomingDial(nodeA)
+	if err := nodeB.Dial(nodeA.LocalAddr().String()); err == nil {
+		t.Errorf("expected handshake failure for rejected connection")
+	}
+}
+
+func TestGaterSecured(t *testing.T) {
+	configurationA := config.New()
+	configurationA.Write(config.SetSelfListeningAddress("127.0.0.1:"))
+
+	nodeA := New(configurationA)
+	nodeB := New(config.New())
+	defer nodeA.Close()
+	defer nodeB.Close()
+
+	gater := NewListGater()
+	gater.DenyPeer(nodeB.ID().String())
+	nodeA.SetConnectionGater(gater)
+
+	<-whenReadyForIncomingDial(nodeA)
+	signalsB, cancel := nodeB.Signals()
+	defer cancel()
+
+	nodeB.Dial(nodeA.LocalAddr().String())
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case signal := <-signalsB:
+			if signal.Type() == PeerDisconnected {
+				if signal.Reason() != ReasonBanned {
+					t.Errorf("expected reason %s, got %s", ReasonBanned, signal.Reason())
+				}
+
+				if nodeA.router.Len() != 0 {
+					t.Errorf("expected rejected peer not routed")
+				}
+
+				return
+			}
+		case <-timeout:
+			t.Fatalf("expected rejected peer disconnected")
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net"
 
@@ -31,8 +32,9 @@ type EDKeyPair struct {
 
 // [KeyRing] hold the set of local keys to use during handshake and session.
 type KeyRing struct {
-	kp DHKey     // encrypt-decrypt key pair exchange
-	sv EDKeyPair // ED25519 local sign-verify keys
+	kp   DHKey     // encrypt-decrypt key pair exchange
+	sv   EDKeyPair // ED25519 local sign-verify keys
+	cert []byte    // local certificate presented to remote peers
 }
 
 // [CipherState] provides symmetric encryption and decryption after a successful handshake.
@@ -72,11 +74,26 @@ type HandshakeState interface {
 }
 
 // Buffer pools
-// If bPools >= 1 a new buffered pool is created.
-// If bPools == 0 a new no-buffered pool is created
-const bPools = 1
+// Up to bPools buffers are kept in the pool shared by all the handshakes,
+// extra buffers are allocated on demand and discarded.
+const bPools = 64
 const headerSize = 2
 
+// maxCertificateSize is the max size for certificates exchanged during handshake.
+const maxCertificateSize = 1024
+
+// handshakeBufferSize is the max size possible for tokens exchanged between peers.
+//
+//	dhKeyLen = 2 * DHLen = 64 bytes
+//	edKeyLen = 32 bytes
+//	proofLen = 64 bytes
+//	cipherLen = 2 * chacha20poly1305.Overhead = 32 bytes
+//	certLen = up to maxCertificateSize
+const handshakeBufferSize = 2*32 + ed25519.PublicKeySize + ed25519.SignatureSize + 2*chacha20poly1305.Overhead + maxCertificateSize + headerSize
+
+// handshakePool is shared by all the handshakes, so a burst of connections doesn't allocate a pool for each one.
+var handshakePool = bpool.NewBytePool(bPools, handshakeBufferSize)
+
 // [CipherSuite] is a set of cryptographic primitives used in a Noise protocol.
 // Based on: Diffie-Hellman X25519, [Blake2] and [ChaCha20-Poly1305]
 // Please see [NoisePatternExplorer] for more details.
@@ -94,6 +111,11 @@ var CipherSuite = noise.NewCipherSuite(noise.DH25519, noise.CipherChaChaPoly, no
 // [NoisePatternExplorer]: https://noiseexplorer.com/patterns/XX/
 var HandshakePattern = noise.HandshakeXX
 
+// protocolName returns the negotiated protocol for network eg. tcp/Noise_XX_25519_ChaChaPoly_BLAKE2b
+func protocolName(network string) string {
+	return network + "/Noise_" + HandshakePattern.Name + "_" + string(CipherSuite.Name())
+}
+
 // GenerateKeypair generates a new keypair using random as a source of entropy.
 // Please see [Docs] for more details.
 //
@@ -117,8 +139,6 @@ func newDHKeyPair() (DHKey, error) {
 func newED25519KeyPair() (EDKeyPair, error) {
 	// ref: https://github.com/openssl/openssl/issues/18448
 	// ref: https://csrc.nist.gov/csrc/media/events/workshop-on-elliptic-curve-cryptography-standards/documents/papers/session6-adalier-mehmet.pdf
-	// TODO should i persist seed to keep the same public key for peer identity?
-	// TODO rand.Reader store it and retrieve it to avoid change the pub key in every new handshake?
 	pb, pv, err := ed25519.GenerateKey(rand.Reader)
 	if err != nil {
 		return EDKeyPair{}, err
@@ -182,16 +202,31 @@ func newKeyRing() (KeyRing, error) {
 		return KeyRing{}, err
 	}
 
-	return KeyRing{kp, sv}, nil
+	return KeyRing{kp: kp, sv: sv}, nil
 }
 
-// newHandshake create a new handshake handler using provided connection and role.
-func newHandshake(conn net.Conn, initiator bool) (*handshake, error) {
-	kr, err := newKeyRing()
+// newIdentityKeyRing create a bundle of local keys using a persistent ED25519 identity key.
+// The certificate is presented to remote peers during handshake.
+func newIdentityKeyRing(identity PrivateKey, cert []byte) (KeyRing, error) {
+	if len(identity) != ed25519.PrivateKeySize {
+		return KeyRing{}, errInvalidIdentityKey(len(identity))
+	}
+
+	if len(cert) > maxCertificateSize {
+		return KeyRing{}, errOversizedMessage(len(cert), maxCertificateSize)
+	}
+
+	kp, err := newDHKeyPair()
 	if err != nil {
-		return nil, err
+		return KeyRing{}, err
 	}
 
+	public := identity.Public().(ed25519.PublicKey)
+	return KeyRing{kp, EDKeyPair{identity, public}, cert}, nil
+}
+
+// newHandshake create a new handshake handler using provided connection, role and local keys.
+func newHandshake(conn net.Conn, initiator bool, kr KeyRing) (*handshake, error) {
 	// set handshake state as initiator?
 	conf := newHandshakeConfig(initiator, kr.kp)
 	// A HandshakeState tracks the state of a Noise handshake
@@ -200,14 +235,6 @@ func newHandshake(conn net.Conn, initiator bool) (*handshake, error) {
 		return nil, errDuringHandshake(err)
 	}
 
-	// Setup the max of size possible for tokens exchanged between peers.
-	edKeyLen := ed25519.PublicKeySize          // 32 bytes
-	dhKeyLen := 2 * noise.DH25519.DHLen()      // 64 bytes
-	cipherLen := 2 * chacha20poly1305.Overhead // 32 bytes
-	// Sum the needed memory size for pool
-	size := dhKeyLen + edKeyLen + cipherLen + headerSize
-	pool := bpool.NewBytePool(bPools, size) // N bytes pool
-
 	// Create a new session handler
 	session, err := newSession(conn, kr)
 	if err != nil {
@@ -215,7 +242,7 @@ func newHandshake(conn net.Conn, initiator bool) (*handshake, error) {
 		return nil, errDuringHandshake(err)
 	}
 
-	return &handshake{session, kr, state, pool, initiator}, nil
+	return &handshake{session, kr, state, handshakePool, initiator}, nil
 }
 
 // Session return secured session after handshake.
@@ -331,6 +358,22 @@ func (h *handshake) Answer() error {
 	return nil
 }
 
+// payload returns the local payload for next handshake message.
+// The first message only carries the signature public key since it isn't encrypted,
+// the next messages carry the signature public key, the local static key signed with the signature key
+// and the local certificate.
+func (h *handshake) payload() []byte {
+	if h.hs.MessageIndex() == 0 {
+		return h.kr.sv.Public
+	}
+
+	proof := ed25519.Sign(h.kr.sv.Private, h.kr.kp.Public)
+	payload := make([]byte, 0, len(h.kr.sv.Public)+len(proof)+len(h.kr.cert))
+	payload = append(payload, h.kr.sv.Public...)
+	payload = append(payload, proof...)
+	return append(payload, h.kr.cert...)
+}
+
 // Send create a new token based on message pattern synchronization and send it to remote peer.
 func (h *handshake) Send() (e, d CipherState, err error) {
 	var msg []byte
@@ -344,13 +387,16 @@ func (h *handshake) Send() (e, d CipherState, err error) {
 	// CipherStates will be returned, one is used for encryption of messages to the
 	// remote peer, the other is used for decryption of messages from the remote
 	// peer. Append public signature key in payload to share with remote.
-	msg, e, d, err = h.hs.WriteMessage(buffer, h.kr.sv.Public)
+	msg, e, d, err = h.hs.WriteMessage(buffer, h.payload())
 	if err != nil {
 		return
 	}
 
 	// 2 bytes of header size
-	binary.Write(h.s, binary.BigEndian, uint16(len(msg)))
+	if err = binary.Write(h.s, binary.BigEndian, uint16(len(msg))); err != nil {
+		return
+	}
+
 	if _, err = h.s.Write(msg); err != nil {
 		return
 	}
@@ -369,13 +415,18 @@ func (h *handshake) Receive() (e, d CipherState, err error) {
 		return
 	}
 
+	// Handshake messages are never bigger than the pool buffers.
+	if int(size) > handshakeBufferSize {
+		err = errOversizedMessage(int(size), handshakeBufferSize)
+		return
+	}
+
 	// With size sent get a chunk from pool
-	// Maybe here we don't need a new pool, just getting a chunk of current could help?
-	buffer := h.p.Get()[:size]
+	buffer := h.p.Get()
 	defer h.p.Put(buffer)
 
-	// Wait for incoming message from remote
-	if _, err = h.s.Read(buffer); err != nil {
+	// Wait for the whole incoming message from remote
+	if _, err = io.ReadFull(h.s, buffer[:size]); err != nil {
 		return
 	}
 
@@ -384,9 +435,35 @@ func (h *handshake) Receive() (e, d CipherState, err error) {
 	// will be returned, one is used for encryption of messages to the remote peer,
 	// the other is used for decryption of messages from the remote peer. It is an
 	// error to call this method out of sync with the handshake pattern.
-	payload, e, d, err = h.hs.ReadMessage(nil, buffer)
+	payload, e, d, err = h.hs.ReadMessage(nil, buffer[:size])
+	if err != nil {
+		return
+	}
+
+	// Every handshake message carries the remote signature public key.
+	if len(payload) < ed25519.PublicKeySize {
+		err = errInvalidPublicKey(len(payload))
+		return
+	}
+
+	public, proof := payload[:ed25519.PublicKeySize], payload[ed25519.PublicKeySize:]
+	// Once the remote static key is known the payload proves the ownership of signature key,
+	// eg. the static key signed with signature key followed by the optional certificate.
+	if static := h.hs.PeerStatic(); len(static) > 0 {
+		if len(proof) < ed25519.SignatureSize || !ed25519.Verify(public, static, proof[:ed25519.SignatureSize]) {
+			err = errInvalidIdentityProof()
+			return
+		}
+
+		h.s.SetRemoteCertificate(proof[ed25519.SignatureSize:])
+		h.s.SetRemoteStatic(static)
+	} else if len(proof) > 0 {
+		err = errInvalidPublicKey(len(payload))
+		return
+	}
+
 	// Set remote signature validation public key
-	h.s.SetRemotePublicKey(payload)
+	h.s.SetRemotePublicKey(public)
 	return
 
 }
@@ -1 +1,73 @@
 package noise
+
+import (
+	"crypto/ed25519"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"testing"
+)
+
+func TestHandshake(t *testing.T) {
+	sA, sB := mockSecureSessions(t)
+	if sA == nil || sB == nil {
+		t.Fatalf("expected secure sessions after handshake")
+	}
+
+	if newBlake2ID(sA.RemotePublicKey()) != newBlake2ID(sB.kr.sv.Public) {
+		t.Errorf("expected remote identity exchanged during handshake")
+	}
+}
+
+func TestHandshakeOversizedMessage(t *testing.T) {
+	initiator, responder := net.Pipe()
+	kr, err := newKeyRing()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h, _ := newHandshake(responder, false, kr)
+	go binary.Write(initiator, binary.BigEndian, uint16(handshakeBufferSize+1))
+
+	var overflow *OverflowError
+	if _, _, err := h.Receive(); !errors.As(err, &overflow) {
+		t.Errorf("expected overflow error, got %v", err)
+	}
+}
+
+func FuzzHandshakeReceive(f *testing.F) {
+	// Real first handshake message from initiator.
+	initiator, responder := net.Pipe()
+	kr, err := newKeyRing()
+	if err != nil {
+		f.Fatal(err)
+	}
+
+	h, _ := newHandshake(initiator, true, kr)
+	go h.Send()
+	var size uint16
+	binary.Read(responder, binary.BigEndian, &size)
+	first := make([]byte, size)
+	io.ReadFull(responder, first)
+
+	f.Add(first)
+	f.Add(first[:len(first)-1])
+	f.Add([]byte{})
+
+	f.Fuzz(func(t *testing.T, msg []byte) {
+		initiator, responder := net.Pipe()
+		h, _ := newHandshake(responder, false, kr)
+		go func() {
+			binary.Write(initiator, binary.BigEndian, uint16(len(msg)))
+			initiator.Write(msg)
+			initiator.Close()
+		}()
+
+		if _, _, err := h.Receive(); err == nil && len(h.s.RemotePublicKey()) != ed25519.PublicKeySize {
+			t.Errorf("expected valid remote public key after receive")
+		}
+
+		responder.Close()
+	})
+}
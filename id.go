@@ -1,9 +1,5 @@
 package noise
 
-import (
-	"unsafe"
-)
-
 // [ID] serves as the identity for peers.
 // It facilitates addressability in router table.
 type ID [32]byte
@@ -21,15 +17,12 @@ func (i ID) String() string {
 
 // newIDFromString creates a new ID from string.
 // ref: https://go.dev/ref/spec#Conversions
-// https://pkg.go.dev/unsafe#Pointer
 func newIDFromString(s string) ID {
-	// "no-copy" convert to ID from string.
-	// If the type starts with the operator * or <-, it must be parenthesized when necessary to avoid ambiguity.
-	// 1- unsafe.Pointer(&s) <- create a pointer from string address
-	// 2- (*ID)(unsafe.Pointer(&s)) <- cast the pointer to *ID pointer
-	// 3 - *(*ID)(unsafe.Pointer(&s)) <- get the value in memory address
-	// TODO could by replaced by unsafe.StringData(s) in go >=1.20
-	return *(*ID)(unsafe.Pointer(&s))
+	// Copy the string bytes into the fixed size array.
+	// The string header can't be casted to ID since it holds a pointer to data and the length.
+	var id ID
+	copy(id[:], s)
+	return id
 }
 
 // newBlake2ID creates a new id blake2 hash based.
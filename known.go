@@ -0,0 +1,188 @@
+package noise
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Trust modes for dialed peers identity.
+// The values must match the trust modes in config package.
+const (
+	trustOff uint8 = iota
+	trustOnFirstUse
+	trustStrict
+)
+
+// [KnownPeers] stores the pinned identity for each dialed address.
+// Please see [Node.SetKnownPeers] to use a custom store.
+type KnownPeers interface {
+	// Lookup returns the pinned peer ID for address.
+	Lookup(addr string) (ID, bool)
+	// Pin stores the peer ID for address.
+	Pin(addr string, id ID) error
+}
+
+// [KnownPeersFile] implements a [KnownPeers] store backed by a file, similar to ssh known_hosts.
+// Each line holds the address and the hex encoded peer ID separated by a space.
+// If the path is empty the store is kept only in memory.
+type KnownPeersFile struct {
+	mu    sync.RWMutex
+	path  string
+	peers map[string]ID
+}
+
+// NewKnownPeersFile creates a new store loading the known peers from file.
+// A missing file is not an error, the file is created when the first peer is pinned.
+func NewKnownPeersFile(path string) (*KnownPeersFile, error) {
+	k := &KnownPeersFile{path: path, peers: make(map[string]ID)}
+	if path == "" {
+		return k, nil
+	}
+
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return k, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		// Skip empty lines and comments
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		raw, err := hex.DecodeString(fields[len(fields)-1])
+		if len(fields) != 2 || err != nil || len(raw) != len(ID{}) {
+			return nil, fmt.Errorf("invalid known peer at %s:%d", path, line)
+		}
+
+		k.peers[fields[0]] = newIDFromString(string(raw))
+	}
+
+	return k, scanner.Err()
+}
+
+// Lookup returns the pinned peer ID for address.
+func (k *KnownPeersFile) Lookup(addr string) (ID, bool) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	id, ok := k.peers[addr]
+	return id, ok
+}
+
+// Pin stores the peer ID for address replacing any previous ID.
+func (k *KnownPeersFile) Pin(addr string, id ID) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.peers[addr] = id
+	return k.save()
+}
+
+// Forget removes the pinned peer ID for address.
+// Use it to accept a peer after a legit key change.
+func (k *KnownPeersFile) Forget(addr string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	delete(k.peers, addr)
+	return k.save()
+}
+
+// save writes the known peers to file.
+// The caller must hold the lock.
+func (k *KnownPeersFile) save() error {
+	if k.path == "" {
+		return nil
+	}
+
+	var b strings.Builder
+	for addr, id := range k.peers {
+		fmt.Fprintf(&b, "%s %s\n", addr, hex.EncodeToString(id.Bytes()))
+	}
+
+	// Replace the file atomically to avoid partial writes on crashes.
+	tmp, err := os.CreateTemp(filepath.Dir(k.path), filepath.Base(k.path)+".*")
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.WriteString(b.String()); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), k.path)
+}
+
+// SetKnownPeers sets the store used to pin the identity of dialed addresses.
+// The store is only consulted if the trust mode is not off.
+func (n *Node) SetKnownPeers(store KnownPeers) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.known = store
+}
+
+// knownPeers returns the current known peers store.
+func (n *Node) knownPeers() KnownPeers {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.known
+}
+
+// verifyKnownPeer checks the remote identity against the pinned identity for dialed address.
+// In trust on first use mode unknown addresses are pinned to the remote identity.
+// In strict mode only already pinned addresses are allowed.
+// The pinned identity is the signature key, it's trusted only if the handshake proved it's bound to
+// the remote static key, otherwise anyone could present a copied signature key.
+// Returns an error if the identity is not bound, the identity changed or the address is unknown in strict mode.
+// A PeerKeyChanged signal is emitted if the identity changed.
+func (n *Node) verifyKnownPeer(addr string, s *session) error {
+	mode := n.config.TrustMode()
+	store := n.knownPeers()
+	if mode == trustOff || store == nil {
+		return nil
+	}
+
+	if len(s.RemoteStatic()) == 0 {
+		return errInvalidIdentityProof()
+	}
+
+	id := newBlake2ID(s.RemotePublicKey())
+	known, ok := store.Lookup(addr)
+	if ok && known != id {
+		n.events.PeerKeyChanged(newPeer(s), addr)
+		return errKeyChanged(addr)
+	}
+
+	if ok {
+		return nil
+	}
+
+	if mode == trustStrict {
+		return errUnknownPeer(addr)
+	}
+
+	log.Printf("pinning new peer identity for %s", addr)
+	if err := store.Pin(addr, id); err != nil {
+		log.Printf("error pinning peer identity: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,150 @@
+package noise
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/geolffreym/p2p-noise/config"
+)
+
+func TestKnownPeersFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "known_peers")
+	known, err := NewKnownPeersFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := known.Lookup(MOCK_ADDRESS); ok {
+		t.Errorf("expected empty store for missing file")
+	}
+
+	known.Pin(MOCK_ADDRESS, peerA.ID())
+	known.Pin("127.0.0.1:9999", peerB.ID())
+	known.Forget("127.0.0.1:9999")
+
+	restored, err := NewKnownPeersFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if id, ok := restored.Lookup(MOCK_ADDRESS); !ok || id != peerA.ID() {
+		t.Errorf("expected pinned peer restored from file")
+	}
+
+	if _, ok := restored.Lookup("127.0.0.1:9999"); ok {
+		t.Errorf("expected forgotten peer removed from file")
+	}
+}
+
+func TestKnownPeersInvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "known_peers")
+	os.WriteFile(path, []byte("# comment\n\n127.0.0.1:2379 invalid\n"), 0o600)
+
+	if _, err := NewKnownPeersFile(path); err == nil {
+		t.Errorf("expected error for invalid known peers file")
+	}
+}
+
+func TestTrustModes(t *testing.T) {
+	configurationA := config.New()
+	configurationA.Write(config.SetSelfListeningAddress("127.0.0.1:"))
+	nodeA := New(configurationA)
+	defer nodeA.Close()
+	<-whenReadyForIncomingDial(nodeA)
+	addr := nodeA.LocalAddr().String()
+
+	t.Run("tofu", func(t *testing.T) {
+		configurationB := config.New()
+		configurationB.Write(
+			config.SetTrustMode(config.TrustOnFirstUse),
+			config.SetKnownPeersPath(filepath.Join(t.TempDir(), "known_peers")),
+		)
+
+		nodeB := New(configurationB)
+		defer nodeB.Close()
+		if err := nodeB.Dial(addr); err != nil {
+			t.Fatal(err)
+		}
+
+		if id, ok := nodeB.knownPeers().Lookup(addr); !ok || id != nodeA.ID() {
+			t.Errorf("expected remote identity pinned on first dial")
+		}
+	})
+
+	t.Run("strict", func(t *testing.T) {
+		configurationB := config.New()
+		configurationB.Write(config.SetTrustMode(config.TrustStrict))
+
+		nodeB := New(configurationB)
+		defer nodeB.Close()
+		var secErr *SecError
+		if err := nodeB.Dial(addr); !errors.As(err, &secErr) {
+			t.Errorf("expected security error dialing unknown peer, got %v", err)
+		}
+
+		known, _ := NewKnownPeersFile("")
+		known.Pin(addr, nodeA.ID())
+		nodeB.SetKnownPeers(known)
+		if err := nodeB.Dial(addr); err != nil {
+			t.Errorf("expected pinned peer allowed, got %v", err)
+		}
+	})
+
+	t.Run("key changed", func(t *testing.T) {
+		configurationB := config.New()
+		configurationB.Write(config.SetTrustMode(config.TrustOnFirstUse))
+
+		nodeB := New(configurationB)
+		defer nodeB.Close()
+		known, _ := NewKnownPeersFile("")
+		known.Pin(addr, peerA.ID())
+		nodeB.SetKnownPeers(known)
+
+		signalsB, cancel := nodeB.Signals()
+		defer cancel()
+
+		var secErr *SecError
+		if err := nodeB.Dial(addr); !errors.As(err, &secErr) {
+			t.Errorf("expected security error for changed identity, got %v", err)
+		}
+
+		timeout := time.After(2 * time.Second)
+		for {
+			select {
+			case signal := <-signalsB:
+				if signal.Type() == PeerKeyChanged {
+					if signal.Payload() != addr {
+						t.Errorf("expected address %s in signal, got %s", addr, signal.Payload())
+					}
+
+					return
+				}
+			case <-timeout:
+				t.Fatalf("expected key changed signal")
+			}
+		}
+	})
+}
+
+func TestVerifyUnboundIdentity(t *testing.T) {
+	configuration := config.New()
+	configuration.Write(config.SetTrustMode(config.TrustOnFirstUse))
+	node := New(configuration)
+	defer node.Close()
+
+	known, _ := NewKnownPeersFile("")
+	node.SetKnownPeers(known)
+
+	// The signature key was not proved to be bound to the remote static key.
+	session := mockSession(&mockConn{}, PeerAPb)
+	if err := node.verifyKnownPeer(MOCK_ADDRESS, session); err == nil {
+		t.Errorf("expected error for unbound identity")
+	}
+
+	if _, ok := known.Lookup(MOCK_ADDRESS); ok {
+		t.Errorf("expected unbound identity not pinned")
+	}
+}
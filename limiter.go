@@ -0,0 +1,248 @@
+package noise
+
+import (
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Policies applied when a rate limit is exceeded.
+// The values must match the policies in config package.
+const (
+	policyDelay uint8 = iota
+	policyDrop
+	policyDisconnect
+)
+
+// bucket implements a token bucket refilled at rate tokens per second.
+// The bucket capacity is equal to the rate, so bursts up to one second of traffic are allowed.
+// A nil bucket means unlimited rate.
+type bucket struct {
+	mu     sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+// newBucket creates a full bucket for rate.
+// Returns nil if rate is 0, eg. unlimited.
+func newBucket(rate int) *bucket {
+	if rate <= 0 {
+		return nil
+	}
+
+	return &bucket{rate: float64(rate), tokens: float64(rate), last: time.Now()}
+}
+
+// refill adds the tokens earned since the last call.
+// The caller must hold the lock.
+func (b *bucket) refill() {
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.rate {
+		b.tokens = b.rate
+	}
+
+	b.last = now
+}
+
+// Reserve takes n tokens even if they aren't available yet.
+// It returns how long the caller should wait until the tokens are earned.
+func (b *bucket) Reserve(n int) time.Duration {
+	if b == nil {
+		return 0
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill()
+	b.tokens -= float64(n)
+	if b.tokens >= 0 {
+		return 0
+	}
+
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// Allow takes n tokens if they are available.
+// Requests bigger than the bucket capacity are allowed only when the bucket is full.
+func (b *bucket) Allow(n int) bool {
+	return allow(take{b, n})
+}
+
+// available returns true if n tokens can be taken from bucket.
+// The caller must hold the lock.
+func (b *bucket) available(n int) bool {
+	b.refill()
+	needed := float64(n)
+	if needed > b.rate {
+		needed = b.rate
+	}
+
+	return b.tokens >= needed
+}
+
+// take is a number of tokens requested from a bucket.
+type take struct {
+	b *bucket
+	n int
+}
+
+// allow takes the tokens from every bucket only if all of them have the tokens available,
+// so a denied request doesn't drain the buckets that would have allowed it.
+// Buckets are locked in order, callers must request the peer buckets before the global ones to avoid deadlocks.
+func allow(takes ...take) bool {
+	for _, t := range takes {
+		if t.b != nil {
+			t.b.mu.Lock()
+			defer t.b.mu.Unlock()
+		}
+	}
+
+	for _, t := range takes {
+		if t.b != nil && !t.b.available(t.n) {
+			return false
+		}
+	}
+
+	for _, t := range takes {
+		if t.b != nil {
+			t.b.tokens -= float64(t.n)
+		}
+	}
+
+	return true
+}
+
+// limiter bounds the incoming messages and bytes per second.
+// A nil limiter means unlimited rate.
+type limiter struct {
+	messages *bucket
+	bytes    *bucket
+}
+
+func newLimiter(messages, bytes int) *limiter {
+	return &limiter{newBucket(messages), newBucket(bytes)}
+}
+
+// Reserve register a message with size in bytes.
+// It returns how long the caller should wait to keep the rate.
+func (l *limiter) Reserve(size int) time.Duration {
+	if l == nil {
+		return 0
+	}
+
+	wait := l.messages.Reserve(1)
+	if delay := l.bytes.Reserve(size); delay > wait {
+		wait = delay
+	}
+
+	return wait
+}
+
+// Allow returns true if the message with size in bytes doesn't exceed the rate.
+func (l *limiter) Allow(size int) bool {
+	return allowAll(size, l)
+}
+
+// takes returns the tokens requested from limiter for a message with size in bytes.
+func (l *limiter) takes(size int) []take {
+	if l == nil {
+		return nil
+	}
+
+	return []take{{l.messages, 1}, {l.bytes, size}}
+}
+
+// allowAll returns true if the message with size in bytes doesn't exceed the rate of any limiter.
+// The tokens are taken only if every limiter allows the message.
+func allowAll(size int, limiters ...*limiter) bool {
+	var takes []take
+	for _, l := range limiters {
+		takes = append(takes, l.takes(size)...)
+	}
+
+	return allow(takes...)
+}
+
+// [ThrottleStats] counts the incoming messages throttled by rate limits.
+type ThrottleStats struct {
+	// Messages delayed to keep the rate.
+	Delayed uint64
+	// Messages discarded.
+	Dropped uint64
+	// Peers disconnected.
+	Disconnected uint64
+}
+
+// throttling keeps the global rate limits and throttling counters.
+type throttling struct {
+	global       *limiter
+	delayed      atomic.Uint64
+	dropped      atomic.Uint64
+	disconnected atomic.Uint64
+}
+
+// Throttled returns the number of throttling events since the node started.
+func (n *Node) Throttled() ThrottleStats {
+	return ThrottleStats{
+		Delayed:      n.throttling.delayed.Load(),
+		Dropped:      n.throttling.dropped.Load(),
+		Disconnected: n.throttling.disconnected.Load(),
+	}
+}
+
+// throttle applies the peer and global rate limits to incoming message.
+// The size is the number of bytes read from the wire for the frame.
+// Depending on the policy the call blocks until the rate allows the message, or the message is dropped,
+// or the peer is disconnected.
+// It returns true if the message should be dispatched.
+func (n *Node) throttle(peer *peer, size int) bool {
+	switch n.config.RateLimitPolicy() {
+	case policyDrop:
+		if allowAll(size, peer.limits, n.throttling.global) {
+			return true
+		}
+
+		n.throttling.dropped.Add(1)
+		return false
+	case policyDisconnect:
+		if allowAll(size, peer.limits, n.throttling.global) {
+			return true
+		}
+
+		// Peer already disconnecting, discard any remaining message.
+		if _, closing := peer.Closing(); closing {
+			return false
+		}
+
+		n.throttling.disconnected.Add(1)
+		log.Printf("disconnecting peer exceeding rate limit: %s", peer.s.RemoteAddr())
+		if err := n.ClosePeer(peer.ID().String(), ReasonRateLimited); err != nil {
+			log.Printf("error closing peer: %v", err)
+		}
+
+		return false
+	default:
+		wait := peer.limits.Reserve(size)
+		if delay := n.throttling.global.Reserve(size); delay > wait {
+			wait = delay
+		}
+
+		if wait <= 0 {
+			return true
+		}
+
+		// Stop reading from peer, the remote peer is pushed back when the socket buffers get full.
+		n.throttling.delayed.Add(1)
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-n.done:
+		}
+
+		return true
+	}
+}
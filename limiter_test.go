@@ -0,0 +1,149 @@
+package noise
+
+import (
+	"testing"
+	"time"
+
+	"github.com/geolffreym/p2p-noise/config"
+)
+
+func TestBucketAllow(t *testing.T) {
+	b := newBucket(3)
+	allowed := []struct {
+		n        int
+		expected bool
+	}{
+		{1, true},
+		{2, true},
+		{1, false},
+	}
+
+	for _, e := range allowed {
+		if b.Allow(e.n) != e.expected {
+			t.Errorf("expected allow %d tokens = %v", e.n, e.expected)
+		}
+	}
+
+	// Requests bigger than capacity are allowed with a full bucket.
+	big := newBucket(10)
+	if !big.Allow(100) || big.Allow(1) {
+		t.Errorf("expected big request allowed only with full bucket")
+	}
+}
+
+func TestBucketReserve(t *testing.T) {
+	b := newBucket(10)
+	if wait := b.Reserve(10); wait != 0 {
+		t.Errorf("expected no wait within capacity, got %v", wait)
+	}
+
+	wait := b.Reserve(5)
+	if wait < 400*time.Millisecond || wait > 500*time.Millisecond {
+		t.Errorf("expected ~500ms wait for 5 tokens at 10/s, got %v", wait)
+	}
+}
+
+func TestAllowAll(t *testing.T) {
+	peer := newLimiter(2, 0)
+	global := newLimiter(1, 0)
+	if !allowAll(10, peer, global) {
+		t.Fatalf("expected first message allowed")
+	}
+
+	// Denied by global limit without draining the peer limit.
+	if allowAll(10, peer, global) {
+		t.Errorf("expected message denied by global limit")
+	}
+
+	if peer.messages.tokens < 1 {
+		t.Errorf("expected peer tokens kept for denied message, got %v", peer.messages.tokens)
+	}
+}
+
+func TestUnlimitedRate(t *testing.T) {
+	var l *limiter
+	if !l.Allow(1<<20) || l.Reserve(1<<20) != 0 {
+		t.Errorf("expected nil limiter unlimited")
+	}
+
+	l = newLimiter(0, 0)
+	if !l.Allow(1<<20) || l.Reserve(1<<20) != 0 {
+		t.Errorf("expected zero rate unlimited")
+	}
+}
+
+func TestRateLimitPolicies(t *testing.T) {
+	policies := []struct {
+		name     string
+		policy   uint8
+		received int
+		stats    func(ThrottleStats) bool
+	}{
+		{"delay", config.PolicyDelay, 6, func(s ThrottleStats) bool { return s.Delayed > 0 }},
+		{"drop", config.PolicyDrop, 2, func(s ThrottleStats) bool { return s.Dropped == 4 }},
+		{"disconnect", config.PolicyDisconnect, 2, func(s ThrottleStats) bool { return s.Disconnected == 1 }},
+	}
+
+	for _, e := range policies {
+		t.Run(e.name, func(t *testing.T) {
+			configurationA := config.New()
+			configurationA.Write(
+				config.SetSelfListeningAddress("127.0.0.1:"),
+				// The dht announcement sent on connect counts towards the limit.
+				config.SetPeerRateLimit(3, 0),
+				config.SetRateLimitPolicy(e.policy),
+			)
+
+			nodeA := New(configurationA)
+			// No dht lookups from B, only the announcement sent on connect.
+			configurationB := config.New()
+			configurationB.Write(config.SetDHTRefreshInterval(0))
+			nodeB := New(configurationB)
+			defer nodeA.Close()
+			defer nodeB.Close()
+
+			signalsA, cancelA := nodeA.Signals()
+			defer cancelA()
+			signalsB, cancelB := nodeB.Signals()
+			defer cancelB()
+
+			go nodeA.Listen()
+			for signal := range signalsA {
+				if signal.Type() == SelfListening {
+					break
+				}
+			}
+
+			if err := nodeB.Dial(nodeA.LocalAddr().String()); err != nil {
+				t.Fatal(err)
+			}
+
+			id := nodeA.ID().String()
+			for i := 0; i < 6; i++ {
+				nodeB.Send(id, []byte("ping"))
+			}
+
+			received := 0
+			timeout := time.After(5 * time.Second)
+			for received < e.received || !e.stats(nodeA.Throttled()) {
+				select {
+				case signal := <-signalsA:
+					if signal.Type() == MessageReceived {
+						received++
+					}
+				case signal := <-signalsB:
+					if signal.Type() == PeerDisconnected && signal.Reason() != ReasonRateLimited {
+						t.Errorf("expected reason %s, got %s", ReasonRateLimited, signal.Reason())
+					}
+				case <-time.After(10 * time.Millisecond):
+				case <-timeout:
+					t.Fatalf("expected %d messages received, got %d with stats %+v", e.received, received, nodeA.Throttled())
+				}
+			}
+
+			if received != e.received {
+				t.Errorf("expected %d messages received, got %d", e.received, received)
+			}
+		})
+	}
+}
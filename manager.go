@@ -0,0 +1,211 @@
+package noise
+
+import (
+	"log"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// persistentTag protect the persistent peers from trimming.
+const persistentTag = "persistent"
+
+// manager keeps the tags and protections used to decide which peers are closed
+// when the number of connections exceeds the high watermark.
+// Tags add value to peers, so the less valuable peers are closed first.
+// Protected peers are never closed by connection manager.
+type manager struct {
+	mu        sync.Mutex
+	tags      map[ID]map[string]int
+	protected map[ID]map[string]struct{}
+	trimming  atomic.Bool // only one trimming running at time
+}
+
+func newManager() *manager {
+	return &manager{
+		tags:      make(map[ID]map[string]int),
+		protected: make(map[ID]map[string]struct{}),
+	}
+}
+
+// Protect protects the peer from trimming using tag.
+// A peer can be protected by many tags, eg. one for each subsystem interested in peer.
+func (m *manager) Protect(id ID, tag string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.protected[id]; !ok {
+		m.protected[id] = make(map[string]struct{})
+	}
+
+	m.protected[id][tag] = struct{}{}
+}
+
+// Unprotect removes the protection tag from peer.
+// It returns true if the peer is still protected by other tags.
+func (m *manager) Unprotect(id ID, tag string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	tags, ok := m.protected[id]
+	if !ok {
+		return false
+	}
+
+	delete(tags, tag)
+	if len(tags) == 0 {
+		delete(m.protected, id)
+		return false
+	}
+
+	return true
+}
+
+// Protected returns true if the peer is protected by any tag.
+func (m *manager) Protected(id ID) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.protected[id]
+	return ok
+}
+
+// Tag sets a value for the peer tag.
+func (m *manager) Tag(id ID, tag string, value int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.tags[id]; !ok {
+		m.tags[id] = make(map[string]int)
+	}
+
+	m.tags[id][tag] = value
+}
+
+// Untag removes the tag from peer.
+func (m *manager) Untag(id ID, tag string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	tags, ok := m.tags[id]
+	if !ok {
+		return
+	}
+
+	delete(tags, tag)
+	if len(tags) == 0 {
+		delete(m.tags, id)
+	}
+}
+
+// Value returns the sum of tags values for peer.
+func (m *manager) Value(id ID) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	value := 0
+	for _, v := range m.tags[id] {
+		value += v
+	}
+
+	return value
+}
+
+// candidate hold a snapshot of the peer state to sort peers by value.
+type candidate struct {
+	peer    *peer
+	value   int
+	idle    time.Duration
+	traffic uint64
+}
+
+// Protect protects the peer from connection manager trimming using tag.
+func (n *Node) Protect(rawID string, tag string) {
+	n.manager.Protect(newIDFromString(rawID), tag)
+}
+
+// Unprotect removes the protection tag from peer.
+// It returns true if the peer is still protected by other tags.
+func (n *Node) Unprotect(rawID string, tag string) bool {
+	return n.manager.Unprotect(newIDFromString(rawID), tag)
+}
+
+// TagPeer sets a value for the peer tag.
+// The sum of values is used by connection manager to close the less valuable peers first.
+func (n *Node) TagPeer(rawID string, tag string, value int) {
+	n.manager.Tag(newIDFromString(rawID), tag, value)
+}
+
+// UntagPeer removes the tag from peer.
+func (n *Node) UntagPeer(rawID string, tag string) {
+	n.manager.Untag(newIDFromString(rawID), tag)
+}
+
+// exceeded returns true if the connection manager is enabled and the number of connections exceeds the high watermark.
+func (n *Node) exceeded() bool {
+	high := n.config.HighWatermark()
+	return high > 0 && n.router.Len() > high
+}
+
+// trimPeers closes the less valuable peers until the low watermark is reached.
+// If a trimming is already running the call has no effect.
+func (n *Node) trimPeers() {
+	if !n.manager.trimming.CompareAndSwap(false, true) {
+		return
+	}
+
+	defer n.manager.trimming.Store(false)
+	closed := n.trim(int(n.config.LowWatermark()))
+	log.Printf("connection manager closed %d peers", closed)
+}
+
+// trim closes the less valuable peers until the number of connections is equal to target.
+// Protected peers and fresh connections during grace period are never closed.
+// Peers are sorted by tags value, then by idle time and then by traffic exchanged.
+// It returns the number of closed peers.
+func (n *Node) trim(target int) int {
+	excess := int(n.router.Len()) - target
+	if excess <= 0 {
+		return 0
+	}
+
+	grace := n.config.GracePeriod()
+	candidates := make([]candidate, 0, n.router.Len())
+	for peer := range n.router.Table() {
+		if peer.Age() < grace || n.manager.Protected(peer.ID()) {
+			continue
+		}
+
+		candidates = append(candidates, candidate{
+			peer,
+			n.manager.Value(peer.ID()),
+			peer.Idle().Truncate(time.Second),
+			peer.m.Traffic(),
+		})
+	}
+
+	// Less valuable peers first.
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.value != b.value {
+			return a.value < b.value
+		}
+
+		if a.idle != b.idle {
+			return a.idle > b.idle
+		}
+
+		return a.traffic < b.traffic
+	})
+
+	if excess > len(candidates) {
+		excess = len(candidates)
+	}
+
+	closed := 0
+	for _, c := range candidates[:excess] {
+		if err := n.ClosePeer(c.peer.ID().String(), ReasonTrimmed); err != nil {
+			log.Printf("error trimming peer: %v", err)
+			continue
+		}
+
+		closed++
+	}
+
+	return closed
+}
@@ -0,0 +1,133 @@
+package noise
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/geolffreym/p2p-noise/config"
+)
+
+func TestManagerProtect(t *testing.T) {
+	manager := newManager()
+	id := peerA.ID()
+
+	manager.Protect(id, "a")
+	manager.Protect(id, "b")
+
+	if !manager.Unprotect(id, "a") {
+		t.Errorf("expected peer still protected by other tags")
+	}
+
+	if !manager.Protected(id) {
+		t.Errorf("expected protected peer")
+	}
+
+	if manager.Unprotect(id, "b") || manager.Protected(id) {
+		t.Errorf("expected peer not protected after remove all tags")
+	}
+}
+
+func TestManagerTagValue(t *testing.T) {
+	manager := newManager()
+	id := peerA.ID()
+
+	manager.Tag(id, "a", 10)
+	manager.Tag(id, "b", 5)
+	if manager.Value(id) != 15 {
+		t.Errorf("expected peer value %d, got %d", 15, manager.Value(id))
+	}
+
+	manager.Untag(id, "a")
+	if manager.Value(id) != 5 {
+		t.Errorf("expected peer value %d after untag, got %d", 5, manager.Value(id))
+	}
+}
+
+func TestTrimLessValuablePeers(t *testing.T) {
+	configurationA := config.New()
+	configurationA.Write(
+		config.SetSelfListeningAddress("127.0.0.1:"),
+		config.SetWatermarks(2, 2),
+		config.SetGracePeriod(0),
+	)
+
+	nodeA := New(configurationA)
+	nodeB := New(config.New())
+	nodeC := New(config.New())
+	nodeD := New(config.New())
+	defer nodeA.Close()
+	defer nodeB.Close()
+	defer nodeC.Close()
+	defer nodeD.Close()
+
+	// C is protected and B is more valuable than D.
+	nodeA.Protect(nodeC.ID().String(), "test")
+	nodeA.TagPeer(nodeB.ID().String(), "test", 10)
+
+	<-whenReadyForIncomingDial(nodeA)
+	signalsD, cancel := nodeD.Signals()
+	defer cancel()
+
+	addr := nodeA.LocalAddr().String()
+	for _, node := range []*Node{nodeB, nodeC, nodeD} {
+		if err := node.Dial(addr); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The less valuable peer is notified about trimming.
+	timeout := time.After(2 * time.Second)
+	for trimmed := false; !trimmed; {
+		select {
+		case signal := <-signalsD:
+			trimmed = signal.Type() == PeerDisconnected && signal.Reason() == ReasonTrimmed
+		case <-timeout:
+			t.Fatalf("expected less valuable peer trimmed")
+		}
+	}
+
+	_, okB := nodeA.router.Query(nodeB.ID())
+	_, okC := nodeA.router.Query(nodeC.ID())
+	if !okB || !okC {
+		t.Errorf("expected valuable and protected peers kept, got B=%v C=%v", okB, okC)
+	}
+}
+
+func TestTrimOnlyAdmittedPeers(t *testing.T) {
+	configurationA := config.New()
+	configurationA.Write(
+		config.SetSelfListeningAddress("127.0.0.1:"),
+		config.SetMaxPeersConnected(1),
+		config.SetWatermarks(1, 1),
+		config.SetGracePeriod(0),
+		config.SetHandshakeTimeout(100*time.Millisecond),
+	)
+
+	nodeA := New(configurationA)
+	nodeB := New(config.New())
+	defer nodeA.Close()
+	defer nodeB.Close()
+
+	<-whenReadyForIncomingDial(nodeA)
+	addr := nodeA.LocalAddr().String()
+	if err := nodeB.Dial(addr); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 100 && !nodeA.connected(nodeB.ID()); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// An unauthenticated connection never completes the handshake.
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer conn.Close()
+	time.Sleep(300 * time.Millisecond)
+	if _, ok := nodeA.router.Query(nodeB.ID()); !ok {
+		t.Errorf("expected connected peer kept after unauthenticated connection")
+	}
+}
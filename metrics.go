@@ -1,5 +1,7 @@
 package noise
 
+import "sync/atomic"
+
 // metrics hold the statistics related to remote peers.
 // We can add any method related to adaptive lookup logic here.
 // Please see [docs] for more information.
@@ -25,6 +27,35 @@ type metrics struct {
 	bytesSent     uint64 // bytes sent: 8 bytes
 }
 
+// Recv register a new received message with size in bytes.
+// Only the peer listening routine should call it.
+func (m *metrics) Recv(size int) {
+	m.recv++
+	atomic.AddUint64(&m.bytesRecv, uint64(size))
+}
+
+// Sent register a new sent message with size in bytes.
+// Calls should be serialized with peer writes.
+func (m *metrics) Sent(size int) {
+	m.sent++
+	atomic.AddUint64(&m.bytesSent, uint64(size))
+}
+
+// Traffic return the total of bytes exchanged with remote peer.
+func (m *metrics) Traffic() uint64 {
+	return atomic.LoadUint64(&m.bytesRecv) + atomic.LoadUint64(&m.bytesSent)
+}
+
+// BytesSent return the total of bytes sent to remote peer.
+func (m *metrics) BytesSent() uint64 {
+	return atomic.LoadUint64(&m.bytesSent)
+}
+
+// BytesRecv return the total of bytes received from remote peer.
+func (m *metrics) BytesRecv() uint64 {
+	return atomic.LoadUint64(&m.bytesRecv)
+}
+
 // TODO https://community.f5.com/t5/technical-articles/introducing-tcp-analytics/ta-p/290873
 // calculate weight
 // builder pattern?
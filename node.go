@@ -5,13 +5,15 @@
 package noise
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net"
+	"sync"
+	"sync/atomic"
 	"time"
-
-	"github.com/oxtoacart/bpool"
 )
 
 // futureDeadline calculate and return a new time for deadline since now.
@@ -26,6 +28,19 @@ func futureDeadLine(deadline time.Duration) time.Time {
 	return time.Now().Add(deadline * time.Second)
 }
 
+// goodbyeTimeout is the minimum time to wait for remote peers to close the connection after goodbye.
+// It is used when Linger is not set, so a default close is still graceful.
+const goodbyeTimeout = 2 * time.Second
+
+// lingerTimeout return the time to wait for remote peers to close the connection after goodbye.
+func lingerTimeout(linger int) time.Duration {
+	if wait := time.Duration(linger) * time.Second; wait > goodbyeTimeout {
+		return wait
+	}
+
+	return goodbyeTimeout
+}
+
 type Config interface {
 	// Default "tcp"
 	Protocol() string
@@ -34,46 +49,281 @@ type Config interface {
 	// Default 0
 	Linger() int
 	// Default 100
-	MaxPeersConnected() uint8
+	MaxPeersConnected() uint32
+	// Default 0
+	LowWatermark() uint32
+	// Default 0 = disabled
+	HighWatermark() uint32
+	// Default 30 seconds
+	GracePeriod() time.Duration
+	// Default 1 hour
+	BanDuration() time.Duration
+	// Default ""
+	BanListPath() string
+	// Default 3
+	MaxBadSignatures() int
+	// Default 3
+	MaxOversizedFrames() int
+	// Default 10
+	MaxHandshakeFailures() int
+	// Default 0
+	PeerMessageRate() int
+	// Default 0
+	PeerByteRate() int
+	// Default 0
+	GlobalMessageRate() int
+	// Default 0
+	GlobalByteRate() int
+	// Default 0 = delay
+	RateLimitPolicy() uint8
+	// Default 128
+	MaxPendingHandshakes() uint32
+	// Default 8
+	MaxHandshakesPerIP() uint32
+	// Default 0 = off
+	TrustMode() uint8
+	// Default ""
+	KnownPeersPath() string
+	// Default nil
+	IdentityKey() []byte
+	// Default nil
+	Certificate() []byte
+	// Default 20
+	DHTBucketSize() int
+	// Default 3
+	DHTConcurrency() int
+	// Default 10 minutes
+	DHTRefreshInterval() time.Duration
+	// Default 24 hours
+	RecordTTL() time.Duration
+	// Default 1 hour
+	RecordRepublishInterval() time.Duration
+	// Default 4096
+	MaxRecords() int
+	// Default 16
+	MaxPublisherRecords() int
+	// Default 0 = disabled
+	TargetDegree() int
+	// Default 8
+	MaxAddressesPerSource() int
+	// Default nil
+	BootstrapPeers() []string
+	// Default 1
+	MinBootstrapPeers() int
+	// Default "" = disabled
+	DiscoveryAddress() string
+	// Default ""
+	DiscoveryInterface() string
+	// Default 10 seconds
+	DiscoveryInterval() time.Duration
+	// Default ""
+	PeerStorePath() string
+	// Default 8
+	RedialKnownPeers() int
+	// Default 6
+	PubSubDegree() int
+	// Default 1 second
+	PubSubHeartbeat() time.Duration
+	// Default false
+	Forwarding() bool
+	// Default 8
+	MaxHops() int
 	// Default 10 << 20 = 10MB
 	PoolBufferSize() int
 	// Default 0
 	IdleTimeout() time.Duration
 	// Default 5 seconds
 	DialTimeout() time.Duration
+	// Default 10 seconds
+	HandshakeTimeout() time.Duration
 	// Default 1800 seconds
 	KeepAlive() time.Duration
+	// Default 1 second
+	ReconnectBackoff() time.Duration
+	// Default 60 seconds
+	MaxReconnectBackoff() time.Duration
+	// Default 10
+	MaxReconnectAttempts() int
 }
 
 // Node represents a network node capable of handling connections,
 // routing messages, and managing configurations.
 type Node struct {
+	// Guard listener and closed state.
+	mu sync.Mutex
+	// Track running watch and handshake routines.
+	wg sync.WaitGroup
+	// Closed when node is shutting down.
+	done chan struct{}
+	// Set when node is shutting down.
+	closed bool
 	// Bound local network listener.
 	listener net.Listener
+	// Persistent peers addresses eg. {Address: stop channel}.
+	persistent map[string]chan struct{}
+	// Local keys used as node identity for every handshake.
+	kr    KeyRing
+	krErr error
+	id    ID
+	once  sync.Once
 	// Routing hash table eg. {Socket: Conn interface}.
 	router *router
+	// Connection manager tags and protections.
+	manager *manager
+	// Allow/deny decisions for connections.
+	gater ConnectionGater
 	// Pubsub notifications.
 	events *events
+	// Banned peers and addresses
+	bans *banList
+	// Global rate limits and throttling counters
+	throttling *throttling
+	// Incoming handshakes limits
+	admission *admission
+	// Pinned identities for dialed addresses
+	known KnownPeers
+	// Remote certificates validation
+	verifier CertificateVerifier
+	// Kademlia routing table and pending requests
+	dht *dht
+	// Records stored for the DHT
+	records RecordStore
+	// Peer exchange address book
+	pex *pex
+	// Known peers addresses and metadata
+	peers PeerStore
+	pmu   sync.Mutex // serialize peerstore updates
+	// Topic subscriptions and mesh
+	pubsub *pubsub
+	// Broadcast sequence and seen messages
+	flood *flood
+	// Learned routes and seen forwarded messages
+	forward *forwarder
+	// Connected peers needed to emit the Bootstrapped signal, zero if not bootstrapping
+	bootstrapMin atomic.Int32
+	bootstrapped atomic.Bool
 	// Global buffer pool
-	pool BytePool
+	pool *bufferPool
 	// Configuration settings
 	config Config
 }
 
 // New create a new node with defaults
 func New(config Config) *Node {
-	// Max allowed "pools" is related to max active peers.
-	maxPools := int(config.MaxPeersConnected())
 	// Width of global pool buffer
+	// Buffers are allocated on demand based on message size, so memory is not related to max active peers.
 	maxBufferSize := config.PoolBufferSize()
-	pool := bpool.NewBytePool(maxPools, maxBufferSize)
+	pool := newBufferPool(maxBufferSize)
+	// Restore the bans from previous runs.
+	bans := newBanList(config.BanListPath())
+	if err := bans.Load(); err != nil {
+		log.Printf("error loading ban list: %v", err)
+	}
+
+	// Pinned identities from previous runs.
+	var known KnownPeers
+	if config.TrustMode() != trustOff {
+		store, err := NewKnownPeersFile(config.KnownPeersPath())
+		if err != nil {
+			log.Printf("error loading known peers: %v", err)
+			store, _ = NewKnownPeersFile("")
+		}
+
+		known = store
+	}
+
+	// Known peers from previous runs.
+	peers, err := NewPeerStoreFile(config.PeerStorePath())
+	if err != nil {
+		log.Printf("error loading peerstore: %v", err)
+		peers, _ = NewPeerStoreFile("")
+	}
 
 	return &Node{
-		router: newRouter(),
-		events: newEvents(),
-		pool:   pool,
-		config: config,
+		done:       make(chan struct{}),
+		persistent: make(map[string]chan struct{}),
+		router:     newRouter(),
+		manager:    newManager(),
+		events:     newEvents(),
+		bans:       bans,
+		admission:  newAdmission(),
+		known:      known,
+		dht:        newDHT(),
+		records:    NewMemoryRecordStore(config.MaxRecords(), config.MaxPublisherRecords()),
+		pex:        newPEX(config.MaxAddressesPerSource()),
+		peers:      peers,
+		pubsub:     newPubSub(),
+		flood:      newFlood(),
+		forward:    newForwarder(),
+		throttling: &throttling{global: newLimiter(config.GlobalMessageRate(), config.GlobalByteRate())},
+		pool:       pool,
+		config:     config,
+	}
+}
+
+// keyRing returns the local keys used as node identity.
+// The keys are generated once the first time they are needed.
+// If an identity key is set in config it is used instead of a fresh one.
+// A certificate can't be presented without the identity key it certifies.
+func (n *Node) keyRing() (KeyRing, error) {
+	n.once.Do(func() {
+		if identity := n.config.IdentityKey(); identity != nil {
+			n.kr, n.krErr = newIdentityKeyRing(identity, n.config.Certificate())
+		} else if n.krErr = n.checkIdentity(); n.krErr == nil {
+			n.kr, n.krErr = newKeyRing()
+		}
+
+		n.id = newBlake2ID(n.kr.sv.Public)
+	})
+
+	return n.kr, n.krErr
+}
+
+// checkIdentity returns an error if a certificate is set in config without the identity key it certifies.
+func (n *Node) checkIdentity() error {
+	if n.config.IdentityKey() == nil && n.config.Certificate() != nil {
+		return errCertificateWithoutIdentity()
 	}
+
+	return nil
+}
+
+// ID returns the local node identity.
+// The ID is the blake2 hashed local public key shared with remote peers during handshake.
+func (n *Node) ID() ID {
+	if _, err := n.keyRing(); err != nil {
+		return ID{}
+	}
+
+	return n.id
+}
+
+// SetConnectionGater sets the gater consulted before dialing, on accepting connections and after handshake.
+// A nil gater allows any connection.
+func (n *Node) SetConnectionGater(gater ConnectionGater) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.gater = gater
+}
+
+// connectionGater returns the current connection gater.
+func (n *Node) connectionGater() ConnectionGater {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.gater
+}
+
+// track register a new running routine to wait for during shutdown.
+// It returns false if the node is shutting down.
+func (n *Node) track() bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.closed {
+		return false
+	}
+
+	n.wg.Add(1)
+	return true
 }
 
 // Signals initiates the signaling process to proxy channels to subscribers.
@@ -89,6 +339,7 @@ func (n *Node) Signals() (<-chan Signal, context.CancelFunc) {
 }
 
 // Disconnect close all the peer connections without stop listening.
+// Please see ClosePeer to notify the remote peers before closing.
 func (n *Node) Disconnect() {
 	log.Print("closing connections and shutting down node..")
 	for peer := range n.router.Table() {
@@ -102,53 +353,156 @@ func (n *Node) Disconnect() {
 // It returns the total bytes sent if there is no error; otherwise, it returns 0.
 // If the peer ID doesn't exist or the peer is not connected, it returns an error.
 // Calling Send extends the write deadline.
+// Please see SendContext to bound the static key lookup of forwarded messages.
 func (n *Node) Send(rawID string, message []byte) (uint32, error) {
+	return n.SendContext(context.Background(), rawID, message)
+}
+
+// SendContext emits a new message using a peer ID.
+// If forwarding is enabled and the peer is not connected, the message is encrypted end-to-end to peer and
+// forwarded through the closest connected peer. The destination receives a RelayedMessageReceived signal, if
+// the message can't be delivered the node receives a DeliveryFailed signal. The context bounds the lookup of
+// the peer static key in the DHT.
+// It returns the total bytes sent to the connected or next hop peer if there is no error; otherwise, it returns 0.
+func (n *Node) SendContext(ctx context.Context, rawID string, message []byte) (uint32, error) {
 	id := newIDFromString(rawID)
 	// Check if id exists in connected peers
 	// check in-band error
 	peer, ok := n.router.Query(id)
+	if !ok && n.config.Forwarding() {
+		return n.forwardTo(ctx, id, message)
+	}
+
 	if !ok {
 		err := fmt.Errorf("remote peer disconnected: %s", id.String())
 		return 0, errSendingMessage(err)
 	}
 
 	bytes, err := peer.Send(message)
+	if err != nil {
+		return 0, err
+	}
+
 	// An idle timeout can be implemented by repeatedly extending
 	// the deadline after successful Read or Write calls.
 	idle := futureDeadLine(n.config.IdleTimeout())
 	peer.SetDeadline(idle)
-	return bytes, err
+	return bytes, nil
+}
+
+// ClosePeer sends a goodbye with the reason to the remote peer and removes the peer from router.
+// The connection is closed when the remote peer acknowledges the goodbye or after Linger seconds, at least goodbyeTimeout.
+// Both local and remote node are notified with a PeerDisconnected signal carrying the reason.
+// If the peer ID doesn't exist or the peer is not connected, it returns an error.
+func (n *Node) ClosePeer(rawID string, reason Reason) error {
+	id := newIDFromString(rawID)
+	peer, ok := n.router.Query(id)
+	if !ok {
+		err := fmt.Errorf("remote peer disconnected: %s", id.String())
+		return errClosingPeer(err)
+	}
+
+	// No more messages are routed to peer.
+	n.router.Remove(peer)
+	if err := peer.Goodbye(reason); err != nil {
+		peer.Close()
+		return errClosingPeer(err)
+	}
+
+	// Wait for remote peer to close the connection up to linger seconds.
+	linger := lingerTimeout(n.config.Linger())
+	peer.SetDeadline(time.Now().Add(linger))
+	return nil
+}
+
+// drop removes the peer from router and notify about the remote peer state.
+// Replaced peers are dropped quietly since the remote peer is still connected.
+func (n *Node) drop(peer *peer, reason Reason) {
+	defer close(peer.done)
+	if peer.Replaced() {
+		log.Print("replaced peer connection closed")
+		return
+	}
+
+	log.Printf("peer disconnected: %s", reason)
+	// Notify about the remote peer state
+	n.events.PeerDisconnected(peer, reason)
+	// Remove peer from router table
+	n.router.Remove(peer)
+	// Keep the connection history.
+	n.rememberMetrics(peer)
+	// Forget the peer topic subscriptions.
+	n.unsubscribe(peer.ID())
+	// Forget the routes through peer.
+	n.forward.Forget(peer.ID())
+	// Forget the address requests and replace the lost peer if target degree is set.
+	n.pex.Forget(peer.ID())
+	n.pex.Wake()
 }
 
 // watch keeps running, waiting for incoming messages.
 // After receiving each new message, the connection is verified. If the local connection is closed or the remote peer is disconnected, the routine stops.
 // It is suggested to process incoming messages in separate goroutines.
 func (n *Node) watch(peer *peer) {
+	defer n.wg.Done()
 
-KEEPALIVE:
 	for {
 
 		// Waiting for new incoming message
-		buf, err := peer.Listen()
+		packet, err := peer.Listen()
 		if err != nil {
 			// net: don't return io.EOF from zero byte reads
-			// Notify about the remote peer state
-			n.events.PeerDisconnected(peer)
-			// Remove peer from router table
-			n.router.Remove(peer)
+			// If goodbye was sent the reason is already known.
+			reason, closing := peer.Closing()
+			if !closing {
+				reason = reasonFromError(err)
+				// Invalid or oversized messages count towards ban the peer.
+				if n.misbehaved(peer, err) {
+					reason = ReasonBanned
+				}
+			}
+
+			peer.Close()
+			n.drop(peer, reason)
 			return
 		}
 
-		if buf == nil {
-			log.Printf("buffer nil with err: %v", err)
-			// `buf` is nil if no more bytes received but peer is still connected
-			// Keep alive always that zero bytes are not received
-			break KEEPALIVE
+		if packet.Frame == goodbyeFrame {
+			// The remote peer is closing the connection, close our side too.
+			peer.Close()
+			n.drop(peer, reasonFromBytes(packet.Msg))
+			return
+		}
+
+		// Apply the incoming rate limits before dispatch any frame.
+		// Control frames trigger work and replies, so they are limited as application messages.
+		if !n.throttle(peer, packet.size) {
+			continue
+		}
+
+		switch packet.Frame {
+		case dhtFrame:
+			n.handleDHT(peer, packet.Msg)
+		case pexFrame:
+			n.handlePEX(peer, packet.Msg)
+		case pubsubFrame:
+			n.handlePubSub(peer, packet.Msg)
+		case broadcastFrame:
+			n.handleBroadcast(peer, packet.Msg)
+		case forwardFrame:
+			n.handleForward(peer, packet.Msg)
+		default:
+			log.Print("receiving message from remote")
+			// Emit new incoming message notification
+			n.events.NewMessage(peer, packet.Msg)
+		}
+
+		n.rememberProtocol(peer, packet.Frame)
+		// If goodbye was sent keep the closing deadline.
+		if _, closing := peer.Closing(); closing {
+			continue
 		}
 
-		log.Print("receiving message from remote")
-		// Emit new incoming message notification
-		n.events.NewMessage(peer, buf)
 		// An idle timeout can be implemented by repeatedly extending
 		// the deadline after successful Read or Write calls.
 		idle := futureDeadLine(n.config.IdleTimeout())
@@ -178,60 +532,299 @@ func (n *Node) setupTCPConnection(conn *net.TCPConn) error {
 	return nil
 }
 
+// handshakeDeadline calculate the deadline for the handshake using the configured handshake timeout.
+// If the context has an earlier deadline then the context deadline is used instead.
+func (n *Node) handshakeDeadline(ctx context.Context) time.Time {
+	var deadline time.Time
+	if timeout := n.config.HandshakeTimeout(); timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+
+	// the closest deadline wins
+	if d, ok := ctx.Deadline(); ok && (deadline.IsZero() || d.Before(deadline)) {
+		deadline = d
+	}
+
+	return deadline
+}
+
+// watchContext aborts any pending I/O in connection when the context is canceled or the node is shutting down.
+// It returns a stop function that must be called to release the watching routine.
+// After stop returns the connection deadline is never changed by the watching routine.
+func (n *Node) watchContext(ctx context.Context, conn net.Conn) func() {
+	done := make(chan struct{})
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		select {
+		case <-ctx.Done():
+			// A deadline in the past unblock any pending Read or Write.
+			conn.SetDeadline(time.Unix(1, 0))
+		case <-n.done:
+			conn.SetDeadline(time.Unix(1, 0))
+		case <-done:
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+		<-exited
+	}
+}
+
+// background returns a context canceled when the node is shutting down.
+// It is used by the background routines eg. DHT refresh.
+func (n *Node) background() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		select {
+		case <-n.done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	return ctx, cancel
+}
+
 // handshake initiates a new handshake for an incoming or dialed connection.
+// The dialed address is used to verify the remote identity, for incoming connections it is empty.
 // After the handshake completes, a new session is created, and a new peer is added to the router.
 // If the TCP protocol is used, the connection is enforced to keep alive.
-// Returns an error if the maximum number of connected peers exceeds MaxPeersConnected; otherwise, returns nil.
-func (n *Node) handshake(conn net.Conn, initialize bool) error {
+// The handshake is aborted if the context is canceled or the handshake timeout is exceeded.
+// Returns an error if the maximum number of connected peers exceeds MaxPeersConnected; otherwise, returns the peer
+// and true if the peer was connected by this handshake, or false if an existing connection with the peer was kept.
+func (n *Node) handshake(ctx context.Context, conn net.Conn, initialize bool, addr string) (*peer, bool, error) {
+	// Shutdown should wait for running handshakes.
+	if !n.track() {
+		conn.Close()
+		return nil, false, errNodeClosed()
+	}
 
+	defer n.wg.Done()
 	// Assertion for tcp connection to keep alive
 	log.Print("starting handshake")
 	connection, isTCP := conn.(*net.TCPConn)
 	if isTCP {
 		// Setup network parameters to control connection behavior.
 		if err := n.setupTCPConnection(connection); err != nil {
-			return errSettingUpConnection(err)
+			conn.Close()
+			return nil, false, errSettingUpConnection(err)
 		}
 	}
 
-	// Drop connections if max peers exceeded
-	if n.router.Len() >= n.config.MaxPeersConnected() {
-		connection.Close() // Drop connection :(
+	// Drop connections if max peers exceeded and no room can be made for them.
+	// Peers are trimmed only after the remote peer is authenticated and admitted.
+	if n.router.Len() >= n.config.MaxPeersConnected() && n.config.HighWatermark() == 0 {
+		conn.Close() // Drop connection :(
 		log.Printf("max peers exceeded: MaxPeerConnected = %d", n.config.MaxPeersConnected())
-		return errExceededMaxPeers(n.config.MaxPeersConnected())
+		return nil, false, errExceededMaxPeers(n.config.MaxPeersConnected())
 	}
 
+	// A remote peer that accept the connection and then stall could hold the handshake forever.
+	// Bound the handshake I/O to handshake timeout and context cancellation.
+	conn.SetDeadline(n.handshakeDeadline(ctx))
+	stop := n.watchContext(ctx, conn)
+	defer stop()
+
 	// Stage 1 -> run handshake
-	h, err := newHandshake(connection, initialize)
+	kr, err := n.keyRing()
+	if err != nil {
+		conn.Close()
+		return nil, false, errDuringHandshake(err)
+	}
+
+	h, err := newHandshake(conn, initialize, kr)
 	if err != nil {
 		log.Printf("error while creating handshake: %s", err)
-		return err
+		conn.Close()
+		return nil, false, err
 	}
 
 	err = h.Start() // start the handshake
 	if err != nil {
 		log.Printf("error while starting handshake: %s", err)
-		return err
+		conn.Close()
+		// Canceled context is the cause of failure.
+		if ctx.Err() != nil {
+			return nil, false, errDuringHandshake(ctx.Err())
+		}
+
+		// Failures caused by node shutting down are not remote peer fault.
+		// Dialed peers could reject the connection or be unreachable, only incoming failures count.
+		select {
+		case <-n.done:
+		default:
+			if !initialize {
+				n.failedHandshake(conn.RemoteAddr())
+			}
+		}
+
+		return nil, false, err
 	}
 
 	// Stage 2 -> get a secure session
 	// All good with handshake? Then get a secure session.
 	log.Print("handshake complete")
 	session := h.Session()
+	// Remote peer identity is known, check if it is banned.
+	if n.banned(newBlake2ID(session.RemotePublicKey()), conn.RemoteAddr()) {
+		log.Printf("connection rejected for banned peer: %s", conn.RemoteAddr())
+		n.reject(session, ReasonBanned)
+		return nil, false, errBannedPeer(errors.New("remote peer banned"))
+	}
+
+	// Check if remote peer is allowed.
+	if gater := n.connectionGater(); gater != nil {
+		id := newBlake2ID(session.RemotePublicKey())
+		protocol := protocolName(n.config.Protocol())
+		if !gater.InterceptSecured(id, conn.RemoteAddr(), protocol) {
+			log.Printf("connection rejected by gater: %s", conn.RemoteAddr())
+			n.reject(session, ReasonBanned)
+			return nil, false, errConnectionGated(errors.New("remote peer rejected"))
+		}
+	}
+
+	// Dialed addresses are pinned to the remote identity.
+	if initialize {
+		if err := n.verifyKnownPeer(addr, session); err != nil {
+			log.Printf("connection rejected for %s: %v", addr, err)
+			n.reject(session, ReasonUntrusted)
+			return nil, false, err
+		}
+	}
+
+	// Remote peer must present a valid certificate if a verifier is set.
+	if verifier := n.certificateVerifier(); verifier != nil {
+		if err := verifier.Verify(session.RemoteCertificate(), session.RemotePublicKey()); err != nil {
+			log.Printf("connection rejected for %s: %v", conn.RemoteAddr(), err)
+			n.reject(session, ReasonBanned)
+			return nil, false, errInvalidCertificate(err)
+		}
+	}
+
+	// Try to make room for the admitted peer closing the less valuable peers.
+	if !n.makeRoom(session) {
+		log.Printf("max peers exceeded: MaxPeerConnected = %d", n.config.MaxPeersConnected())
+		n.reject(session, ReasonTrimmed)
+		return nil, false, errExceededMaxPeers(n.config.MaxPeersConnected())
+	}
+
 	// Stage 3 -> create a peer and add it to router
+	// The context bounds only the handshake, an established peer must not be aborted by it.
+	stop()
+	if err := ctx.Err(); err != nil {
+		conn.Close()
+		return nil, false, errDuringHandshake(err)
+	}
+
+	// Clear the handshake deadline, routing sets the idle deadline for the routed peer.
+	conn.SetDeadline(time.Time{})
 	// Routing for secure session
-	peer := n.routing(session)
+	// The node could start shutting down while handshake was running.
+	n.mu.Lock()
+	if n.closed {
+		n.mu.Unlock()
+		conn.Close()
+		return nil, false, errNodeClosed()
+	}
+
+	peer, routed, replaced := n.routing(session, initialize)
+	n.mu.Unlock()
+	if peer == nil {
+		conn.Close()
+		return nil, false, errDuringHandshake(errors.New("connection with self"))
+	}
+
+	// Incoming connections are requested by remote, they are never closed after requests.
+	if !initialize {
+		peer.Keep()
+	}
+
+	if !routed {
+		// Already connected with remote peer, the existing connection was kept.
+		return peer, false, nil
+	}
+
 	// Keep watching for incoming messages
 	// This routine will stop when Close() is called
+	n.wg.Add(1)
 	go n.watch(peer)
-	// Dispatch event for new peer connected
-	n.events.PeerConnected(peer)
-	return nil
+	// Trim connections if high watermark is exceeded.
+	if n.exceeded() {
+		go n.trimPeers()
+	}
+
+	// The remote peer was already notified if the connection replaced an existing one.
+	if !replaced {
+		// Dispatch event for new peer connected
+		n.events.PeerConnected(peer)
+	}
+
+	// Share the listening address to be discoverable through the DHT.
+	n.discovered(peer, addr)
+	// Ask for more peers if target degree is not reached.
+	n.exchange(peer)
+	// Announce the joined topics.
+	n.subscriptions(peer)
+	// Remember the peer to redial it on next start.
+	n.rememberPeer(peer, addr)
+	// Publish the static key to receive forwarded messages.
+	n.announceKey()
+	// Incoming connections count towards bootstrap too.
+	n.checkBootstrapped(peer)
+	return peer, !replaced, nil
+}
+
+// makeRoom returns true if there is room in router for the authenticated remote peer.
+// If max peers is reached and watermarks are set, the less valuable peers are trimmed to make room.
+// A connection with an already connected peer replaces the existing one, so it needs no room.
+func (n *Node) makeRoom(s *session) bool {
+	max := n.config.MaxPeersConnected()
+	if n.router.Len() < max || n.connected(newBlake2ID(s.RemotePublicKey())) {
+		return true
+	}
+
+	if n.config.HighWatermark() > 0 {
+		n.trim(int(max) - 1)
+	}
+
+	return n.router.Len() < max
+}
+
+// preferred return true if the connection direction is the preferred for remote peer.
+// If two nodes dial each other at the same time both ends need to agree on the connection to keep.
+// The connection dialed by the node with the lower ID is preferred.
+func (n *Node) preferred(peer *peer) bool {
+	local := n.ID()
+	remote := peer.ID()
+	lower := bytes.Compare(local[:], remote[:]) < 0
+	return peer.outbound == lower
+}
+
+// reject sends a goodbye to a not routed session and close it.
+func (n *Node) reject(conn *session, reason Reason) {
+	peer := newPeer(conn)
+	peer.BindPool(n.pool)
+	dismiss(peer, reason)
+}
+
+// dismiss sends a goodbye to peer and close the connection.
+func dismiss(peer *peer, reason Reason) {
+	if err := peer.Goodbye(reason); err != nil {
+		log.Printf("error sending goodbye: %v", err)
+	}
+
+	peer.Close()
 }
 
 // routing initializes a route in the routing table from a session.
-// It returns the recently added peer.
-func (n *Node) routing(conn *session) *peer {
+// If there is already a connection with the same peer only one of them survives, please see preferred.
+// It returns the routed peer, true if the new connection was routed or false if the existing connection was kept,
+// and true if the new connection replaced the existing one.
+// Returns nil if the session belongs to the local node.
+func (n *Node) routing(conn *session, outbound bool) (*peer, bool, bool) {
 	// Initial deadline for connection.
 	// A deadline is an absolute time after which I/O operations
 	// fail instead of blocking. The deadline applies to all future
@@ -243,17 +836,49 @@ func (n *Node) routing(conn *session) *peer {
 	conn.SetDeadline(idle)
 	// We need to know how interact with peer based on socket and connection
 	peer := newPeer(conn)
+	peer.outbound = outbound
 	// Bind global buffer pool to peer.
 	// Pool buffering reduce memory allocation latency.
 	peer.BindPool(n.pool)
+	// Incoming rate limits for peer.
+	peer.limits = newLimiter(n.config.PeerMessageRate(), n.config.PeerByteRate())
+
+	// Dialing ourselves
+	if peer.ID() == n.ID() {
+		return nil, false, false
+	}
+
 	// Store new peer in router table
-	n.router.Add(peer)
-	return peer
+	if n.router.Add(peer) {
+		return peer, true, false
+	}
+
+	existing, ok := n.router.Query(peer.ID())
+	if !ok {
+		// The existing peer was removed in the meantime.
+		return n.routing(conn, outbound)
+	}
+
+	// Simultaneous connections between the same two peers.
+	// Keep the preferred connection and close the other one.
+	if n.preferred(existing) || !n.preferred(peer) {
+		log.Print("duplicated connection with peer, keeping existing")
+		go dismiss(peer, ReasonDuplicate)
+		return existing, false, false
+	}
+
+	log.Print("duplicated connection with peer, replacing existing")
+	existing.Replace()
+	n.router.Replace(existing, peer)
+	go dismiss(existing, ReasonDuplicate)
+	return peer, true, true
 }
 
 // LocalAddr returns the local address assigned to node.
 // If node is not listening nil is returned instead.
 func (n *Node) LocalAddr() net.Addr {
+	n.mu.Lock()
+	defer n.mu.Unlock()
 	if n.listener == nil {
 		return nil
 	}
@@ -264,10 +889,22 @@ func (n *Node) LocalAddr() net.Addr {
 // Listen start listening on the given address and wait for new connection.
 // Return error if error occurred while listening.
 func (n *Node) Listen() error {
+	return n.ListenContext(context.Background())
+}
+
+// ListenContext start listening on the given address and wait for new connection until context is canceled.
+// Cancellation stops the accept loop and aborts the in-progress handshakes for incoming connections.
+// Return error if error occurred while listening or the context error if context is canceled.
+func (n *Node) ListenContext(ctx context.Context) error {
+	// Invalid identity settings would fail every handshake.
+	if err := n.checkIdentity(); err != nil {
+		return err
+	}
 
 	addr := n.config.SelfListeningAddress() // eg. 0.0.0.0
 	protocol := n.config.Protocol()         // eg. tcp
-	listener, err := net.Listen(protocol, addr)
+	var lc net.ListenConfig
+	listener, err := lc.Listen(ctx, protocol, addr)
 	log.Printf("listening on %s", addr)
 
 	if err != nil {
@@ -277,37 +914,160 @@ func (n *Node) Listen() error {
 
 	// The order here is IMPORTANT.
 	// We set listener first then we notify listening event, otherwise a race condition is caused.
-	n.listener = listener        // keep reference to current listener.
+	n.mu.Lock()
+	if n.closed {
+		n.mu.Unlock()
+		listener.Close()
+		return errNodeClosed()
+	}
+
+	n.listener = listener // keep reference to current listener.
+	n.mu.Unlock()
 	n.events.SelfListening(addr) // emit listening event
 
+	// Join the network through the bootstrap peers.
+	if addrs := n.config.BootstrapPeers(); len(addrs) > 0 && n.track() {
+		go n.bootstrap(ctx, addrs)
+	}
+
+	// Reconnect with the peers known from previous runs.
+	if n.config.RedialKnownPeers() > 0 && n.track() {
+		go n.redial(ctx)
+	}
+
+	// Find peers in local network.
+	if group := n.config.DiscoveryAddress(); group != "" && n.track() {
+		go n.discover(ctx, group)
+	}
+
+	// Stop accepting connections when context is canceled.
+	stop := watchListener(ctx, listener)
+	defer stop()
+
 	for {
 		// Block/Hold while waiting for new incoming connection
 		// Synchronized incoming connections
 		conn, err := listener.Accept()
 		if err != nil {
 			log.Printf("error accepting connection %s", err)
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
 			return errBindingConnection(err)
 		}
 
-		// Run handshake for incoming connection
-		// We need to run in a separate goroutine to improve time performance between nodes requesting connections.
-		go n.handshake(conn, false)
+		// Banned addresses are dropped before handshake.
+		if n.bans.BannedIP(hostIP(conn.RemoteAddr().String())) {
+			log.Printf("connection rejected for banned address: %s", conn.RemoteAddr())
+			conn.Close()
+			continue
+		}
+
+		// Check if remote address is allowed before handshake.
+		if gater := n.connectionGater(); gater != nil && !gater.InterceptAccept(conn.RemoteAddr()) {
+			log.Printf("connection rejected by gater: %s", conn.RemoteAddr())
+			conn.Close()
+			continue
+		}
+
+		// Run handshake for incoming connection if handshake limits allow it.
+		n.admit(ctx, conn)
 	}
 
 }
 
-// Close all peers connections and stop listening.
-func (n *Node) Close() error {
+// watchListener close the listener when the context is canceled.
+// It returns a stop function that must be called to release the watching routine.
+func watchListener(ctx context.Context, listener net.Listener) func() {
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			// Any blocked Accept operations will be unblocked and return errors.
+			listener.Close()
+		case <-done:
+		}
+	}()
 
-	// close peer connections
-	go n.Disconnect()
+	return func() { close(done) }
+}
+
+// Shutdown gracefully stop the node.
+// It stops accepting new connections, aborts in-progress handshakes and sends a goodbye to each connected peer after draining its pending sends.
+// Shutdown waits until every remote peer closes the connection and all the running routines exit, or until context expires.
+// If context expires the remaining connections are closed and the context error is returned.
+func (n *Node) Shutdown(ctx context.Context) error {
+	log.Print("closing connections and shutting down node..")
+	n.mu.Lock()
+	if !n.closed {
+		n.closed = true
+		// abort in-progress handshakes
+		close(n.done)
+	}
 
+	listener := n.listener
+	n.mu.Unlock()
+
+	var err error
 	// stop listener for listening node only
-	if n.listener == nil {
-		return nil
+	if listener != nil {
+		err = listener.Close()
+		// listener could be already closed by listening context.
+		if errors.Is(err, net.ErrClosed) {
+			err = nil
+		}
+	}
+
+	// No more messages are delivered to joined topics.
+	n.closeTopics()
+	for p := range n.router.Table() {
+		// Goodbye routines are waited with watch routines.
+		n.wg.Add(1)
+		go func(p *peer) {
+			defer n.wg.Done()
+			// After goodbye the remote peer closes the connection and watch routine exits.
+			if err := p.Goodbye(ReasonShutdown); err != nil {
+				log.Printf("error sending goodbye: %v", err)
+				p.Close()
+			}
+		}(p)
+	}
+
+	// Wait for watch and handshake routines.
+	exited := make(chan struct{})
+	go func() {
+		n.wg.Wait()
+		close(exited)
+	}()
+
+	select {
+	case <-exited:
+	case <-ctx.Done():
+		// Too late to wait for remote peers.
+		n.Disconnect()
+		<-exited
+		err = ctx.Err()
 	}
 
-	if err := n.listener.Close(); err != nil {
+	// Write pending peerstore changes after the last connection is recorded.
+	if ferr := n.peerStore().Flush(); ferr != nil {
+		log.Printf("error flushing peerstore: %v", ferr)
+	}
+
+	return err
+}
+
+// Close all peers connections and stop listening.
+// Close waits for remote peers to close the connection up to Linger seconds, then the remaining connections are closed.
+// If Linger is not set Close waits up to goodbyeTimeout.
+// Please see Shutdown to control the graceful closing time.
+func (n *Node) Close() error {
+	linger := lingerTimeout(n.config.Linger())
+	ctx, cancel := context.WithTimeout(context.Background(), linger)
+	defer cancel()
+
+	if err := n.Shutdown(ctx); err != nil && ctx.Err() == nil {
 		return err
 	}
 
@@ -317,21 +1077,57 @@ func (n *Node) Close() error {
 // Dial attempts to connect to a remote node and adds the connected peer to the routing table.
 // It returns an error if an error occurred while dialing the node.
 func (n *Node) Dial(addr string) error {
-	protocol := n.config.Protocol()   // eg. tcp
-	timeout := n.config.DialTimeout() // max time waiting for dial.
+	return n.DialContext(context.Background(), addr)
+}
+
+// DialContext attempts to connect to a remote node using the provided context.
+// Cancellation aborts the dialing and the handshake with the remote node.
+// It returns an error if an error occurred while dialing the node.
+func (n *Node) DialContext(ctx context.Context, addr string) error {
+	_, err := n.dial(ctx, addr)
+	return err
+}
+
+// dial connect to a remote node and returns the connected peer.
+// The connection is kept even if it was already opened for a request, see [Node.connect].
+func (n *Node) dial(ctx context.Context, addr string) (*peer, error) {
+	peer, _, err := n.connect(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	peer.Keep()
+	return peer, nil
+}
+
+// connect dials a remote node and returns the connected peer.
+// It returns true if the peer was connected by this call, or false if an existing connection with the peer was kept.
+func (n *Node) connect(ctx context.Context, addr string) (*peer, bool, error) {
+	if err := n.checkIdentity(); err != nil {
+		return nil, false, err
+	}
+
+	// Check if remote address is allowed before dialing.
+	if gater := n.connectionGater(); gater != nil && !gater.InterceptDial(addr) {
+		return nil, false, errConnectionGated(fmt.Errorf("dialing %s", addr))
+	}
+
+	if n.bans.BannedIP(hostIP(addr)) {
+		return nil, false, errBannedPeer(fmt.Errorf("dialing %s", addr))
+	}
+
+	protocol := n.config.Protocol() // eg. tcp
+	// max time waiting for dial.
+	dialer := net.Dialer{Timeout: n.config.DialTimeout()}
 
 	// Start dialing to address
-	conn, err := net.DialTimeout(protocol, addr, timeout)
+	conn, err := dialer.DialContext(ctx, protocol, addr)
 	log.Printf("dialing to %s", addr)
 
 	if err != nil {
-		return errDialingNode(err)
+		return nil, false, errDialingNode(err)
 	}
 
 	// Run handshake for dialed connection
-	if err = n.handshake(conn, true); err != nil {
-		return err
-	}
-
-	return nil
+	return n.handshake(ctx, conn, true, addr)
 }
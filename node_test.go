@@ -3,9 +3,11 @@ package noise
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"crypto/rand"
 	"io/ioutil"
 	"log"
+	"net"
 	"os"
 	"testing"
 	"time"
@@ -72,6 +74,22 @@ func whenReadyForIncomingDial(node *Node) <-chan bool {
 	return ready
 }
 
+// waitFor returns the first signal of event type received before wait time.
+// It returns false if the signal wasn't received in time.
+func waitFor(signals <-chan Signal, event Event, wait time.Duration) (Signal, bool) {
+	timeout := time.After(wait)
+	for {
+		select {
+		case signal := <-signals:
+			if signal.Type() == event {
+				return signal, true
+			}
+		case <-timeout:
+			return Signal{}, false
+		}
+	}
+}
+
 func TestWithZeroFutureDeadline(t *testing.T) {
 	idle := futureDeadLine(0)
 
@@ -81,6 +99,24 @@ func TestWithZeroFutureDeadline(t *testing.T) {
 
 }
 
+func TestWatchContextStop(t *testing.T) {
+	node := New(config.New())
+	local, remote := net.Pipe()
+	defer local.Close()
+	defer remote.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stop := node.watchContext(ctx, local)
+	stop()
+	// The connection is no longer bound to context once stopped.
+	cancel()
+
+	go remote.Write([]byte("hello"))
+	if _, err := local.Read(make([]byte, 5)); err != nil {
+		t.Errorf("expected connection not aborted after stop, got %v", err)
+	}
+}
+
 func TestTwoNodesHandshakeTrace(t *testing.T) {
 
 	expectedBehavior := []string{
@@ -228,6 +264,355 @@ func TestSomeNodesHandshake(t *testing.T) {
 
 }
 
+func TestDialContextStalledHandshake(t *testing.T) {
+	// A remote that accepts the connection and never answers the handshake.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err == nil {
+			defer conn.Close()
+			// hold the connection without answering
+			time.Sleep(3 * time.Second)
+		}
+	}()
+
+	configuration := config.New()
+	configuration.Write(config.SetHandshakeTimeout(0))
+	node := New(configuration)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err = node.DialContext(ctx, listener.Addr().String())
+	if err == nil {
+		t.Errorf("expected error dialing stalled remote")
+	}
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected dial aborted by context, got %v elapsed", elapsed)
+	}
+}
+
+func TestHandshakeTimeoutForIncoming(t *testing.T) {
+	configuration := config.New()
+	configuration.Write(
+		config.SetSelfListeningAddress("127.0.0.1:"),
+		config.SetHandshakeTimeout(200*time.Millisecond),
+	)
+
+	node := New(configuration)
+	defer node.Close()
+	<-whenReadyForIncomingDial(node)
+
+	// Dial and never send the handshake messages.
+	conn, err := net.Dial("tcp", node.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	// The node should drop the stalled connection after handshake timeout.
+	_, err = conn.Read(make([]byte, 1))
+	if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+		t.Errorf("expected connection closed by node after handshake timeout")
+	}
+}
+
+func TestListenContextCancel(t *testing.T) {
+	configuration := config.New()
+	configuration.Write(config.SetSelfListeningAddress("127.0.0.1:"))
+	node := New(configuration)
+
+	ready := make(chan bool)
+	stopped := make(chan error)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	go func() {
+		signals, cancel := node.Signals()
+		for signal := range signals {
+			if signal.Type() == SelfListening {
+				cancel()
+				ready <- true
+			}
+		}
+	}()
+
+	go func() { stopped <- node.ListenContext(ctx) }()
+	<-ready
+	cancel()
+
+	select {
+	case err := <-stopped:
+		if err != context.Canceled {
+			t.Errorf("expected context canceled error, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Errorf("expected listening stopped after context cancellation")
+	}
+}
+
+func TestShutdownNotifyRemotePeer(t *testing.T) {
+	configurationA := config.New()
+	configurationB := config.New()
+	configurationA.Write(config.SetSelfListeningAddress("127.0.0.1:"))
+
+	nodeA := New(configurationA)
+	nodeB := New(configurationB)
+	defer nodeB.Close()
+
+	<-whenReadyForIncomingDial(nodeA)
+	signalsB, cancel := nodeB.Signals()
+	defer cancel()
+
+	if err := nodeB.Dial(nodeA.LocalAddr().String()); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancelShutdown := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancelShutdown()
+
+	if err := nodeA.Shutdown(ctx); err != nil {
+		t.Errorf("expected graceful shutdown, got %v", err)
+	}
+
+	if nodeA.router.Len() != 0 {
+		t.Errorf("expected no routed peers after shutdown, got %d", nodeA.router.Len())
+	}
+
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case signal := <-signalsB:
+			if signal.Type() == PeerDisconnected {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("expected remote peer disconnected after shutdown")
+		}
+	}
+}
+
+func TestCloseNotifyRemotePeer(t *testing.T) {
+	configurationA := config.New()
+	configurationA.Write(config.SetSelfListeningAddress("127.0.0.1:"))
+
+	nodeA := New(configurationA)
+	nodeB := New(config.New())
+	defer nodeB.Close()
+
+	signalsA, cancelA := nodeA.Signals()
+	signalsB, cancelB := nodeB.Signals()
+	defer cancelA()
+	defer cancelB()
+
+	go nodeA.Listen()
+	if _, ok := waitFor(signalsA, SelfListening, 2*time.Second); !ok {
+		t.Fatalf("expected node listening")
+	}
+
+	if err := nodeB.Dial(nodeA.LocalAddr().String()); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := waitFor(signalsA, NewPeerDetected, 2*time.Second); !ok {
+		t.Fatalf("expected peer detected")
+	}
+
+	// Default linger still sends goodbye to remote peers.
+	if err := nodeA.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	remote, ok := waitFor(signalsB, PeerDisconnected, 2*time.Second)
+	if !ok {
+		t.Fatalf("expected remote peer disconnected after close")
+	}
+
+	if reason := remote.Reason(); reason != ReasonShutdown {
+		t.Errorf("expected remote reason %s, got %s", ReasonShutdown, reason)
+	}
+}
+
+func TestDialAfterShutdown(t *testing.T) {
+	configurationA := config.New()
+	configurationA.Write(config.SetSelfListeningAddress("127.0.0.1:"))
+
+	nodeA := New(configurationA)
+	nodeB := New(config.New())
+	defer nodeA.Close()
+
+	<-whenReadyForIncomingDial(nodeA)
+	nodeB.Shutdown(context.Background())
+
+	if err := nodeB.Dial(nodeA.LocalAddr().String()); err == nil {
+		t.Errorf("expected error dialing from closed node")
+	}
+}
+
+func TestClosePeerWithReason(t *testing.T) {
+	configurationA := config.New()
+	configurationA.Write(config.SetSelfListeningAddress("127.0.0.1:"))
+
+	nodeA := New(configurationA)
+	nodeB := New(config.New())
+	defer nodeA.Close()
+	defer nodeB.Close()
+
+	signalsA, cancelA := nodeA.Signals()
+	signalsB, cancelB := nodeB.Signals()
+	defer cancelA()
+	defer cancelB()
+
+	go nodeA.Listen()
+	if _, ok := waitFor(signalsA, SelfListening, 2*time.Second); !ok {
+		t.Fatalf("expected node listening")
+	}
+
+	if err := nodeB.Dial(nodeA.LocalAddr().String()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Remote peer id from node A perspective
+	detected, ok := waitFor(signalsA, NewPeerDetected, 2*time.Second)
+	if !ok {
+		t.Fatalf("expected peer detected")
+	}
+
+	id := detected.Payload()
+	if err := nodeA.ClosePeer(id, ReasonBanned); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := nodeA.router.Query(newIDFromString(id)); ok {
+		t.Errorf("expected peer removed from router after close")
+	}
+
+	local, ok := waitFor(signalsA, PeerDisconnected, 2*time.Second)
+	if !ok {
+		t.Fatalf("expected local peer disconnected")
+	}
+
+	if reason := local.Reason(); reason != ReasonBanned {
+		t.Errorf("expected local reason %s, got %s", ReasonBanned, reason)
+	}
+
+	remote, ok := waitFor(signalsB, PeerDisconnected, 2*time.Second)
+	if !ok {
+		t.Fatalf("expected remote peer disconnected")
+	}
+
+	if reason := remote.Reason(); reason != ReasonBanned {
+		t.Errorf("expected remote reason %s, got %s", ReasonBanned, reason)
+	}
+}
+
+func TestClosePeerInvalid(t *testing.T) {
+	node := New(config.New())
+	id := mockID(PeerAPb)
+
+	if err := node.ClosePeer(id.String(), ReasonLocalClose); err == nil {
+		t.Errorf("expected error closing not connected peer")
+	}
+}
+
+func TestNodeIdentity(t *testing.T) {
+	configurationA := config.New()
+	configurationA.Write(config.SetSelfListeningAddress("127.0.0.1:"))
+
+	nodeA := New(configurationA)
+	nodeB := New(config.New())
+	defer nodeA.Close()
+	defer nodeB.Close()
+
+	<-whenReadyForIncomingDial(nodeA)
+	if err := nodeB.Dial(nodeA.LocalAddr().String()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Node B identity should match the routed remote peer in A.
+	// The handshake could be still running in node A.
+	timeout := time.After(2 * time.Second)
+	for {
+		if _, ok := nodeA.router.Query(nodeB.ID()); ok {
+			break
+		}
+
+		select {
+		case <-timeout:
+			t.Fatalf("expected node B identity routed in node A")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	id := nodeA.ID()
+	if id != nodeA.ID() || id == nodeB.ID() {
+		t.Errorf("expected stable and unique node identities")
+	}
+}
+
+func TestDialSelf(t *testing.T) {
+	configuration := config.New()
+	configuration.Write(config.SetSelfListeningAddress("127.0.0.1:"))
+
+	node := New(configuration)
+	defer node.Close()
+
+	<-whenReadyForIncomingDial(node)
+	if err := node.Dial(node.LocalAddr().String()); err == nil {
+		t.Errorf("expected error dialing self")
+	}
+}
+
+func TestSimultaneousDial(t *testing.T) {
+	configurationA := config.New()
+	configurationB := config.New()
+	configurationA.Write(config.SetSelfListeningAddress("127.0.0.1:"))
+	configurationB.Write(config.SetSelfListeningAddress("127.0.0.1:"))
+
+	nodeA := New(configurationA)
+	nodeB := New(configurationB)
+	defer nodeA.Close()
+	defer nodeB.Close()
+
+	<-whenReadyForIncomingDial(nodeA)
+	<-whenReadyForIncomingDial(nodeB)
+
+	// Both nodes dial each other at the same time.
+	done := make(chan bool)
+	go func() { nodeA.Dial(nodeB.LocalAddr().String()); done <- true }()
+	go func() { nodeB.Dial(nodeA.LocalAddr().String()); done <- true }()
+	<-done
+	<-done
+
+	// Wait until the duplicated connection is dismissed.
+	timeout := time.After(2 * time.Second)
+	for {
+		peerB, okA := nodeA.router.Query(nodeB.ID())
+		peerA, okB := nodeB.router.Query(nodeA.ID())
+		// Both ends should agree on the same connection.
+		if okA && okB && peerB.outbound != peerA.outbound {
+			break
+		}
+
+		select {
+		case <-timeout:
+			t.Fatalf("expected only one connection between nodes")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	if nodeA.router.Len() != 1 || nodeB.router.Len() != 1 {
+		t.Errorf("expected one routed peer for each node, got %d and %d", nodeA.router.Len(), nodeB.router.Len())
+	}
+}
+
 func BenchmarkHandshake(b *testing.B) {
 
 	// Discard logs to avoid extra allocations.
@@ -4,18 +4,23 @@ import (
 	"bytes"
 	"encoding/binary"
 	"encoding/gob"
+	"errors"
 	"fmt"
 	"io"
-	"log"
-	"net"
+	"sync"
+	"sync/atomic"
 	"time"
+
+	"golang.org/x/crypto/chacha20poly1305"
 )
 
 // packet set needed properties to handle incoming message for peer.
 type packet struct {
 	// Ascending order for struct size
-	Sig []byte // 24 byte Signature
-	Msg []byte // 24 byte Digest
+	Sig   []byte // 24 byte Signature
+	Msg   []byte // 24 byte Digest
+	Frame frame  // 1 byte Frame type
+	size  int    // bytes read from the wire, not encoded
 }
 
 // TODO Establecer de manera dinámica el send buffer y receiver buffer en el peer y no en el nodo, de modo que se pued la establecerlo usando las métricas
@@ -31,13 +36,14 @@ func marshall(p packet) bytes.Buffer {
 }
 
 // unmarshall decode incoming message to packet.
-func unmarshall(b []byte) packet {
+// Returns an error if the bytes are not a valid encoded packet.
+func unmarshall(b []byte) (packet, error) {
 	var p packet
 	buf := bytes.NewBuffer(b)
 	decoder := gob.NewDecoder(buf)
 	// decode bytes to packet
-	decoder.Decode(&p)
-	return p
+	err := decoder.Decode(&p)
+	return p, err
 }
 
 // peer represents a trusty remote peer, providing necessary methods to interact with the secured session.
@@ -45,22 +51,71 @@ type peer struct {
 	// Optimizing space with ordered types.
 	// the attributes orders matters.
 	// ref: https://stackoverflow.com/questions/2113751/sizeof-struct-in-go
-	id   ID
-	s    *session
-	m    *metrics
-	pool BytePool
+	id       ID
+	s        *session
+	m        *metrics
+	pool     *bufferPool
+	limits   *limiter       // incoming rate limits
+	pending  sync.WaitGroup // in-flight sends
+	wmu      sync.Mutex     // serialize writes to session
+	smu      sync.Mutex     // guard closing state
+	closing  bool
+	reason   Reason        // reason sent to remote in goodbye
+	done     chan struct{} // closed when peer get disconnected
+	outbound bool          // connection dialed by local node
+	replaced bool          // connection replaced by a duplicated one
+	created  time.Time     // when the connection was established
+	lastSeen atomic.Int64  // last I/O activity in unix nanoseconds
+	frames   atomic.Uint32 // bitmask of frames received from peer
+	umu      sync.Mutex    // guard requests usage
+	requests int           // in-flight requests using the connection
+	opened   bool          // connection opened only for requests
+	kept     bool          // connection used beyond requests
 }
 
 // Create a new peer based on secure session
 func newPeer(s *session) *peer {
 	// Blake2 hashed remote public key.
 	id := newBlake2ID(s.RemotePublicKey())
-	return &peer{id, s, nil, nil}
+	p := &peer{id: id, s: s, m: new(metrics), done: make(chan struct{}), created: time.Now()}
+	p.Touch()
+	return p
+}
+
+// Observe register a frame received from peer.
+// It returns true the first time the frame is received.
+func (p *peer) Observe(f frame) bool {
+	bit := uint32(1) << f
+	for {
+		seen := p.frames.Load()
+		if seen&bit != 0 {
+			return false
+		}
+
+		if p.frames.CompareAndSwap(seen, seen|bit) {
+			return true
+		}
+	}
+}
+
+// Touch register I/O activity with peer.
+func (p *peer) Touch() {
+	p.lastSeen.Store(time.Now().UnixNano())
+}
+
+// Idle return how long the peer has been without I/O activity.
+func (p *peer) Idle() time.Duration {
+	return time.Since(time.Unix(0, p.lastSeen.Load()))
+}
+
+// Age return how long the connection has been established.
+func (p *peer) Age() time.Duration {
+	return time.Since(p.created)
 }
 
 // BindPool set a global memory pool for peer.
 // Using pools remove latency from buffer allocation.
-func (p *peer) BindPool(pool BytePool) {
+func (p *peer) BindPool(pool *bufferPool) {
 	p.pool = pool
 }
 
@@ -70,6 +125,11 @@ func (p *peer) ID() ID {
 	return p.id
 }
 
+// Done returns a channel that's closed when the peer get disconnected.
+func (p *peer) Done() <-chan struct{} {
+	return p.done
+}
+
 // Close its a forward method for internal `Close` method in session.
 func (p *peer) Close() error {
 	return p.s.Close()
@@ -80,22 +140,154 @@ func (p *peer) SetDeadline(t time.Time) error {
 	return p.s.SetDeadline(t)
 }
 
+// BeginRequest register a new in-flight request using the connection.
+// If opened is true the connection was dialed only for the request.
+func (p *peer) BeginRequest(opened bool) {
+	p.umu.Lock()
+	defer p.umu.Unlock()
+	p.requests++
+	p.opened = p.opened || opened
+}
+
+// EndRequest ends an in-flight request using the connection.
+// It returns true if the connection was opened only for requests and it's no longer in use.
+func (p *peer) EndRequest() bool {
+	p.umu.Lock()
+	defer p.umu.Unlock()
+	p.requests--
+	return p.requests == 0 && p.opened && !p.kept
+}
+
+// Kept returns true if the connection is used beyond requests.
+func (p *peer) Kept() bool {
+	p.umu.Lock()
+	defer p.umu.Unlock()
+	return p.kept
+}
+
+// Keep marks the connection as used beyond requests, so it's never closed after them.
+func (p *peer) Keep() {
+	p.umu.Lock()
+	defer p.umu.Unlock()
+	p.kept = true
+}
+
+// acquire register a new in-flight send.
+// It returns false if the peer is closing and no more sends are allowed.
+func (p *peer) acquire() bool {
+	p.smu.Lock()
+	defer p.smu.Unlock()
+	if p.closing {
+		return false
+	}
+
+	p.pending.Add(1)
+	return true
+}
+
 // Send send a message to Peer with size bundled in header for dynamic allocation of buffer.
 // Each message is encrypted using session keys.
+// It returns an error if the peer connection is closing.
 func (p *peer) Send(msg []byte) (uint32, error) {
+	return p.send(dataFrame, msg)
+}
+
+// send send a frame to Peer as an in-flight send drained before goodbye.
+// It returns an error if the peer connection is closing.
+func (p *peer) send(f frame, msg []byte) (uint32, error) {
+	if !p.acquire() {
+		return 0, errSendingMessage(errors.New("peer connection is closing"))
+	}
+
+	defer p.pending.Done()
+	return p.write(f, msg)
+}
+
+// Goodbye notify the remote peer that the connection is going to be closed.
+// New sends are rejected and pending sends are drained before the goodbye frame is sent.
+// After goodbye the write side of connection is closed, if supported, waiting for remote to close the connection.
+func (p *peer) Goodbye(reason Reason) error {
+	p.smu.Lock()
+	p.closing = true
+	p.reason = reason
+	p.smu.Unlock()
 
+	// Drain pending sends
+	p.pending.Wait()
+	if _, err := p.write(goodbyeFrame, []byte{byte(reason)}); err != nil {
+		return err
+	}
+
+	// eg. TCP connection send FIN to remote after pending data.
+	if conn, ok := p.s.Conn.(interface{ CloseWrite() error }); ok {
+		return conn.CloseWrite()
+	}
+
+	return nil
+}
+
+// Replace mark the peer connection as replaced by a new connection with the same peer.
+func (p *peer) Replace() {
+	p.smu.Lock()
+	defer p.smu.Unlock()
+	p.replaced = true
+}
+
+// Replaced return true if the peer connection was replaced by a new connection with the same peer.
+func (p *peer) Replaced() bool {
+	p.smu.Lock()
+	defer p.smu.Unlock()
+	return p.replaced
+}
+
+// Closing return the reason sent to remote peer in goodbye.
+// It returns false if goodbye was not sent.
+func (p *peer) Closing() (Reason, bool) {
+	p.smu.Lock()
+	defer p.smu.Unlock()
+	return p.reason, p.closing
+}
+
+// sendPacked send an already signed and encoded packet to Peer as an in-flight send drained before goodbye.
+// It returns an error if the peer connection is closing.
+func (p *peer) sendPacked(packed []byte) (uint32, error) {
+	if !p.acquire() {
+		return 0, errSendingMessage(errors.New("peer connection is closing"))
+	}
+
+	defer p.pending.Done()
+	return p.writePacked(packed)
+}
+
+// write send a frame to Peer with size bundled in header for dynamic allocation of buffer.
+func (p *peer) write(f frame, msg []byte) (uint32, error) {
 	// only small messages can be signed, which is why it's usually a hash.
 	// hash + signature + encode
 	sig := p.s.Sign(msg)
-	packed := marshall(packet{sig, msg})
+	packed := marshall(packet{sig, msg, f, 0})
+	return p.writePacked(packed.Bytes())
+}
+
+// writePacked encrypts and send an encoded packet to Peer with size bundled in header.
+func (p *peer) writePacked(packed []byte) (uint32, error) {
+	// Writes to session need to be serialized.
+	// The cipher state nonce and the header + message write are not safe for concurrent use.
+	p.wmu.Lock()
+	defer p.wmu.Unlock()
+
+	// Remote peer would reject messages bigger than max buffer size.
+	size := len(packed) + chacha20poly1305.Overhead
+	if size > p.pool.Max() {
+		return 0, errOversizedMessage(size, p.pool.Max())
+	}
 
-	// Get a pool buffer chunk
-	buffer := p.pool.Get()
+	// Get a pool buffer chunk big enough for packet + cipher overhead.
+	buffer := p.pool.Get(size)
 	defer p.pool.Put(buffer)
 
 	// Encrypt packet with message and signature inside.
 	// we need to re-slice the buffer to avoid overflow slice in internal append.
-	ciphertext, err := p.s.Encrypt(buffer[:0], packed.Bytes())
+	ciphertext, err := p.s.Encrypt(buffer[:0], packed)
 	if err != nil {
 		return 0, err
 	}
@@ -112,68 +304,61 @@ func (p *peer) Send(msg []byte) (uint32, error) {
 		return 0, err
 	}
 
+	p.m.Sent(bytes)
+	p.Touch()
 	return uint32(bytes), nil
 }
 
-// Listen wait for incoming messages from Peer.
+// Listen wait for incoming packets from Peer.
 // Use the needed pool buffer based on incoming header.
-func (p *peer) Listen() ([]byte, error) {
+// The declared size is validated before any allocation or read, a size bigger than max buffer size
+// returns an OverflowError since the stream can't be trusted anymore and the peer must be disconnected.
+func (p *peer) Listen() (*packet, error) {
 	var size uint32 // read bytes size from header
 	err := binary.Read(p.s, binary.BigEndian, &size)
 	if err != nil {
 		return nil, err
 	}
 
-	defer func() {
-		if r := recover(); r != nil {
-			// Here we just omit any related issue related to overflow message size
-			log.Printf("recovered from: %v", r)
-		}
-	}()
+	// Never read messages bigger than max buffer size.
+	if int(size) > p.pool.Max() {
+		return nil, errOversizedMessage(int(size), p.pool.Max())
+	}
 
-	// Get a pool buffer chunk
-	buffer := p.pool.Get()
+	// Get a pool buffer chunk based on incoming header size.
+	buffer := p.pool.Get(int(size))
 	defer p.pool.Put(buffer)
 
-	// Read incoming message to buffer.
-	bytes, err := p.s.Read(buffer)
-	log.Printf("got %d bytes from peer", bytes)
-
-	if err == nil {
-		// decrypt incoming messages
-		ciphertext := buffer[:size]
-		// Reuse the buffer[:0] = reset slice from byte pool.
-		raw, err := p.s.Decrypt(buffer[:0], ciphertext)
-		if err != nil {
-			return nil, err
-		}
-
-		// decode decrypted packet
-		packet := unmarshall(raw)
-		// validate message signature
-		if !p.s.Verify(packet.Msg, packet.Sig) {
-			err := fmt.Errorf("invalid signature for incoming message: %s", packet.Sig)
-			return nil, errVerifyingSignature(err)
-		}
-
-		// Receive secure message from peer.
-		return packet.Msg, nil
+	// Read the whole incoming message to buffer.
+	// A single Read could return less bytes than the message size.
+	ciphertext := buffer[:size]
+	if _, err := io.ReadFull(p.s, ciphertext); err != nil {
+		return nil, err
 	}
 
-	// net: don't return io.EOF from zero byte reads
-	// if err == io.EOF then peer connection is closed
-	_, isNetError := err.(*net.OpError)
-	if err != io.EOF && !isNetError {
-		// end of message, but peer is still connected
-		return nil, nil
+	// decrypt incoming messages
+	// Reuse the buffer[:0] = reset slice from byte pool.
+	raw, err := p.s.Decrypt(buffer[:0], ciphertext)
+	if err != nil {
+		return nil, errDecryptingMessage(err)
 	}
 
-	// Close disconnected peer
-	if err := p.s.Close(); err != nil {
-		return nil, err
+	// decode decrypted packet
+	packet, err := unmarshall(raw)
+	if err != nil {
+		return nil, errDecodingMessage(err)
 	}
 
-	// Peer disconnected
-	return nil, err
+	// validate message signature
+	if !p.s.Verify(packet.Msg, packet.Sig) {
+		err := fmt.Errorf("invalid signature for incoming message: %s", packet.Sig)
+		return nil, errVerifyingSignature(err)
+	}
 
+	// Receive secure packet from peer.
+	// The rate limits account the header and ciphertext read from the wire.
+	packet.size = int(size) + 4
+	p.m.Recv(int(size))
+	p.Touch()
+	return &packet, nil
 }
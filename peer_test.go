@@ -2,6 +2,7 @@ package noise
 
 import (
 	"bytes"
+	"encoding/binary"
 	"encoding/hex"
 	"errors"
 	"net"
@@ -137,7 +138,7 @@ func (*mockHandshakeState) ReadMessage(out, message []byte) ([]byte, CipherState
 
 // mockSession create a testable session
 func mockSession(conn net.Conn, pb PublicKey) *session {
-	return &session{conn, KeyRing{}, pb, nil, nil}
+	return &session{conn, KeyRing{}, pb, nil, nil, nil, nil}
 }
 
 // mockID create a new testable id from public key
@@ -181,3 +182,198 @@ func TestHashID(t *testing.T) {
 		t.Errorf("expected returned %s equal to %s", got, expected)
 	}
 }
+
+func TestIDFromString(t *testing.T) {
+	for _, pb := range []PublicKey{PeerAPb, PeerBPb} {
+		id := mockID(pb)
+		// The id must be read from string data, not from the string header.
+		got := newIDFromString(id.String())
+
+		if got != id {
+			t.Errorf("expected id from string equal to %x, got %x", id, got)
+		}
+
+		if got.String() != id.String() {
+			t.Errorf("expected id string round trip equal to %x, got %x", id.String(), got.String())
+		}
+	}
+}
+
+// mockSecureSessions runs a real handshake over an in-memory connection.
+// It returns the initiator and responder secured sessions.
+func mockSecureSessions(t testing.TB) (*session, *session) {
+	krA, err := newKeyRing()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	krB, err := newKeyRing()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return mockSecureSessionsWith(t, krA, krB)
+}
+
+// mockSecureSessionsWith returns the sessions for a handshake between the initiator and responder key rings.
+func mockSecureSessionsWith(t testing.TB, krA, krB KeyRing) (*session, *session) {
+	initiator, responder := net.Pipe()
+	hA, _ := newHandshake(initiator, true, krA)
+	hB, _ := newHandshake(responder, false, krB)
+	answer := make(chan error, 1)
+	go func() { answer <- hB.Start() }()
+	if err := hA.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := <-answer; err != nil {
+		t.Fatal(err)
+	}
+
+	return hA.Session(), hB.Session()
+}
+
+// chunkedConn writes one byte at time to force short reads in remote.
+type chunkedConn struct {
+	net.Conn
+}
+
+func (c *chunkedConn) Write(b []byte) (int, error) {
+	for i := range b {
+		if _, err := c.Conn.Write(b[i : i+1]); err != nil {
+			return i, err
+		}
+	}
+
+	return len(b), nil
+}
+
+func TestListenShortReads(t *testing.T) {
+	sA, sB := mockSecureSessions(t)
+	sA.Conn = &chunkedConn{sA.Conn}
+	pool := newBufferPool(1024)
+	sender, receiver := newPeer(sA), newPeer(sB)
+	sender.BindPool(pool)
+	receiver.BindPool(pool)
+
+	expected := []byte("hello over short reads")
+	go sender.Send(expected)
+
+	packet, err := receiver.Listen()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(packet.Msg, expected) {
+		t.Errorf("expected message %s, got %s", expected, packet.Msg)
+	}
+}
+
+func TestListenOversizedMessage(t *testing.T) {
+	sA, sB := mockSecureSessions(t)
+	receiver := newPeer(sB)
+	receiver.BindPool(newBufferPool(1024))
+
+	// Declared size bigger than max buffer size.
+	go binary.Write(sA, binary.BigEndian, uint32(1<<30))
+
+	var overflow *OverflowError
+	_, err := receiver.Listen()
+	if !errors.As(err, &overflow) {
+		t.Errorf("expected overflow error, got %v", err)
+	}
+}
+
+func TestSendOversizedMessage(t *testing.T) {
+	sA, _ := mockSecureSessions(t)
+	sender := newPeer(sA)
+	sender.BindPool(newBufferPool(1024))
+
+	var overflow *OverflowError
+	_, err := sender.Send(make([]byte, 2048))
+	if !errors.As(err, &overflow) {
+		t.Errorf("expected overflow error, got %v", err)
+	}
+}
+
+// fuzzFrame encode a frame as sent through the wire.
+// If declared is 0 the real body size is used as header.
+func fuzzFrame(body []byte, declared uint32) []byte {
+	if declared == 0 {
+		declared = uint32(len(body))
+	}
+
+	var wire bytes.Buffer
+	binary.Write(&wire, binary.BigEndian, declared)
+	wire.Write(body)
+	return wire.Bytes()
+}
+
+func FuzzUnmarshall(f *testing.F) {
+	sA, _ := mockSecureSessions(f)
+	msg := []byte("hello")
+	data := marshall(packet{sA.Sign(msg), msg, dataFrame, 0})
+	goodbye := marshall(packet{sA.Sign([]byte{1}), []byte{1}, goodbyeFrame, 0})
+	f.Add(data.Bytes())
+	f.Add(goodbye.Bytes())
+	f.Add([]byte{})
+
+	f.Fuzz(func(t *testing.T, b []byte) {
+		p, err := unmarshall(b)
+		if err != nil {
+			return
+		}
+
+		// Decoded packets survive a round trip.
+		encoded := marshall(p)
+		decoded, err := unmarshall(encoded.Bytes())
+		if err != nil {
+			t.Fatalf("expected round trip decoding, got %v", err)
+		}
+
+		if !bytes.Equal(p.Msg, decoded.Msg) || !bytes.Equal(p.Sig, decoded.Sig) || p.Frame != decoded.Frame {
+			t.Errorf("expected equal packets after round trip")
+		}
+	})
+}
+
+func FuzzListen(f *testing.F) {
+	// Every input is streamed between sessions with the same key rings, so the seeds signed
+	// by the sender are verified by the receiver.
+	krA, errA := newKeyRing()
+	krB, errB := newKeyRing()
+	if errA != nil || errB != nil {
+		f.Fatal("error generating key rings")
+	}
+
+	sA, _ := mockSecureSessionsWith(f, krA, krB)
+	msg := []byte("hello")
+	data := marshall(packet{sA.Sign(msg), msg, dataFrame, 0})
+	f.Add(data.Bytes(), true, uint32(0))
+	f.Add(data.Bytes(), false, uint32(0))
+	f.Add([]byte{}, true, uint32(0))
+	f.Add([]byte("garbage"), false, uint32(1<<31))
+
+	f.Fuzz(func(t *testing.T, body []byte, encrypt bool, declared uint32) {
+		sender, receiver := mockSecureSessionsWith(t, krA, krB)
+		if encrypt {
+			body, _ = sender.Encrypt(nil, body)
+		}
+
+		// Stream the frame and close, the receiver must never wait for more data.
+		conn := sender.Conn
+		go func() {
+			conn.Write(fuzzFrame(body, declared))
+			conn.Close()
+		}()
+
+		p := newPeer(receiver)
+		p.BindPool(newBufferPool(1024))
+		packet, err := p.Listen()
+		if err == nil && packet == nil {
+			t.Errorf("expected packet or error")
+		}
+
+		receiver.Close()
+	})
+}
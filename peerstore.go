@@ -0,0 +1,439 @@
+package noise
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// maxMetricsHistory is the number of connections kept in the metrics history of each peer.
+const maxMetricsHistory = 16
+
+// maxPeerAddrs is the number of addresses kept for each peer, the least recently seen are dropped first.
+const maxPeerAddrs = 8
+
+// maxStoredPeers is the number of peers kept in a [PeerStoreFile], the least recently seen are dropped first.
+const maxStoredPeers = 1024
+
+// peerStoreFlushDelay is the time changes are batched before a [PeerStoreFile] is written to file.
+const peerStoreFlushDelay = 2 * time.Second
+
+// Sources for peer addresses in peerstore.
+const (
+	// Address dialed by local node.
+	SourceDialed = "dialed"
+	// Address learned through DHT and proved by handshake.
+	SourceDHT = "dht"
+	// Address suggested by another peer during peer exchange.
+	SourcePEX = "pex"
+	// Address announced in local network.
+	SourceLAN = "lan"
+)
+
+// [PeerAddr] is an address known for a peer.
+type PeerAddr struct {
+	Addr     string
+	Source   string
+	LastSeen time.Time
+}
+
+// [ConnectionMetrics] summarizes a closed connection with a peer.
+type ConnectionMetrics struct {
+	Connected time.Time
+	Duration  time.Duration
+	BytesSent uint64
+	BytesRecv uint64
+}
+
+// [PeerInfo] holds what is known about a peer across restarts.
+type PeerInfo struct {
+	ID        ID
+	PublicKey PublicKey
+	StaticKey []byte // static DH key used to encrypt forwarded messages
+	Addrs     []PeerAddr
+	Protocols []string
+	Metrics   []ConnectionMetrics
+}
+
+// LastSeen returns the most recent time any address of peer was seen.
+func (p PeerInfo) LastSeen() time.Time {
+	var last time.Time
+	for _, a := range p.Addrs {
+		if a.LastSeen.After(last) {
+			last = a.LastSeen
+		}
+	}
+
+	return last
+}
+
+// addAddr adds or refreshes the address keeping the most recently seen addresses first.
+func (p *PeerInfo) addAddr(addr, source string) {
+	// The slice could be shared with the store, build a new one.
+	addrs := []PeerAddr{{addr, source, time.Now()}}
+	for _, a := range p.Addrs {
+		if a.Addr != addr && len(addrs) < maxPeerAddrs {
+			addrs = append(addrs, a)
+		}
+	}
+
+	p.Addrs = addrs
+}
+
+// addProtocol adds the protocol if it isn't already known.
+// It returns true if the protocol was added.
+func (p *PeerInfo) addProtocol(protocol string) bool {
+	for _, known := range p.Protocols {
+		if known == protocol {
+			return false
+		}
+	}
+
+	p.Protocols = append(p.Protocols, protocol)
+	return true
+}
+
+// addMetrics appends a connection to metrics history dropping the oldest connections.
+func (p *PeerInfo) addMetrics(m ConnectionMetrics) {
+	p.Metrics = append(p.Metrics, m)
+	if len(p.Metrics) > maxMetricsHistory {
+		p.Metrics = p.Metrics[len(p.Metrics)-maxMetricsHistory:]
+	}
+}
+
+// [PeerStore] keeps the information known about peers.
+// Please see [Node.SetPeerStore] to use a custom store.
+type PeerStore interface {
+	// Get returns the information for peer ID.
+	Get(id ID) (PeerInfo, bool)
+	// Put stores the information for peer replacing any previous information.
+	Put(info PeerInfo) error
+	// All returns the information for every known peer.
+	All() []PeerInfo
+	// Flush writes any pending change to persistent storage.
+	Flush() error
+}
+
+// [PeerStoreFile] implements a [PeerStore] backed by a JSON file.
+// If the path is empty the store is kept only in memory.
+// Changes are batched and written to file after a short delay or on [PeerStoreFile.Flush].
+type PeerStoreFile struct {
+	mu    sync.RWMutex
+	path  string
+	peers map[ID]PeerInfo
+	dirty bool
+	timer *time.Timer
+}
+
+// peerRecord is the persistence format for peer information.
+type peerRecord struct {
+	ID        []byte              `json:"id"`
+	PublicKey []byte              `json:"public_key,omitempty"`
+	StaticKey []byte              `json:"static_key,omitempty"`
+	Addrs     []PeerAddr          `json:"addrs,omitempty"`
+	Protocols []string            `json:"protocols,omitempty"`
+	Metrics   []ConnectionMetrics `json:"metrics,omitempty"`
+}
+
+// NewPeerStoreFile creates a new store loading the known peers from file.
+// A missing file is not an error, the file is created when the first peer is stored.
+func NewPeerStoreFile(path string) (*PeerStoreFile, error) {
+	s := &PeerStoreFile{path: path, peers: make(map[ID]PeerInfo)}
+	if path == "" {
+		return s, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var records []peerRecord
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, err
+	}
+
+	for _, r := range records {
+		if len(r.ID) != len(ID{}) {
+			return nil, errors.New("invalid peer id in peerstore")
+		}
+
+		id := newIDFromString(string(r.ID))
+		s.peers[id] = PeerInfo{id, r.PublicKey, r.StaticKey, r.Addrs, r.Protocols, r.Metrics}
+	}
+
+	return s, nil
+}
+
+// Get returns the information for peer ID.
+func (s *PeerStoreFile) Get(id ID) (PeerInfo, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	info, ok := s.peers[id]
+	return info, ok
+}
+
+// Put stores the information for peer and schedules the store to be written to file.
+// If the store is full the least recently seen peer is dropped.
+func (s *PeerStoreFile) Put(info PeerInfo) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.peers[info.ID]; !ok && len(s.peers) >= maxStoredPeers {
+		s.evict()
+	}
+
+	s.peers[info.ID] = info
+	if s.path == "" {
+		return nil
+	}
+
+	s.dirty = true
+	if s.timer == nil {
+		s.timer = time.AfterFunc(peerStoreFlushDelay, func() {
+			if err := s.Flush(); err != nil {
+				log.Printf("error flushing peerstore: %v", err)
+			}
+		})
+	}
+
+	return nil
+}
+
+// Flush writes the pending changes to file.
+func (s *PeerStoreFile) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+
+	if !s.dirty {
+		return nil
+	}
+
+	if err := s.save(); err != nil {
+		return err
+	}
+
+	s.dirty = false
+	return nil
+}
+
+// evict drops the least recently seen peer.
+// The caller must hold the lock.
+func (s *PeerStoreFile) evict() {
+	var oldest ID
+	var last time.Time
+	first := true
+	for id, info := range s.peers {
+		if seen := info.LastSeen(); first || seen.Before(last) {
+			oldest, last, first = id, seen, false
+		}
+	}
+
+	delete(s.peers, oldest)
+}
+
+// All returns the information for every known peer.
+func (s *PeerStoreFile) All() []PeerInfo {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	peers := make([]PeerInfo, 0, len(s.peers))
+	for _, info := range s.peers {
+		peers = append(peers, info)
+	}
+
+	return peers
+}
+
+// save writes the peers to file.
+// The caller must hold the lock.
+func (s *PeerStoreFile) save() error {
+	if s.path == "" {
+		return nil
+	}
+
+	records := make([]peerRecord, 0, len(s.peers))
+	for _, p := range s.peers {
+		records = append(records, peerRecord{p.ID.Bytes(), p.PublicKey, p.StaticKey, p.Addrs, p.Protocols, p.Metrics})
+	}
+
+	data, err := json.Marshal(records)
+	if err != nil {
+		return err
+	}
+
+	// Replace the file atomically to avoid partial writes on crashes.
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), s.path)
+}
+
+// SetPeerStore sets the store used to keep the information known about peers.
+func (n *Node) SetPeerStore(store PeerStore) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.peers = store
+}
+
+// peerStore returns the current peerstore.
+func (n *Node) peerStore() PeerStore {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.peers
+}
+
+// PeerInfo returns the information known about peer ID.
+func (n *Node) PeerInfo(rawID string) (PeerInfo, bool) {
+	return n.peerStore().Get(newIDFromString(rawID))
+}
+
+// remember updates the information for peer ID in peerstore.
+// Updates are serialized to avoid losing concurrent changes for the same peer.
+func (n *Node) remember(id ID, update func(*PeerInfo) bool) {
+	if id == n.ID() {
+		return
+	}
+
+	n.pmu.Lock()
+	defer n.pmu.Unlock()
+	store := n.peerStore()
+	info, ok := store.Get(id)
+	if !ok {
+		info = PeerInfo{ID: id}
+	}
+
+	if !update(&info) {
+		return
+	}
+
+	if err := store.Put(info); err != nil {
+		log.Printf("error storing peer info: %v", err)
+	}
+}
+
+// rememberAddr records an address for peer ID with its source.
+func (n *Node) rememberAddr(id ID, addr, source string) {
+	if addr == "" {
+		return
+	}
+
+	n.remember(id, func(info *PeerInfo) bool {
+		info.addAddr(addr, source)
+		return true
+	})
+}
+
+// rememberPeer records the keys of a new connected peer and the dialed address if any.
+func (n *Node) rememberPeer(peer *peer, addr string) {
+	n.remember(peer.ID(), func(info *PeerInfo) bool {
+		info.PublicKey = peer.s.RemotePublicKey()
+		info.StaticKey = peer.s.RemoteStatic()
+		if addr != "" {
+			info.addAddr(addr, SourceDialed)
+		}
+
+		return true
+	})
+}
+
+// rememberProtocol records a protocol supported by peer the first time it is used.
+func (n *Node) rememberProtocol(peer *peer, f frame) {
+	if !peer.Observe(f) {
+		return
+	}
+
+	n.remember(peer.ID(), func(info *PeerInfo) bool {
+		return info.addProtocol(f.String())
+	})
+}
+
+// rememberMetrics appends the closed connection metrics to peer history.
+func (n *Node) rememberMetrics(peer *peer) {
+	m := ConnectionMetrics{
+		Connected: peer.created,
+		Duration:  peer.Age(),
+		BytesSent: peer.m.BytesSent(),
+		BytesRecv: peer.m.BytesRecv(),
+	}
+
+	n.remember(peer.ID(), func(info *PeerInfo) bool {
+		info.addMetrics(m)
+		return true
+	})
+}
+
+// redial dials the most recently seen peers from peerstore when the node starts listening.
+// Up to RedialKnownPeers peers are dialed concurrently, each address is tried once in order of last seen.
+// An address is accepted only if the handshake proves the known peer identity at the address.
+func (n *Node) redial(ctx context.Context) {
+	defer n.wg.Done()
+	known := n.peerStore().All()
+	sort.Slice(known, func(i, j int) bool {
+		return known[i].LastSeen().After(known[j].LastSeen())
+	})
+
+	var wg sync.WaitGroup
+	max := n.config.RedialKnownPeers()
+	for _, info := range known {
+		if max == 0 {
+			break
+		}
+
+		if info.ID == n.ID() || n.connected(info.ID) || len(info.Addrs) == 0 {
+			continue
+		}
+
+		max--
+		wg.Add(1)
+		go func(info PeerInfo) {
+			defer wg.Done()
+			for _, a := range info.Addrs {
+				peer, opened, err := n.connect(ctx, a.Addr)
+				if err != nil {
+					continue
+				}
+
+				// Another peer could be listening at the known address now, only a connection opened here is closed.
+				if peer.ID() != info.ID {
+					log.Printf("known peer identity mismatch at %s", a.Addr)
+					if opened {
+						n.hangUp(peer)
+					}
+
+					continue
+				}
+
+				peer.Keep()
+				log.Printf("known peer connected: %s", a.Addr)
+				return
+			}
+		}(info)
+	}
+
+	wg.Wait()
+}
@@ -0,0 +1,205 @@
+package noise
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/geolffreym/p2p-noise/config"
+)
+
+func TestPeerStoreFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "peers.json")
+	store, err := NewPeerStoreFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(store.All()) != 0 {
+		t.Errorf("expected empty store for missing file")
+	}
+
+	info := PeerInfo{ID: peerA.ID(), Protocols: []string{"dht"}}
+	info.addAddr(MOCK_ADDRESS, SourceDialed)
+	info.addMetrics(ConnectionMetrics{Duration: time.Second, BytesSent: 10, BytesRecv: 20})
+	store.Put(info)
+	store.Put(PeerInfo{ID: peerB.ID()})
+
+	// Writes are batched until flushed.
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected file written only after flush")
+	}
+
+	if err := store.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	restored, err := NewPeerStoreFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, ok := restored.Get(peerA.ID())
+	if !ok || len(restored.All()) != 2 {
+		t.Fatalf("expected peers restored from file")
+	}
+
+	if got.Addrs[0].Addr != MOCK_ADDRESS || got.Addrs[0].Source != SourceDialed || got.Protocols[0] != "dht" {
+		t.Errorf("expected address and protocols restored, got %+v", got)
+	}
+
+	if len(got.Metrics) != 1 || got.Metrics[0].BytesRecv != 20 || got.Metrics[0].Duration != time.Second {
+		t.Errorf("expected metrics history restored, got %+v", got.Metrics)
+	}
+}
+
+func TestPeerStoreFileLimit(t *testing.T) {
+	store, _ := NewPeerStoreFile("")
+	for i := 0; i < maxStoredPeers; i++ {
+		info := PeerInfo{ID: ID{byte(i), byte(i >> 8), 1}}
+		info.addAddr(MOCK_ADDRESS, SourceDialed)
+		store.Put(info)
+	}
+
+	// The peer without addresses is the least recently seen.
+	stale := store.All()[0].ID
+	store.Put(PeerInfo{ID: stale})
+	store.Put(PeerInfo{ID: ID{2}, Addrs: []PeerAddr{{MOCK_ADDRESS, SourceDialed, time.Now()}}})
+	if len(store.All()) != maxStoredPeers {
+		t.Errorf("expected max %d peers stored, got %d", maxStoredPeers, len(store.All()))
+	}
+
+	if _, ok := store.Get(stale); ok {
+		t.Errorf("expected least recently seen peer evicted")
+	}
+}
+
+func TestPeerStoreInvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "peers.json")
+	os.WriteFile(path, []byte(`[{"id":"aW52YWxpZA=="}]`), 0o600)
+
+	if _, err := NewPeerStoreFile(path); err == nil {
+		t.Errorf("expected error for invalid peerstore file")
+	}
+}
+
+func TestPeerInfoHistory(t *testing.T) {
+	var info PeerInfo
+	for i := 0; i < maxPeerAddrs+2; i++ {
+		info.addAddr(string(rune('a'+i)), SourcePEX)
+	}
+
+	info.addAddr("c", SourceDHT)
+	if len(info.Addrs) != maxPeerAddrs || info.Addrs[0].Addr != "c" || info.Addrs[0].Source != SourceDHT {
+		t.Errorf("expected refreshed address first and max %d addresses, got %+v", maxPeerAddrs, info.Addrs)
+	}
+
+	if info.LastSeen() != info.Addrs[0].LastSeen {
+		t.Errorf("expected last seen from most recent address")
+	}
+
+	if !info.addProtocol("dht") || info.addProtocol("dht") {
+		t.Errorf("expected protocol added once")
+	}
+
+	for i := 0; i < maxMetricsHistory+1; i++ {
+		info.addMetrics(ConnectionMetrics{BytesSent: uint64(i)})
+	}
+
+	if len(info.Metrics) != maxMetricsHistory || info.Metrics[0].BytesSent != 1 {
+		t.Errorf("expected oldest connection dropped from history")
+	}
+}
+
+func TestRedialKnownPeers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "peers.json")
+	configurationA := config.New()
+	configurationA.Write(config.SetSelfListeningAddress("127.0.0.1:"))
+	nodeA := New(configurationA)
+	defer nodeA.Close()
+	<-whenReadyForIncomingDial(nodeA)
+	addr := nodeA.LocalAddr().String()
+
+	configurationB := config.New()
+	configurationB.Write(
+		config.SetSelfListeningAddress("127.0.0.1:"),
+		config.SetPeerStorePath(path),
+	)
+
+	nodeB := New(configurationB)
+	if err := nodeB.Dial(addr); err != nil {
+		t.Fatal(err)
+	}
+
+	nodeB.Close()
+	info, ok := nodeB.PeerInfo(nodeA.ID().String())
+	if !ok || len(info.Addrs) == 0 || info.Addrs[0].Addr != addr {
+		t.Fatalf("expected dialed address stored, got %+v", info)
+	}
+
+	if len(info.PublicKey) == 0 || len(info.Metrics) != 1 {
+		t.Errorf("expected public key and connection metrics stored, got %+v", info)
+	}
+
+	// A new node using the same peerstore dials the known peer on start.
+	configurationC := config.New()
+	configurationC.Write(
+		config.SetSelfListeningAddress("127.0.0.1:"),
+		config.SetPeerStorePath(path),
+	)
+
+	nodeC := New(configurationC)
+	defer nodeC.Close()
+	signals, _ := nodeC.Signals()
+	go nodeC.Listen()
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case signal := <-signals:
+			if signal.Type() == NewPeerDetected {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("expected known peer redialed")
+		}
+	}
+}
+
+func TestRedialIdentityMismatch(t *testing.T) {
+	configurationA := config.New()
+	configurationA.Write(config.SetSelfListeningAddress("127.0.0.1:"))
+	nodeA := New(configurationA)
+	defer nodeA.Close()
+	<-whenReadyForIncomingDial(nodeA)
+
+	// Another peer is listening at the known address.
+	store, _ := NewPeerStoreFile("")
+	store.Put(PeerInfo{
+		ID:    mockID(PeerBPb),
+		Addrs: []PeerAddr{{nodeA.LocalAddr().String(), SourceDHT, time.Now()}},
+	})
+
+	configurationC := config.New()
+	configurationC.Write(
+		config.SetSelfListeningAddress("127.0.0.1:"),
+		config.SetDHTRefreshInterval(0),
+	)
+
+	nodeC := New(configurationC)
+	defer nodeC.Close()
+	nodeC.SetPeerStore(store)
+	signals, cancel := nodeC.Signals()
+	defer cancel()
+	go nodeC.Listen()
+
+	if _, ok := waitFor(signals, NewPeerDetected, 2*time.Second); !ok {
+		t.Fatalf("expected known address dialed")
+	}
+
+	if _, ok := waitFor(signals, PeerDisconnected, 2*time.Second); !ok {
+		t.Errorf("expected mismatched peer hung up")
+	}
+}
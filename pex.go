@@ -0,0 +1,407 @@
+package noise
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"log"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// maxPexPeers is the max number of addresses exchanged in a single response.
+const maxPexPeers = 16
+
+// maxAddressBookSize is the max number of addresses kept in address book.
+const maxAddressBookSize = 1024
+
+// maxAddressFailures is the number of consecutive failed dials before an address is forgotten.
+const maxAddressFailures = 3
+
+// pexInterval is the interval between checks of the connected peers against the target degree.
+// A connected peer is asked for addresses at most once during the interval.
+const pexInterval = 30 * time.Second
+
+// pexKind identify the kind of message exchanged by the peer exchange protocol.
+type pexKind uint8
+
+const (
+	// Request the known addresses from peer.
+	pexRequest pexKind = iota
+	// Response with a sample of known addresses.
+	pexPeers
+)
+
+// pexMessage is the request and response exchanged between peers in pex frames.
+type pexMessage struct {
+	Kind  pexKind
+	Peers []contact
+}
+
+// encodePEX encode a pex message to bytes.
+func encodePEX(m pexMessage) []byte {
+	var buffer bytes.Buffer
+	gob.NewEncoder(&buffer).Encode(m)
+	return buffer.Bytes()
+}
+
+// decodePEX decode incoming bytes to a pex message.
+func decodePEX(b []byte) (pexMessage, error) {
+	var m pexMessage
+	err := gob.NewDecoder(bytes.NewReader(b)).Decode(&m)
+	return m, err
+}
+
+// address is a peer address learned from a remote peer.
+type address struct {
+	contact
+	source   ID // peer suggesting the address
+	failures int
+}
+
+// addressBook keeps the peer addresses learned through peer exchange.
+// To avoid the eclipse of the node by a single malicious peer, each source can suggest a limited number
+// of addresses, the first source suggesting an address keeps it and the dial candidates are picked
+// from different sources.
+type addressBook struct {
+	mu           sync.Mutex
+	addrs        map[ID]*address
+	sources      map[ID]int // number of addresses suggested by source
+	maxPerSource int
+}
+
+func newAddressBook(maxPerSource int) *addressBook {
+	return &addressBook{
+		addrs:        make(map[ID]*address),
+		sources:      make(map[ID]int),
+		maxPerSource: maxPerSource,
+	}
+}
+
+// Add stores the address suggested by source.
+// It returns false if the address is already known, the source exceeded its limit or the book is full.
+func (b *addressBook) Add(c contact, source ID) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.addrs[c.ID]; ok || c.Addr == "" {
+		return false
+	}
+
+	if b.sources[source] >= b.maxPerSource || len(b.addrs) >= maxAddressBookSize {
+		return false
+	}
+
+	b.addrs[c.ID] = &address{c, source, 0}
+	b.sources[source]++
+	return true
+}
+
+// Remove forgets the address for peer.
+func (b *addressBook) Remove(id ID) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.remove(id)
+}
+
+// remove forgets the address for peer releasing the source slot.
+// The caller must hold the lock.
+func (b *addressBook) remove(id ID) {
+	a, ok := b.addrs[id]
+	if !ok {
+		return
+	}
+
+	delete(b.addrs, id)
+	if b.sources[a.source]--; b.sources[a.source] <= 0 {
+		delete(b.sources, a.source)
+	}
+}
+
+// Failed register a failed dial to peer address.
+// After maxAddressFailures consecutive failures the address is forgotten.
+func (b *addressBook) Failed(id ID) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if a, ok := b.addrs[id]; ok {
+		if a.failures++; a.failures >= maxAddressFailures {
+			b.remove(id)
+		}
+	}
+}
+
+// Succeeded resets the failures for peer address.
+func (b *addressBook) Succeeded(id ID) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if a, ok := b.addrs[id]; ok {
+		a.failures = 0
+	}
+}
+
+// Sample returns up to count random addresses.
+func (b *addressBook) Sample(count int, exclude ID) []contact {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	sample := make([]contact, 0, count)
+	// Map iteration order is random enough to not favor any address.
+	for id, a := range b.addrs {
+		if len(sample) == count {
+			break
+		}
+
+		if id != exclude {
+			sample = append(sample, a.contact)
+		}
+	}
+
+	return sample
+}
+
+// Candidates returns up to count addresses to dial, picking one address from each source in turn.
+// Addresses for which skip returns true are not returned eg. already connected peers.
+func (b *addressBook) Candidates(count int, skip func(ID) bool) []contact {
+	b.mu.Lock()
+	bySource := make(map[ID][]contact)
+	for id, a := range b.addrs {
+		if !skip(id) {
+			bySource[a.source] = append(bySource[a.source], a.contact)
+		}
+	}
+	b.mu.Unlock()
+
+	sources := make([][]contact, 0, len(bySource))
+	for _, contacts := range bySource {
+		rand.Shuffle(len(contacts), func(i, j int) { contacts[i], contacts[j] = contacts[j], contacts[i] })
+		sources = append(sources, contacts)
+	}
+
+	rand.Shuffle(len(sources), func(i, j int) { sources[i], sources[j] = sources[j], sources[i] })
+	var candidates []contact
+	for round := 0; len(candidates) < count; round++ {
+		picked := false
+		for _, contacts := range sources {
+			if round < len(contacts) && len(candidates) < count {
+				candidates = append(candidates, contacts[round])
+				picked = true
+			}
+		}
+
+		if !picked {
+			break
+		}
+	}
+
+	return candidates
+}
+
+// Len returns the number of known addresses.
+func (b *addressBook) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.addrs)
+}
+
+// pex holds the peer exchange state for node.
+type pex struct {
+	book    *addressBook
+	started atomic.Bool // peer exchange routine started
+	wake    chan struct{}
+	mu      sync.Mutex // guard asked peers
+	asked   map[ID]time.Time
+	pending map[ID]bool // peers expected to answer with addresses
+}
+
+func newPEX(maxPerSource int) *pex {
+	return &pex{
+		book:    newAddressBook(maxPerSource),
+		wake:    make(chan struct{}, 1),
+		asked:   make(map[ID]time.Time),
+		pending: make(map[ID]bool),
+	}
+}
+
+// Wake signals the peer exchange routine to check the target degree.
+func (p *pex) Wake() {
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Forget clears the address requests for a disconnected peer.
+func (p *pex) Forget(id ID) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.asked, id)
+	delete(p.pending, id)
+}
+
+// exchange asks a new connected peer for addresses if the node needs more peers.
+// The first connected peer starts the peer exchange routine.
+func (n *Node) exchange(peer *peer) {
+	target := n.config.TargetDegree()
+	if target <= 0 {
+		return
+	}
+
+	if n.pex.started.CompareAndSwap(false, true) && n.track() {
+		go n.pexLoop(target)
+	}
+
+	if int(n.router.Len()) < target {
+		n.askAddresses(peer)
+	}
+}
+
+// askAddresses sends an address request to peer unless it was asked during the last interval.
+func (n *Node) askAddresses(peer *peer) {
+	n.pex.mu.Lock()
+	if time.Since(n.pex.asked[peer.ID()]) < pexInterval {
+		n.pex.mu.Unlock()
+		return
+	}
+
+	n.pex.asked[peer.ID()] = time.Now()
+	n.pex.pending[peer.ID()] = true
+	n.pex.mu.Unlock()
+
+	if _, err := peer.send(pexFrame, encodePEX(pexMessage{Kind: pexRequest})); err != nil {
+		log.Printf("error requesting peers: %v", err)
+	}
+}
+
+// handlePEX process an incoming pex message from peer.
+// Requests are answered with connected peers and known addresses, responses are only accepted if requested.
+func (n *Node) handlePEX(peer *peer, msg []byte) {
+	m, err := decodePEX(msg)
+	if err != nil {
+		log.Printf("error decoding pex message: %v", err)
+		return
+	}
+
+	switch m.Kind {
+	case pexRequest:
+		res := pexMessage{Kind: pexPeers, Peers: n.knownAddresses(peer.ID())}
+		if _, err := peer.send(pexFrame, encodePEX(res)); err != nil {
+			log.Printf("error answering peers request: %v", err)
+		}
+	case pexPeers:
+		n.pex.mu.Lock()
+		requested := n.pex.pending[peer.ID()]
+		delete(n.pex.pending, peer.ID())
+		n.pex.mu.Unlock()
+		// Unsolicited addresses could be used to poison the address book.
+		if !requested {
+			return
+		}
+
+		var added int
+		for i, c := range m.Peers {
+			if i == maxPexPeers {
+				break
+			}
+
+			if c.ID != n.ID() && n.pex.book.Add(c, peer.ID()) {
+				added++
+			}
+		}
+
+		if added > 0 {
+			n.pex.Wake()
+		}
+	}
+}
+
+// knownAddresses returns a sample of connected peers listening addresses and address book entries.
+func (n *Node) knownAddresses(exclude ID) []contact {
+	var peers []contact
+	for p := range n.router.Table() {
+		if len(peers) == maxPexPeers {
+			break
+		}
+
+		// Only the listening address of peers announced in DHT is known.
+		if c, ok := n.routingTable().Lookup(p.ID()); ok && p.ID() != exclude {
+			peers = append(peers, c)
+		}
+	}
+
+	return append(peers, n.pex.book.Sample(maxPexPeers-len(peers), exclude)...)
+}
+
+// fill dials address book candidates until target degree is reached.
+// If there aren't candidates the connected peers are asked for addresses.
+func (n *Node) fill(ctx context.Context, target int) {
+	missing := target - int(n.router.Len())
+	if missing <= 0 {
+		return
+	}
+
+	self := n.ID()
+	candidates := n.pex.book.Candidates(missing, func(id ID) bool {
+		return id == self || n.connected(id)
+	})
+
+	if len(candidates) == 0 {
+		for p := range n.router.Table() {
+			n.askAddresses(p)
+		}
+
+		return
+	}
+
+	var wg sync.WaitGroup
+	for _, c := range candidates {
+		wg.Add(1)
+		go func(c contact) {
+			defer wg.Done()
+			peer, opened, err := n.connect(ctx, c.Addr)
+			if err != nil {
+				log.Printf("error dialing exchanged peer: %v", err)
+				n.pex.book.Failed(c.ID)
+				return
+			}
+
+			// The suggested identity doesn't match the address.
+			// Another peer could be connected at the address, only a connection opened here is closed.
+			if peer.ID() != c.ID {
+				n.pex.book.Remove(c.ID)
+				if opened {
+					n.hangUp(peer)
+				}
+
+				return
+			}
+
+			peer.Keep()
+
+			n.pex.book.Succeeded(c.ID)
+			// Suggested addresses are persisted only once the handshake proved the identity.
+			n.rememberAddr(c.ID, c.Addr, SourcePEX)
+		}(c)
+	}
+
+	wg.Wait()
+}
+
+// pexLoop keeps the number of connected peers close to target degree until node is shutting down.
+// It runs on every interval, when new addresses are learned or when a peer get disconnected.
+func (n *Node) pexLoop(target int) {
+	defer n.wg.Done()
+	ctx, cancel := n.background()
+	defer cancel()
+
+	ticker := time.NewTicker(pexInterval)
+	defer ticker.Stop()
+	for {
+		n.fill(ctx, target)
+		select {
+		case <-ticker.C:
+		case <-n.pex.wake:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
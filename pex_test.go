@@ -0,0 +1,163 @@
+package noise
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/geolffreym/p2p-noise/config"
+)
+
+func TestAddressBookLimits(t *testing.T) {
+	book := newAddressBook(2)
+	sourceA, sourceB := ID{0xa}, ID{0xb}
+
+	adds := []struct {
+		id       byte
+		source   ID
+		expected bool
+	}{
+		{1, sourceA, true},
+		{2, sourceA, true},
+		{3, sourceA, false}, // source limit
+		{1, sourceB, false}, // already known from another source
+		{3, sourceB, true},
+	}
+
+	for _, e := range adds {
+		if book.Add(contact{ID{e.id}, MOCK_ADDRESS}, e.source) != e.expected {
+			t.Errorf("expected add %d from %x = %v", e.id, e.source[0], e.expected)
+		}
+	}
+
+	// Candidates are picked from every source before repeating a source.
+	candidates := book.Candidates(2, func(ID) bool { return false })
+	if len(candidates) != 2 || (candidates[0].ID == ID{3}) == (candidates[1].ID == ID{3}) {
+		t.Errorf("expected candidates from different sources, got %v", candidates)
+	}
+
+	for i := 0; i < maxAddressFailures; i++ {
+		book.Failed(ID{1})
+	}
+
+	if book.Len() != 2 || !book.Add(contact{ID{4}, MOCK_ADDRESS}, sourceA) {
+		t.Errorf("expected failing address forgotten releasing the source slot")
+	}
+
+	if sample := book.Sample(10, ID{3}); len(sample) != 2 {
+		t.Errorf("expected 2 addresses in sample excluding peer, got %d", len(sample))
+	}
+}
+
+func TestPeerExchange(t *testing.T) {
+	nodes := make([]*Node, 4)
+	for i := range nodes {
+		configuration := config.New()
+		configuration.Write(config.SetSelfListeningAddress("127.0.0.1:"))
+		nodes[i] = New(configuration)
+		defer nodes[i].Close()
+		<-whenReadyForIncomingDial(nodes[i])
+	}
+
+	hub := nodes[0]
+	for _, node := range nodes[1:] {
+		if err := node.Dial(hub.LocalAddr().String()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Wait until the hub knows the listening address of every peer.
+	timeout := time.After(2 * time.Second)
+	for hub.routingTable().Len() != len(nodes)-1 {
+		select {
+		case <-timeout:
+			t.Fatalf("expected peers announced to hub")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	configuration := config.New()
+	configuration.Write(
+		config.SetTargetDegree(3),
+		// Lookups could connect to other peers too.
+		config.SetDHTRefreshInterval(0),
+	)
+	node := New(configuration)
+	defer node.Close()
+
+	// Only the hub is dialed, the other peers are learned from the hub.
+	if err := node.Dial(hub.LocalAddr().String()); err != nil {
+		t.Fatal(err)
+	}
+
+	for node.router.Len() != 3 {
+		select {
+		case <-timeout:
+			t.Fatalf("expected target degree reached, got %d peers", node.router.Len())
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func TestPeerExchangeMismatch(t *testing.T) {
+	configurationA := config.New()
+	configurationA.Write(config.SetSelfListeningAddress("127.0.0.1:"))
+	nodeA := New(configurationA)
+	defer nodeA.Close()
+	<-whenReadyForIncomingDial(nodeA)
+
+	configurationB := config.New()
+	configurationB.Write(config.SetDHTRefreshInterval(0))
+	nodeB := New(configurationB)
+	defer nodeB.Close()
+
+	// The suggested identity doesn't match the peer listening in address.
+	suggested := contact{ID{1}, nodeA.LocalAddr().String()}
+	nodeB.pex.book.Add(suggested, ID{2})
+	nodeB.fill(context.Background(), 1)
+	if len(nodeB.pex.book.Candidates(1, func(ID) bool { return false })) != 0 {
+		t.Errorf("expected mismatched peer removed from address book")
+	}
+
+	timeout := time.After(2 * time.Second)
+	for nodeB.connected(nodeA.ID()) {
+		select {
+		case <-timeout:
+			t.Fatalf("expected mismatched peer closed")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	if _, ok := nodeB.PeerInfo(suggested.ID.String()); ok {
+		t.Errorf("expected suggested address not stored")
+	}
+
+	// A connected peer is kept when its address is suggested with another identity,
+	// even if the connection was opened for a request.
+	if _, _, err := nodeB.connect(context.Background(), nodeA.LocalAddr().String()); err != nil {
+		t.Fatal(err)
+	}
+
+	nodeB.pex.book.Add(suggested, ID{2})
+	nodeB.fill(context.Background(), 2)
+	time.Sleep(50 * time.Millisecond)
+	if !nodeB.connected(nodeA.ID()) {
+		t.Fatalf("expected connected peer kept")
+	}
+
+	// The address requests are forgotten on disconnect.
+	peer, _ := nodeB.router.Query(nodeA.ID())
+	nodeB.askAddresses(peer)
+	nodeB.ClosePeer(nodeA.ID().String(), ReasonLocalClose)
+	select {
+	case <-peer.done:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("expected peer disconnected")
+	}
+
+	nodeB.pex.mu.Lock()
+	defer nodeB.pex.mu.Unlock()
+	if len(nodeB.pex.asked) != 0 || len(nodeB.pex.pending) != 0 {
+		t.Errorf("expected address requests forgotten for disconnected peer")
+	}
+}
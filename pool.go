@@ -0,0 +1,78 @@
+package noise
+
+import "sync"
+
+// minBufferSize is the smallest buffer size class in pool.
+const minBufferSize = 512
+
+// bufferPool implements a pool of []byte grouped by size classes.
+// Each class holds buffers with power of two capacity up to max buffer size.
+// Buffers are allocated on demand based on the needed size instead of allocating max size buffers for every peer,
+// and the idle buffers are released by garbage collector.
+type bufferPool struct {
+	classes []sync.Pool
+	sizes   []int
+	max     int
+}
+
+// newBufferPool creates a new pool with buffers up to max size.
+func newBufferPool(max int) *bufferPool {
+	sizes := []int{}
+	for size := minBufferSize; ; size <<= 1 {
+		if size >= max {
+			sizes = append(sizes, max)
+			break
+		}
+
+		sizes = append(sizes, size)
+	}
+
+	classes := make([]sync.Pool, len(sizes))
+	for i := range classes {
+		width := sizes[i]
+		classes[i].New = func() any {
+			// store pointer to avoid allocations when slice is converted to interface.
+			// ref: https://staticcheck.io/docs/checks#SA6002
+			b := make([]byte, width)
+			return &b
+		}
+	}
+
+	return &bufferPool{classes, sizes, max}
+}
+
+// Max returns the max buffer size allowed in pool.
+func (p *bufferPool) Max() int {
+	return p.max
+}
+
+// class returns the index for the smallest size class that fits size.
+// If size exceeds the max buffer size the biggest class is returned.
+func (p *bufferPool) class(size int) int {
+	for i, width := range p.sizes {
+		if size <= width {
+			return i
+		}
+	}
+
+	return len(p.sizes) - 1
+}
+
+// Get returns a buffer with len equal to size class that fits size.
+// Buffers bigger than max buffer size are never allocated.
+func (p *bufferPool) Get(size int) []byte {
+	b := p.classes[p.class(size)].Get().(*[]byte)
+	return *b
+}
+
+// Put returns the buffer to its size class.
+// Buffers not created by pool are discarded.
+func (p *bufferPool) Put(b []byte) {
+	i := p.class(cap(b))
+	if cap(b) != p.sizes[i] {
+		return
+	}
+
+	b = b[:cap(b)]
+	p.classes[i].Put(&b)
+}
@@ -0,0 +1,50 @@
+package noise
+
+import "testing"
+
+func TestBufferPoolSizeClasses(t *testing.T) {
+	pool := newBufferPool(10 << 10) // 10KB
+
+	sizes := []struct {
+		size     int
+		expected int
+	}{
+		{1, minBufferSize},
+		{minBufferSize, minBufferSize},
+		{minBufferSize + 1, minBufferSize << 1},
+		{5000, 8 << 10},
+		{10 << 10, 10 << 10},
+		// never allocate more than max buffer size
+		{1 << 20, 10 << 10},
+	}
+
+	for _, e := range sizes {
+		buffer := pool.Get(e.size)
+		if len(buffer) != e.expected {
+			t.Errorf("expected buffer len %d for size %d, got %d", e.expected, e.size, len(buffer))
+		}
+
+		pool.Put(buffer)
+	}
+}
+
+func TestBufferPoolPutForeign(t *testing.T) {
+	pool := newBufferPool(1 << 10)
+	// Buffers not created by pool are discarded
+	pool.Put(make([]byte, 100))
+
+	if buffer := pool.Get(100); len(buffer) != minBufferSize {
+		t.Errorf("expected buffer len %d, got %d", minBufferSize, len(buffer))
+	}
+}
+
+func BenchmarkBufferPool(b *testing.B) {
+	pool := newBufferPool(10 << 20)
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			buffer := pool.Get(1 << 10)
+			pool.Put(buffer)
+		}
+	})
+}
@@ -0,0 +1,651 @@
+package noise
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"log"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// maxTopicLength is the max length allowed for a topic name.
+const maxTopicLength = 256
+
+// maxTopicsPerPeer is the max number of topic subscriptions tracked for a remote peer.
+const maxTopicsPerPeer = 64
+
+// maxGossipIDs is the max number of message IDs exchanged in a single gossip message.
+const maxGossipIDs = 64
+
+// topicBufferSize is the number of messages buffered for a subscription before new messages are dropped.
+const topicBufferSize = 64
+
+// seenTTL is the time a delivered message ID is remembered to suppress duplicates.
+const seenTTL = 2 * time.Minute
+
+// Message IDs are gossiped during gossipWindows heartbeats and the messages are kept during
+// historyWindows heartbeats to answer the peers asking for them.
+const (
+	gossipWindows  = 3
+	historyWindows = 5
+)
+
+// pubsubDomain separates the topic message signatures from other messages signed with the node identity.
+const pubsubDomain = "p2p-noise/pubsub"
+
+// pubsubKind identify the kind of message exchanged by the pubsub protocol.
+type pubsubKind uint8
+
+const (
+	// Announce a topic subscription.
+	pubsubSubscribe pubsubKind = iota
+	// Announce a topic unsubscription.
+	pubsubUnsubscribe
+	// Message published in topic.
+	pubsubPublish
+	// Request to join the topic mesh.
+	pubsubGraft
+	// Notify the peer was removed from topic mesh.
+	pubsubPrune
+	// Advertise the IDs of recently seen messages.
+	pubsubIHave
+	// Request the messages for the advertised IDs.
+	pubsubIWant
+)
+
+// pubsubMessage is the control and data message exchanged between peers in pubsub frames.
+type pubsubMessage struct {
+	Kind    pubsubKind
+	Topic   string
+	Message Message
+	IDs     []ID
+}
+
+// encodePubSub encode a pubsub message to bytes.
+func encodePubSub(m pubsubMessage) []byte {
+	var buffer bytes.Buffer
+	gob.NewEncoder(&buffer).Encode(m)
+	return buffer.Bytes()
+}
+
+// decodePubSub decode incoming bytes to a pubsub message.
+func decodePubSub(b []byte) (pubsubMessage, error) {
+	var m pubsubMessage
+	err := gob.NewDecoder(bytes.NewReader(b)).Decode(&m)
+	return m, err
+}
+
+// [Message] is a message published in a topic and signed by the publisher identity key.
+type Message struct {
+	Topic     string
+	Data      []byte
+	Seq       uint64
+	Publisher PublicKey
+	Signature []byte
+}
+
+// ID returns the message ID used to suppress duplicates.
+func (m Message) ID() ID {
+	return newBlake2ID(append(m.signedBytes(), m.Publisher...))
+}
+
+// From returns the publisher ID.
+func (m Message) From() ID {
+	return newBlake2ID(m.Publisher)
+}
+
+// signedBytes returns the message fields covered by signature.
+func (m Message) signedBytes() []byte {
+	var buffer bytes.Buffer
+	buffer.WriteString(pubsubDomain)
+	binary.Write(&buffer, binary.BigEndian, uint32(len(m.Topic)))
+	buffer.WriteString(m.Topic)
+	binary.Write(&buffer, binary.BigEndian, uint32(len(m.Data)))
+	buffer.Write(m.Data)
+	binary.Write(&buffer, binary.BigEndian, m.Seq)
+	return buffer.Bytes()
+}
+
+// validateMessage checks the message topic and publisher signature.
+func validateMessage(m Message) error {
+	if m.Topic == "" || len(m.Topic) > maxTopicLength {
+		return errInvalidMessage(errors.New("invalid topic"))
+	}
+
+	if len(m.Publisher) != ed25519.PublicKeySize {
+		return errInvalidMessage(errors.New("invalid publisher key"))
+	}
+
+	if !ed25519.Verify(m.Publisher, m.signedBytes(), m.Signature) {
+		return errInvalidMessage(errors.New("invalid signature"))
+	}
+
+	return nil
+}
+
+// [Topic] is a subscription to a pubsub topic.
+// Please see [Node.Join] to create a subscription.
+type Topic struct {
+	n        *Node
+	name     string
+	messages chan Message
+}
+
+// Name returns the topic name.
+func (t *Topic) Name() string {
+	return t.name
+}
+
+// Messages returns the channel receiving the messages published by remote peers in topic.
+// If the messages are not consumed fast enough the new messages are dropped.
+// The channel is closed when the topic is left or the node is closed.
+func (t *Topic) Messages() <-chan Message {
+	return t.messages
+}
+
+// Publish signs the data with the node identity and sends it to the topic mesh.
+// Messages published by the local node are not delivered to its own subscription.
+// It returns an error if the topic was left.
+func (t *Topic) Publish(data []byte) error {
+	return t.n.publishTopic(t, data)
+}
+
+// Leave unsubscribe from topic and closes the messages channel.
+func (t *Topic) Leave() {
+	t.n.leave(t)
+}
+
+// pubsub holds the topic subscriptions and mesh state for node.
+type pubsub struct {
+	started atomic.Bool   // heartbeat routine started
+	seq     atomic.Uint64 // sequence for published messages
+	mu      sync.Mutex    // guard the topics state
+	closed  bool          // subscriptions closed on node shutdown
+	topics  map[string]*Topic
+	peers   map[string]map[ID]bool // topics subscribed by remote peers
+	counts  map[ID]int             // number of topics subscribed by each remote peer
+	mesh    map[string]map[ID]bool // mesh peers for joined topics
+	seen    map[ID]time.Time       // delivered message IDs
+	cache   map[ID]Message         // recent messages to answer gossip requests
+	history [][]ID                 // recent message IDs by heartbeat, most recent first
+}
+
+func newPubSub() *pubsub {
+	p := &pubsub{
+		topics:  make(map[string]*Topic),
+		peers:   make(map[string]map[ID]bool),
+		counts:  make(map[ID]int),
+		mesh:    make(map[string]map[ID]bool),
+		seen:    make(map[ID]time.Time),
+		cache:   make(map[ID]Message),
+		history: make([][]ID, 1),
+	}
+
+	// The sequence starts from time to avoid reusing a sequence after restarts.
+	p.seq.Store(uint64(time.Now().UnixNano()))
+	return p
+}
+
+// subscribed returns the peers subscribed to topic.
+// The caller must hold the lock.
+func (p *pubsub) subscribed(topic string) []ID {
+	peers := make([]ID, 0, len(p.peers[topic]))
+	for id := range p.peers[topic] {
+		peers = append(peers, id)
+	}
+
+	return peers
+}
+
+// subscribe records the topic subscribed by remote peer.
+// It returns false if the peer reached the max number of subscriptions.
+// The caller must hold the lock.
+func (p *pubsub) subscribe(topic string, id ID) bool {
+	if p.peers[topic][id] {
+		return true
+	}
+
+	if p.counts[id] >= maxTopicsPerPeer {
+		return false
+	}
+
+	peers, ok := p.peers[topic]
+	if !ok {
+		peers = make(map[ID]bool)
+		p.peers[topic] = peers
+	}
+
+	peers[id] = true
+	p.counts[id]++
+	return true
+}
+
+// unsubscribe forgets the topic subscribed by remote peer.
+// The caller must hold the lock.
+func (p *pubsub) unsubscribe(topic string, id ID) {
+	peers := p.peers[topic]
+	if !peers[id] {
+		return
+	}
+
+	if delete(peers, id); len(peers) == 0 {
+		delete(p.peers, topic)
+	}
+
+	if p.counts[id]--; p.counts[id] == 0 {
+		delete(p.counts, id)
+	}
+}
+
+// remember marks the message as seen and keeps it in cache for gossip.
+// It returns false if the message was already seen.
+// The caller must hold the lock.
+func (p *pubsub) remember(m Message) bool {
+	id := m.ID()
+	if _, ok := p.seen[id]; ok {
+		return false
+	}
+
+	p.seen[id] = time.Now()
+	p.cache[id] = m
+	p.history[0] = append(p.history[0], id)
+	return true
+}
+
+// shift starts a new history window dropping the oldest messages from cache and expired seen IDs.
+// The caller must hold the lock.
+func (p *pubsub) shift() {
+	p.history = append([][]ID{nil}, p.history...)
+	if len(p.history) > historyWindows {
+		for _, id := range p.history[historyWindows] {
+			delete(p.cache, id)
+		}
+
+		p.history = p.history[:historyWindows]
+	}
+
+	for id, seen := range p.seen {
+		if time.Since(seen) > seenTTL {
+			delete(p.seen, id)
+		}
+	}
+}
+
+// gossip returns the recent message IDs published in topic.
+// The caller must hold the lock.
+func (p *pubsub) gossip(topic string) []ID {
+	var ids []ID
+	for i := 0; i < gossipWindows && i < len(p.history); i++ {
+		for _, id := range p.history[i] {
+			if len(ids) == maxGossipIDs {
+				return ids
+			}
+
+			if p.cache[id].Topic == topic {
+				ids = append(ids, id)
+			}
+		}
+	}
+
+	return ids
+}
+
+// outgoing is a pubsub message waiting to be sent to peer.
+type outgoing struct {
+	to ID
+	m  pubsubMessage
+}
+
+// deliver sends the pubsub messages to connected peers.
+// Messages are collected while holding the pubsub lock and sent after release.
+func (n *Node) deliver(out []outgoing) {
+	for _, o := range out {
+		peer, ok := n.router.Query(o.to)
+		if !ok {
+			continue
+		}
+
+		if _, err := peer.send(pubsubFrame, encodePubSub(o.m)); err != nil {
+			log.Printf("error sending pubsub message: %v", err)
+		}
+	}
+}
+
+// Join subscribes to topic announcing the subscription to connected peers.
+// Messages published in topic by any peer in the network are received through the returned topic.
+// It returns an error if the topic name is invalid, the topic was already joined or the node is closed.
+func (n *Node) Join(topic string) (*Topic, error) {
+	if topic == "" || len(topic) > maxTopicLength {
+		return nil, errInvalidTopic(topic)
+	}
+
+	n.pubsub.mu.Lock()
+	if n.pubsub.closed {
+		n.pubsub.mu.Unlock()
+		return nil, errNodeClosed()
+	}
+
+	if _, ok := n.pubsub.topics[topic]; ok {
+		n.pubsub.mu.Unlock()
+		return nil, errTopicJoined(topic)
+	}
+
+	t := &Topic{n, topic, make(chan Message, topicBufferSize)}
+	n.pubsub.topics[topic] = t
+	n.pubsub.mesh[topic] = make(map[ID]bool)
+	n.pubsub.mu.Unlock()
+
+	var out []outgoing
+	for p := range n.router.Table() {
+		out = append(out, outgoing{p.ID(), pubsubMessage{Kind: pubsubSubscribe, Topic: topic}})
+	}
+
+	n.deliver(out)
+	// Build the mesh with the already known subscribers.
+	n.deliver(n.maintain(topic))
+
+	interval := n.config.PubSubHeartbeat()
+	if interval > 0 && n.pubsub.started.CompareAndSwap(false, true) && n.track() {
+		go n.heartbeat(interval)
+	}
+
+	return t, nil
+}
+
+// leave removes the subscription to topic notifying the connected peers.
+func (n *Node) leave(t *Topic) {
+	n.pubsub.mu.Lock()
+	if n.pubsub.topics[t.name] != t {
+		n.pubsub.mu.Unlock()
+		return
+	}
+
+	delete(n.pubsub.topics, t.name)
+	delete(n.pubsub.mesh, t.name)
+	close(t.messages)
+	n.pubsub.mu.Unlock()
+
+	var out []outgoing
+	for p := range n.router.Table() {
+		out = append(out, outgoing{p.ID(), pubsubMessage{Kind: pubsubUnsubscribe, Topic: t.name}})
+	}
+
+	n.deliver(out)
+}
+
+// closeTopics closes the subscriptions of joined topics on node shutdown.
+// Topics can't be joined after close.
+func (n *Node) closeTopics() {
+	n.pubsub.mu.Lock()
+	defer n.pubsub.mu.Unlock()
+	n.pubsub.closed = true
+	for name, t := range n.pubsub.topics {
+		delete(n.pubsub.topics, name)
+		delete(n.pubsub.mesh, name)
+		close(t.messages)
+	}
+}
+
+// signMessage creates a message for topic signed with the node identity.
+func (n *Node) signMessage(topic string, data []byte) (Message, error) {
+	kr, err := n.keyRing()
+	if err != nil {
+		return Message{}, err
+	}
+
+	m := Message{
+		Topic:     topic,
+		Data:      data,
+		Seq:       n.pubsub.seq.Add(1),
+		Publisher: kr.sv.Public,
+	}
+
+	m.Signature = ed25519.Sign(kr.sv.Private, m.signedBytes())
+	return m, nil
+}
+
+// publishTopic signs and sends the data to topic mesh peers.
+// If the mesh is not built yet the message is sent to every known subscriber.
+func (n *Node) publishTopic(t *Topic, data []byte) error {
+	m, err := n.signMessage(t.name, data)
+	if err != nil {
+		return err
+	}
+
+	n.pubsub.mu.Lock()
+	if n.pubsub.topics[t.name] != t {
+		n.pubsub.mu.Unlock()
+		return errTopicClosed(t.name)
+	}
+
+	n.pubsub.remember(m)
+	peers := n.pubsub.subscribed(t.name)
+	if mesh := n.pubsub.mesh[t.name]; len(mesh) > 0 {
+		peers = peers[:0]
+		for id := range mesh {
+			peers = append(peers, id)
+		}
+	}
+	n.pubsub.mu.Unlock()
+
+	out := make([]outgoing, 0, len(peers))
+	for _, id := range peers {
+		out = append(out, outgoing{id, pubsubMessage{Kind: pubsubPublish, Topic: t.name, Message: m}})
+	}
+
+	n.deliver(out)
+	return nil
+}
+
+// subscriptions announces the joined topics to a new connected peer.
+func (n *Node) subscriptions(peer *peer) {
+	n.pubsub.mu.Lock()
+	out := make([]outgoing, 0, len(n.pubsub.topics))
+	for topic := range n.pubsub.topics {
+		out = append(out, outgoing{peer.ID(), pubsubMessage{Kind: pubsubSubscribe, Topic: topic}})
+	}
+	n.pubsub.mu.Unlock()
+
+	n.deliver(out)
+}
+
+// unsubscribe forgets the topics subscribed by a disconnected peer.
+func (n *Node) unsubscribe(id ID) {
+	n.pubsub.mu.Lock()
+	defer n.pubsub.mu.Unlock()
+	for topic := range n.pubsub.peers {
+		n.pubsub.unsubscribe(topic, id)
+	}
+
+	for _, mesh := range n.pubsub.mesh {
+		delete(mesh, id)
+	}
+}
+
+// handlePubSub process an incoming pubsub message from peer.
+// Published messages are validated, delivered to the local subscription and forwarded to mesh peers only once.
+func (n *Node) handlePubSub(peer *peer, msg []byte) {
+	m, err := decodePubSub(msg)
+	if err != nil {
+		log.Printf("error decoding pubsub message: %v", err)
+		return
+	}
+
+	if m.Topic == "" || len(m.Topic) > maxTopicLength {
+		return
+	}
+
+	id := peer.ID()
+	var out []outgoing
+	n.pubsub.mu.Lock()
+	_, joined := n.pubsub.topics[m.Topic]
+	switch m.Kind {
+	case pubsubSubscribe:
+		n.pubsub.subscribe(m.Topic, id)
+	case pubsubUnsubscribe:
+		n.pubsub.unsubscribe(m.Topic, id)
+		delete(n.pubsub.mesh[m.Topic], id)
+	case pubsubPublish:
+		if m.Message.Topic != m.Topic || !joined {
+			break
+		}
+
+		if err := validateMessage(m.Message); err != nil {
+			log.Printf("pubsub message rejected: %v", err)
+			break
+		}
+
+		if !n.pubsub.remember(m.Message) {
+			break
+		}
+
+		select {
+		case n.pubsub.topics[m.Topic].messages <- m.Message:
+		default:
+			log.Printf("pubsub message dropped for slow subscription: %s", m.Topic)
+		}
+
+		from := m.Message.From()
+		for mesh := range n.pubsub.mesh[m.Topic] {
+			if mesh != id && mesh != from {
+				out = append(out, outgoing{mesh, m})
+			}
+		}
+	case pubsubGraft:
+		if !joined {
+			out = append(out, outgoing{id, pubsubMessage{Kind: pubsubPrune, Topic: m.Topic}})
+			break
+		}
+
+		n.pubsub.mesh[m.Topic][id] = true
+	case pubsubPrune:
+		delete(n.pubsub.mesh[m.Topic], id)
+	case pubsubIHave:
+		if !joined {
+			break
+		}
+
+		var want []ID
+		for i, msgID := range m.IDs {
+			if i == maxGossipIDs {
+				break
+			}
+
+			if _, ok := n.pubsub.seen[msgID]; !ok {
+				want = append(want, msgID)
+			}
+		}
+
+		if len(want) > 0 {
+			out = append(out, outgoing{id, pubsubMessage{Kind: pubsubIWant, Topic: m.Topic, IDs: want}})
+		}
+	case pubsubIWant:
+		for i, msgID := range m.IDs {
+			if i == maxGossipIDs {
+				break
+			}
+
+			if cached, ok := n.pubsub.cache[msgID]; ok && cached.Topic == m.Topic {
+				out = append(out, outgoing{id, pubsubMessage{Kind: pubsubPublish, Topic: m.Topic, Message: cached}})
+			}
+		}
+	}
+	n.pubsub.mu.Unlock()
+
+	n.deliver(out)
+}
+
+// maintain keeps the topic mesh degree between the low and high bounds and returns the graft and prune
+// messages for the selected peers. The gossip about recent messages is sent to subscribers outside the mesh.
+func (n *Node) maintain(topic string) []outgoing {
+	d := n.config.PubSubDegree()
+	low, high := d-d/3, 2*d
+
+	n.pubsub.mu.Lock()
+	defer n.pubsub.mu.Unlock()
+	mesh, ok := n.pubsub.mesh[topic]
+	if !ok {
+		return nil
+	}
+
+	var out []outgoing
+	for id := range mesh {
+		if !n.connected(id) {
+			delete(mesh, id)
+		}
+	}
+
+	var outside []ID
+	for _, id := range n.pubsub.subscribed(topic) {
+		if !mesh[id] && n.connected(id) {
+			outside = append(outside, id)
+		}
+	}
+
+	rand.Shuffle(len(outside), func(i, j int) { outside[i], outside[j] = outside[j], outside[i] })
+	if len(mesh) < low {
+		for len(mesh) < d && len(outside) > 0 {
+			mesh[outside[0]] = true
+			out = append(out, outgoing{outside[0], pubsubMessage{Kind: pubsubGraft, Topic: topic}})
+			outside = outside[1:]
+		}
+	}
+
+	if len(mesh) > high {
+		for id := range mesh {
+			if len(mesh) <= d {
+				break
+			}
+
+			delete(mesh, id)
+			out = append(out, outgoing{id, pubsubMessage{Kind: pubsubPrune, Topic: topic}})
+		}
+	}
+
+	if ids := n.pubsub.gossip(topic); len(ids) > 0 {
+		for i, id := range outside {
+			if i == d {
+				break
+			}
+
+			out = append(out, outgoing{id, pubsubMessage{Kind: pubsubIHave, Topic: topic, IDs: ids}})
+		}
+	}
+
+	return out
+}
+
+// heartbeat maintains the mesh of joined topics and gossips recent messages on every interval
+// until node is shutting down.
+func (n *Node) heartbeat(interval time.Duration) {
+	defer n.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-n.done:
+			return
+		}
+
+		n.pubsub.mu.Lock()
+		topics := make([]string, 0, len(n.pubsub.topics))
+		for topic := range n.pubsub.topics {
+			topics = append(topics, topic)
+		}
+		n.pubsub.mu.Unlock()
+
+		for _, topic := range topics {
+			n.deliver(n.maintain(topic))
+		}
+
+		n.pubsub.mu.Lock()
+		n.pubsub.shift()
+		n.pubsub.mu.Unlock()
+	}
+}
@@ -0,0 +1,199 @@
+package noise
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/geolffreym/p2p-noise/config"
+)
+
+func TestValidateMessage(t *testing.T) {
+	node := New(config.New())
+	defer node.Close()
+
+	valid, _ := node.signMessage("news", []byte("hello"))
+	tampered := valid
+	tampered.Data = []byte("evil")
+	moved := valid
+	moved.Topic = "other"
+
+	cases := []struct {
+		name    string
+		message Message
+		valid   bool
+	}{
+		{"valid", valid, true},
+		{"tampered", tampered, false},
+		{"topic changed", moved, false},
+		{"invalid publisher", Message{Topic: "news"}, false},
+		{"invalid topic", Message{}, false},
+	}
+
+	for _, e := range cases {
+		t.Run(e.name, func(t *testing.T) {
+			if err := validateMessage(e.message); (err == nil) != e.valid {
+				t.Errorf("expected valid = %v, got %v", e.valid, err)
+			}
+		})
+	}
+
+	if valid.From() != node.ID() {
+		t.Errorf("expected message from node identity")
+	}
+}
+
+func TestPubSubHistory(t *testing.T) {
+	p := newPubSub()
+	news := Message{Topic: "news", Seq: 1}
+	sports := Message{Topic: "sports", Seq: 2}
+
+	if !p.remember(news) || p.remember(news) || !p.remember(sports) {
+		t.Fatalf("expected duplicated message rejected")
+	}
+
+	if ids := p.gossip("news"); len(ids) != 1 || ids[0] != news.ID() {
+		t.Errorf("expected gossip only for topic messages, got %d", len(ids))
+	}
+
+	for i := 0; i < gossipWindows; i++ {
+		p.shift()
+	}
+
+	if len(p.gossip("news")) != 0 {
+		t.Errorf("expected old messages not gossiped")
+	}
+
+	if _, ok := p.cache[news.ID()]; !ok {
+		t.Errorf("expected message kept in cache for history windows")
+	}
+
+	for i := gossipWindows; i < historyWindows; i++ {
+		p.shift()
+	}
+
+	if _, ok := p.cache[news.ID()]; ok || len(p.history) != historyWindows {
+		t.Errorf("expected message dropped from cache after history windows")
+	}
+
+	if p.remember(news) {
+		t.Errorf("expected message still seen after dropped from cache")
+	}
+}
+
+func TestPubSubSubscriptions(t *testing.T) {
+	p := newPubSub()
+	for i := 0; i < maxTopicsPerPeer; i++ {
+		if !p.subscribe(fmt.Sprintf("topic-%d", i), ID{1}) {
+			t.Fatalf("expected subscription %d accepted", i)
+		}
+	}
+
+	if !p.subscribe("topic-0", ID{1}) || p.counts[ID{1}] != maxTopicsPerPeer {
+		t.Errorf("expected repeated subscription not counted")
+	}
+
+	if p.subscribe("exceeded", ID{1}) {
+		t.Errorf("expected max %d subscriptions per peer", maxTopicsPerPeer)
+	}
+
+	if _, ok := p.peers["exceeded"]; ok {
+		t.Errorf("expected no topic tracked for rejected subscription")
+	}
+
+	p.unsubscribe("topic-0", ID{1})
+	if _, ok := p.peers["topic-0"]; ok || !p.subscribe("exceeded", ID{1}) {
+		t.Errorf("expected subscription accepted after unsubscribe")
+	}
+}
+
+func TestJoinPublish(t *testing.T) {
+	nodes := make([]*Node, 3)
+	for i := range nodes {
+		configuration := config.New()
+		configuration.Write(
+			config.SetSelfListeningAddress("127.0.0.1:"),
+			config.SetPubSubHeartbeat(50*time.Millisecond),
+			config.SetDHTRefreshInterval(0),
+		)
+
+		nodes[i] = New(configuration)
+		defer nodes[i].Close()
+		<-whenReadyForIncomingDial(nodes[i])
+	}
+
+	// A line topology: messages from A reach C through B.
+	hub := nodes[1].LocalAddr().String()
+	for _, node := range []*Node{nodes[0], nodes[2]} {
+		if err := node.Dial(hub); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	topics := make([]*Topic, len(nodes))
+	for i, node := range nodes {
+		topic, err := node.Join("news")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		topics[i] = topic
+	}
+
+	if _, err := nodes[0].Join("news"); err == nil {
+		t.Errorf("expected error joining topic twice")
+	}
+
+	// Publish until the mesh is built.
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	timeout := time.After(5 * time.Second)
+	for received := false; !received; {
+		select {
+		case <-ticker.C:
+			topics[0].Publish([]byte("hello"))
+		case m := <-topics[2].Messages():
+			if string(m.Data) != "hello" || m.From() != nodes[0].ID() {
+				t.Errorf("expected message published by A, got %q", m.Data)
+			}
+
+			received = true
+		case <-timeout:
+			t.Fatalf("expected message delivered through relay")
+		}
+	}
+
+	topics[2].Leave()
+	if _, ok := <-topics[2].Messages(); ok {
+		t.Errorf("expected messages channel closed after leave")
+	}
+
+	if err := topics[2].Publish([]byte("bye")); err == nil {
+		t.Errorf("expected error publishing in left topic")
+	}
+}
+
+func TestTopicClosedOnClose(t *testing.T) {
+	configuration := config.New()
+	configuration.Write(config.SetPubSubHeartbeat(0))
+	node := New(configuration)
+
+	topic, err := node.Join("news")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	node.Close()
+	select {
+	case _, ok := <-topic.Messages():
+		if ok {
+			t.Errorf("expected messages channel closed after close")
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("expected messages channel closed without heartbeat")
+	}
+
+	if _, err := node.Join("sports"); err == nil {
+		t.Errorf("expected error joining topic after close")
+	}
+}
@@ -0,0 +1,178 @@
+package noise
+
+import (
+	"context"
+	"log"
+	"math/rand"
+	"time"
+)
+
+// backoff calculate a jittered exponential delay for a redial attempt.
+// The delay is doubled on each attempt up to max and then a random jitter between [delay/2, delay] is taken.
+// Jitter avoid that many nodes redial the same peer in lockstep.
+func backoff(attempt int, base, max time.Duration) time.Duration {
+	delay := base
+	for i := 0; i < attempt && delay < max; i++ {
+		delay *= 2
+	}
+
+	if delay > max {
+		delay = max
+	}
+
+	if delay <= 1 {
+		return delay
+	}
+
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// AddPersistentPeer marks the address as persistent and keeps it connected.
+// The address is dialed immediately and redialed using exponential backoff every time the peer get disconnected.
+// After MaxReconnectAttempts consecutive failed attempts the node gives up and the address is no longer persistent.
+// Returns an error if the node is shutting down.
+func (n *Node) AddPersistentPeer(addr string) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.closed {
+		return errNodeClosed()
+	}
+
+	if _, exists := n.persistent[addr]; exists {
+		return nil
+	}
+
+	stop := make(chan struct{})
+	n.persistent[addr] = stop
+	// Shutdown should wait for the reconnection routine.
+	n.wg.Add(1)
+	go n.keepConnected(addr, stop)
+	return nil
+}
+
+// RemovePersistentPeer stops redialing the address.
+// The current connection with the peer is not closed.
+func (n *Node) RemovePersistentPeer(addr string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if stop, exists := n.persistent[addr]; exists {
+		delete(n.persistent, addr)
+		close(stop)
+	}
+}
+
+// PersistentPeers returns the addresses marked as persistent.
+func (n *Node) PersistentPeers() []string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	addrs := make([]string, 0, len(n.persistent))
+	for addr := range n.persistent {
+		addrs = append(addrs, addr)
+	}
+
+	return addrs
+}
+
+// forget removes the address from persistent peers if it wasn't removed before.
+func (n *Node) forget(addr string, stop chan struct{}) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if current, exists := n.persistent[addr]; exists && current == stop {
+		delete(n.persistent, addr)
+	}
+}
+
+// keepConnected dial the persistent address and wait until the peer get disconnected to dial again.
+// The routine stops when the node is shutting down, the address is removed from persistent peers or max attempts is exceeded.
+func (n *Node) keepConnected(addr string, stop chan struct{}) {
+	defer n.wg.Done()
+	defer n.forget(addr, stop)
+
+	// Dialing is aborted if the address is removed from persistent peers.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	// Last protected peer identity for address.
+	var protected ID
+	defer func() { n.manager.Unprotect(protected, persistentTag) }()
+
+	attempt := 0
+	reconnecting := false
+	max := n.config.MaxReconnectAttempts()
+
+	for {
+		if reconnecting {
+			n.events.PeerReconnecting(addr)
+		}
+
+		peer, err := n.dial(ctx, addr)
+		if err == nil {
+			log.Printf("persistent peer connected: %s", addr)
+			// Persistent peers are never trimmed by connection manager.
+			n.manager.Unprotect(protected, persistentTag)
+			protected = peer.ID()
+			n.manager.Protect(protected, persistentTag)
+			if reconnecting {
+				n.events.PeerReconnected(peer, addr)
+			}
+
+			// Wait until the peer get disconnected to start again.
+			attempt = 0
+			if !n.waitDisconnected(peer, stop) {
+				return
+			}
+
+			reconnecting = true
+			continue
+		}
+
+		attempt++
+		reconnecting = true
+		log.Printf("error dialing persistent peer %s, attempt %d: %v", addr, attempt, err)
+		if max > 0 && attempt >= max {
+			log.Printf("giving up reconnecting to %s", addr)
+			return
+		}
+
+		delay := backoff(attempt-1, n.config.ReconnectBackoff(), n.config.MaxReconnectBackoff())
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-stop:
+			timer.Stop()
+			return
+		case <-n.done:
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// waitDisconnected blocks until the peer get disconnected.
+// It returns false if the node is shutting down or the address is removed from persistent peers.
+func (n *Node) waitDisconnected(peer *peer, stop chan struct{}) bool {
+	for {
+		select {
+		case <-peer.Done():
+			// The connection could be replaced by another connection with the same peer.
+			current, ok := n.router.Query(peer.ID())
+			if !ok || current == peer {
+				return true
+			}
+
+			peer = current
+		case <-stop:
+			return false
+		case <-n.done:
+			return false
+		}
+	}
+}
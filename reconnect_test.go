@@ -0,0 +1,126 @@
+package noise
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/geolffreym/p2p-noise/config"
+)
+
+func TestBackoff(t *testing.T) {
+	base := 100 * time.Millisecond
+	max := time.Second
+
+	attempts := []struct {
+		attempt int
+		min     time.Duration
+		max     time.Duration
+	}{
+		{0, base / 2, base},
+		{1, base, 2 * base},
+		{2, 2 * base, 4 * base},
+		{10, max / 2, max},
+	}
+
+	for _, e := range attempts {
+		delay := backoff(e.attempt, base, max)
+		if delay < e.min || delay > e.max {
+			t.Errorf("expected delay for attempt %d between %v and %v, got %v", e.attempt, e.min, e.max, delay)
+		}
+	}
+}
+
+func TestPersistentPeerReconnect(t *testing.T) {
+	configurationA := config.New()
+	configurationB := config.New()
+	configurationA.Write(config.SetSelfListeningAddress("127.0.0.1:"))
+	configurationB.Write(config.SetReconnectBackoff(10 * time.Millisecond))
+
+	nodeA := New(configurationA)
+	nodeB := New(configurationB)
+	defer nodeA.Close()
+	defer nodeB.Close()
+
+	signalsA, cancelA := nodeA.Signals()
+	signalsB, cancelB := nodeB.Signals()
+	defer cancelA()
+	defer cancelB()
+
+	go nodeA.Listen()
+	if _, ok := waitFor(signalsA, SelfListening, 3*time.Second); !ok {
+		t.Fatalf("expected node listening")
+	}
+
+	addr := nodeA.LocalAddr().String()
+	if err := nodeB.AddPersistentPeer(addr); err != nil {
+		t.Fatal(err)
+	}
+
+	detected, ok := waitFor(signalsA, NewPeerDetected, 3*time.Second)
+	if !ok {
+		t.Fatalf("expected peer detected")
+	}
+
+	nodeA.ClosePeer(detected.Payload(), ReasonLocalClose)
+
+	reconnecting, ok := waitFor(signalsB, PeerReconnecting, 3*time.Second)
+	if !ok {
+		t.Fatalf("expected peer reconnecting")
+	}
+
+	if reconnecting.Payload() != addr {
+		t.Errorf("expected reconnecting to %s, got %s", addr, reconnecting.Payload())
+	}
+
+	reconnected, ok := waitFor(signalsB, PeerReconnected, 3*time.Second)
+	if !ok {
+		t.Fatalf("expected peer reconnected")
+	}
+
+	if reconnected.Payload() != addr {
+		t.Errorf("expected reconnected to %s, got %s", addr, reconnected.Payload())
+	}
+}
+
+func TestPersistentPeerGiveUp(t *testing.T) {
+	// Get a free address without listener.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	addr := listener.Addr().String()
+	listener.Close()
+
+	configuration := config.New()
+	configuration.Write(
+		config.SetReconnectBackoff(time.Millisecond),
+		config.SetMaxReconnectAttempts(2),
+	)
+
+	node := New(configuration)
+	defer node.Close()
+	node.AddPersistentPeer(addr)
+
+	timeout := time.After(2 * time.Second)
+	for len(node.PersistentPeers()) > 0 {
+		select {
+		case <-timeout:
+			t.Fatalf("expected persistent peer removed after max attempts")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func TestRemovePersistentPeer(t *testing.T) {
+	node := New(config.New())
+	defer node.Close()
+
+	node.AddPersistentPeer("127.0.0.1:1")
+	node.RemovePersistentPeer("127.0.0.1:1")
+
+	if len(node.PersistentPeers()) != 0 {
+		t.Errorf("expected no persistent peers after remove")
+	}
+}
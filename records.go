@@ -0,0 +1,456 @@
+package noise
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// maxRecordSize is the max size allowed for a record value.
+const maxRecordSize = 4 << 10 // 4KB
+
+// maxRecordTTL is the max time a record is accepted to live before expiration.
+const maxRecordTTL = 7 * 24 * time.Hour
+
+// [Record] is a small value published in the DHT and signed by the publisher identity key.
+// Records are namespaced by publisher, only the publisher can update its own records.
+// A record with a higher sequence replaces the previous one.
+type Record struct {
+	Key       string
+	Value     []byte
+	Seq       uint64
+	Expires   time.Time
+	Publisher PublicKey
+	Signature []byte
+}
+
+// ID returns the publisher ID.
+func (r Record) ID() ID {
+	return newBlake2ID(r.Publisher)
+}
+
+// Expired returns true if the record expiration time has passed.
+func (r Record) Expired() bool {
+	return time.Now().After(r.Expires)
+}
+
+// signedBytes returns the record fields covered by signature.
+func (r Record) signedBytes() []byte {
+	var buffer bytes.Buffer
+	binary.Write(&buffer, binary.BigEndian, uint32(len(r.Key)))
+	buffer.WriteString(r.Key)
+	binary.Write(&buffer, binary.BigEndian, uint32(len(r.Value)))
+	buffer.Write(r.Value)
+	binary.Write(&buffer, binary.BigEndian, r.Seq)
+	binary.Write(&buffer, binary.BigEndian, r.Expires.UnixNano())
+	return buffer.Bytes()
+}
+
+// newer returns true if record a replaces record b.
+// Higher sequence wins, for the same sequence the later expiration wins.
+func newer(a, b Record) bool {
+	return a.Seq > b.Seq || (a.Seq == b.Seq && a.Expires.After(b.Expires))
+}
+
+// recordKey returns the DHT key for the publisher record key.
+func recordKey(publisher ID, key string) ID {
+	return newBlake2ID(append(publisher.Bytes(), key...))
+}
+
+// encodeRecord encode a record to bytes.
+func encodeRecord(r Record) []byte {
+	var buffer bytes.Buffer
+	gob.NewEncoder(&buffer).Encode(r)
+	return buffer.Bytes()
+}
+
+// decodeRecord decode incoming bytes to record.
+func decodeRecord(b []byte) (Record, error) {
+	var r Record
+	err := gob.NewDecoder(bytes.NewReader(b)).Decode(&r)
+	return r, err
+}
+
+// validateRecord checks the record belongs to DHT key, is not expired and is signed by publisher.
+func validateRecord(key ID, r Record) error {
+	if len(r.Publisher) != ed25519.PublicKeySize {
+		return errInvalidRecord(errors.New("invalid publisher key"))
+	}
+
+	if len(r.Value) > maxRecordSize {
+		return errInvalidRecord(fmt.Errorf("value size %d exceeds max size %d", len(r.Value), maxRecordSize))
+	}
+
+	if recordKey(r.ID(), r.Key) != key {
+		return errInvalidRecord(errors.New("key mismatch"))
+	}
+
+	if r.Expired() || time.Until(r.Expires) > maxRecordTTL {
+		return errInvalidRecord(errors.New("invalid expiration"))
+	}
+
+	if !ed25519.Verify(r.Publisher, r.signedBytes(), r.Signature) {
+		return errInvalidRecord(errors.New("invalid signature"))
+	}
+
+	return nil
+}
+
+// checkRecord decodes and validates a record received for DHT key.
+func checkRecord(key ID, raw []byte) (Record, error) {
+	r, err := decodeRecord(raw)
+	if err != nil {
+		return Record{}, errInvalidRecord(err)
+	}
+
+	return r, validateRecord(key, r)
+}
+
+// [RecordStore] keeps the records stored by the local node.
+// Please see [Node.SetRecordStore] to use a custom store.
+type RecordStore interface {
+	// Get returns the record for DHT key.
+	Get(key ID) (Record, bool)
+	// Put stores the record for DHT key replacing any previous record.
+	Put(key ID, record Record) error
+}
+
+// [MemoryRecordStore] implements an in-memory [RecordStore] bounded in total and per publisher records.
+// Expired records are removed on read, with Purge or when the store is full.
+type MemoryRecordStore struct {
+	mu           sync.Mutex
+	records      map[ID]Record
+	publishers   map[ID]int // records stored for each publisher
+	max          int
+	perPublisher int
+}
+
+// NewMemoryRecordStore creates a new empty in-memory record store.
+// The store keeps up to max records and up to perPublisher records for each publisher, 0 means unlimited.
+func NewMemoryRecordStore(max, perPublisher int) *MemoryRecordStore {
+	return &MemoryRecordStore{
+		records:      make(map[ID]Record),
+		publishers:   make(map[ID]int),
+		max:          max,
+		perPublisher: perPublisher,
+	}
+}
+
+// Get returns the record for DHT key if it isn't expired.
+func (m *MemoryRecordStore) Get(key ID) (Record, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r, ok := m.records[key]
+	if ok && r.Expired() {
+		m.remove(key)
+		return Record{}, false
+	}
+
+	return r, ok
+}
+
+// Put stores the record for DHT key.
+// Replacing a stored record is always allowed. A new record is rejected if its publisher reached the limit,
+// if the store is full the expired records are purged and then the record closest to expiration is evicted,
+// unless the new record expires before any stored record.
+func (m *MemoryRecordStore) Put(key ID, record Record) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.records[key]; ok {
+		m.records[key] = record
+		return nil
+	}
+
+	publisher := record.ID()
+	if m.perPublisher > 0 && m.publishers[publisher] >= m.perPublisher {
+		return errRecordLimit(fmt.Errorf("publisher %x reached %d records", publisher.Bytes(), m.perPublisher))
+	}
+
+	if m.max > 0 && len(m.records) >= m.max {
+		m.purge()
+	}
+
+	if m.max > 0 && len(m.records) >= m.max {
+		evict, ok := m.closestToExpire()
+		if !ok || m.records[evict].Expires.After(record.Expires) {
+			return errRecordLimit(fmt.Errorf("store reached %d records", m.max))
+		}
+
+		m.remove(evict)
+	}
+
+	m.records[key] = record
+	m.publishers[publisher]++
+	return nil
+}
+
+// Purge removes the expired records.
+func (m *MemoryRecordStore) Purge() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.purge()
+}
+
+// purge removes the expired records.
+// The caller must hold the lock.
+func (m *MemoryRecordStore) purge() {
+	for key, r := range m.records {
+		if r.Expired() {
+			m.remove(key)
+		}
+	}
+}
+
+// closestToExpire returns the key of the stored record closest to expiration.
+// The caller must hold the lock.
+func (m *MemoryRecordStore) closestToExpire() (ID, bool) {
+	var key ID
+	var expires time.Time
+	found := false
+	for k, r := range m.records {
+		if !found || r.Expires.Before(expires) {
+			key, expires, found = k, r.Expires, true
+		}
+	}
+
+	return key, found
+}
+
+// remove deletes the record for DHT key updating the publisher count.
+// The caller must hold the lock.
+func (m *MemoryRecordStore) remove(key ID) {
+	r, ok := m.records[key]
+	if !ok {
+		return
+	}
+
+	delete(m.records, key)
+	publisher := r.ID()
+	if m.publishers[publisher]--; m.publishers[publisher] <= 0 {
+		delete(m.publishers, publisher)
+	}
+}
+
+// SetRecordStore sets the store used to keep the records stored by the local node.
+func (n *Node) SetRecordStore(store RecordStore) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.records = store
+}
+
+// recordStore returns the current record store.
+func (n *Node) recordStore() RecordStore {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.records
+}
+
+// storeRecord validates and stores a record received for DHT key.
+// Records older than the already stored record are rejected.
+func (n *Node) storeRecord(key ID, raw []byte) error {
+	r, err := checkRecord(key, raw)
+	if err != nil {
+		return err
+	}
+
+	store := n.recordStore()
+	if existing, ok := store.Get(key); ok && !newer(r, existing) {
+		return errInvalidRecord(fmt.Errorf("stale sequence %d", r.Seq))
+	}
+
+	return store.Put(key, r)
+}
+
+// localValue returns the encoded record stored locally for DHT key.
+func (n *Node) localValue(key ID) []byte {
+	if r, ok := n.recordStore().Get(key); ok {
+		return encodeRecord(r)
+	}
+
+	return nil
+}
+
+// publish stores the record locally and in the closest peers to record key.
+// It returns an error if no peer stored the record.
+func (n *Node) publish(ctx context.Context, r Record) error {
+	key := recordKey(r.ID(), r.Key)
+	if err := n.recordStore().Put(key, r); err != nil {
+		return err
+	}
+
+	contacts, _ := n.lookup(ctx, key, dhtFindNode)
+	results := make(chan error, len(contacts))
+	for _, c := range contacts {
+		go func(c contact) {
+			res, err := n.request(ctx, c, dhtMessage{Kind: dhtStore, Target: key, Value: encodeRecord(r)})
+			if err == nil && res.Error != "" {
+				err = errors.New(res.Error)
+			}
+
+			results <- err
+		}(c)
+	}
+
+	var stored int
+	err := errors.New("no peers available")
+	for range contacts {
+		if e := <-results; e != nil {
+			err = e
+			continue
+		}
+
+		stored++
+	}
+
+	if stored == 0 {
+		return errRecordNotStored(err)
+	}
+
+	return nil
+}
+
+// sign creates a signed record expiring after the record TTL.
+// The TTL is capped to maxRecordTTL since peers reject records living longer.
+func (n *Node) sign(key string, value []byte, seq uint64) (Record, error) {
+	kr, err := n.keyRing()
+	if err != nil {
+		return Record{}, err
+	}
+
+	ttl := n.config.RecordTTL()
+	if ttl > maxRecordTTL {
+		ttl = maxRecordTTL
+	}
+
+	r := Record{
+		Key:       key,
+		Value:     value,
+		Seq:       seq,
+		Expires:   time.Now().Add(ttl),
+		Publisher: kr.sv.Public,
+	}
+
+	r.Signature = ed25519.Sign(kr.sv.Private, r.signedBytes())
+	return r, nil
+}
+
+// PutRecord publishes a value for key in the DHT signed by the local identity.
+// The record is stored in the closest peers to key and republished periodically until node is closed.
+// Every put increments the record sequence so the new value replaces the previous one.
+// It returns an error if the value is too big or no peer stored the record.
+func (n *Node) PutRecord(ctx context.Context, key string, value []byte) error {
+	if len(value) > maxRecordSize {
+		return errInvalidRecord(fmt.Errorf("value size %d exceeds max size %d", len(value), maxRecordSize))
+	}
+
+	k := recordKey(n.ID(), key)
+	n.dht.mu.Lock()
+	previous, ok := n.dht.published[k]
+	n.dht.mu.Unlock()
+
+	// Continue the sequence from previous runs if the record is still in the network.
+	seq := uint64(1)
+	if ok {
+		seq = previous.Seq + 1
+	} else if existing, err := n.GetRecord(ctx, n.ID().String(), key); err == nil {
+		seq = existing.Seq + 1
+	}
+
+	// Allocate the sequence and keep the record at once, concurrent puts for key never reuse a sequence.
+	n.dht.mu.Lock()
+	if current, ok := n.dht.published[k]; ok && current.Seq >= seq {
+		seq = current.Seq + 1
+	}
+
+	r, err := n.sign(key, value, seq)
+	if err != nil {
+		n.dht.mu.Unlock()
+		return err
+	}
+
+	n.dht.published[k] = r
+	n.dht.mu.Unlock()
+
+	interval := n.config.RecordRepublishInterval()
+	if interval > 0 && n.dht.republishing.CompareAndSwap(false, true) && n.track() {
+		go n.republishLoop(interval)
+	}
+
+	return n.publish(ctx, r)
+}
+
+// GetRecord returns the record published for key by the peer ID.
+// The DHT is queried for the record, the locally stored record is returned if it is newer or the lookup fails.
+// It returns an error if no valid record is found.
+func (n *Node) GetRecord(ctx context.Context, rawID string, key string) (Record, error) {
+	k := recordKey(newIDFromString(rawID), key)
+	local, ok := n.recordStore().Get(k)
+
+	_, raw := n.lookup(ctx, k, dhtFindValue)
+	if raw != nil {
+		if r, err := checkRecord(k, raw); err == nil && (!ok || newer(r, local)) {
+			return r, nil
+		}
+	}
+
+	if ok {
+		return local, nil
+	}
+
+	return Record{}, errRecordNotFound(key)
+}
+
+// republishLoop renews the expiration of published records and stores them again in the closest peers
+// until node is shutting down. Expired records are purged from the local store if supported.
+func (n *Node) republishLoop(interval time.Duration) {
+	defer n.wg.Done()
+	ctx, cancel := n.background()
+	defer cancel()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+
+		if purger, ok := n.recordStore().(interface{ Purge() }); ok {
+			purger.Purge()
+		}
+
+		n.dht.mu.Lock()
+		published := make([]Record, 0, len(n.dht.published))
+		for _, r := range n.dht.published {
+			published = append(published, r)
+		}
+		n.dht.mu.Unlock()
+
+		for _, previous := range published {
+			r, err := n.sign(previous.Key, previous.Value, previous.Seq)
+			if err != nil {
+				log.Printf("error signing record: %v", err)
+				continue
+			}
+
+			n.dht.mu.Lock()
+			// The record could be updated in the meantime.
+			if current := n.dht.published[recordKey(r.ID(), r.Key)]; current.Seq == r.Seq {
+				n.dht.published[recordKey(r.ID(), r.Key)] = r
+			}
+			n.dht.mu.Unlock()
+
+			if err := n.publish(ctx, r); err != nil {
+				log.Printf("error republishing record: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package noise
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/geolffreym/p2p-noise/config"
+)
+
+func TestValidateRecord(t *testing.T) {
+	node := New(config.New())
+	defer node.Close()
+
+	signed := func(key string, value []byte, ttl time.Duration) Record {
+		r, _ := node.sign(key, value, 1)
+		r.Expires = time.Now().Add(ttl)
+		r.Signature = ed25519.Sign(node.kr.sv.Private, r.signedBytes())
+		return r
+	}
+
+	valid := signed("svc", []byte("addr"), time.Hour)
+	tampered := valid
+	tampered.Value = []byte("evil")
+
+	cases := []struct {
+		name   string
+		key    ID
+		record Record
+		valid  bool
+	}{
+		{"valid", recordKey(node.ID(), "svc"), valid, true},
+		{"tampered", recordKey(node.ID(), "svc"), tampered, false},
+		{"key mismatch", recordKey(node.ID(), "other"), valid, false},
+		{"expired", recordKey(node.ID(), "svc"), signed("svc", nil, -time.Second), false},
+		{"ttl too long", recordKey(node.ID(), "svc"), signed("svc", nil, 2*maxRecordTTL), false},
+		{"oversized", recordKey(node.ID(), "svc"), signed("svc", make([]byte, maxRecordSize+1), time.Hour), false},
+		{"invalid publisher", recordKey(node.ID(), "svc"), Record{Key: "svc"}, false},
+	}
+
+	for _, e := range cases {
+		t.Run(e.name, func(t *testing.T) {
+			if err := validateRecord(e.key, e.record); (err == nil) != e.valid {
+				t.Errorf("expected valid = %v, got %v", e.valid, err)
+			}
+		})
+	}
+}
+
+func TestMemoryRecordStore(t *testing.T) {
+	store := NewMemoryRecordStore(0, 0)
+	store.Put(ID{1}, Record{Expires: time.Now().Add(time.Hour)})
+	store.Put(ID{2}, Record{Expires: time.Now().Add(-time.Second)})
+
+	if _, ok := store.Get(ID{1}); !ok {
+		t.Errorf("expected record stored")
+	}
+
+	if _, ok := store.Get(ID{2}); ok {
+		t.Errorf("expected expired record removed")
+	}
+
+	store.Put(ID{3}, Record{Expires: time.Now().Add(-time.Second)})
+	store.Purge()
+	if len(store.records) != 1 {
+		t.Errorf("expected expired records purged, got %d records", len(store.records))
+	}
+}
+
+func TestMemoryRecordStoreLimits(t *testing.T) {
+	store := NewMemoryRecordStore(3, 2)
+	publisherA := PublicKey(mockBytes(PeerAPb))
+	publisherB := PublicKey(mockBytes(PeerBPb))
+	expires := func(d time.Duration) time.Time { return time.Now().Add(d) }
+
+	store.Put(ID{1}, Record{Publisher: publisherA, Expires: expires(time.Hour)})
+	store.Put(ID{2}, Record{Publisher: publisherA, Expires: expires(2 * time.Hour)})
+	if err := store.Put(ID{3}, Record{Publisher: publisherA, Expires: expires(time.Hour)}); err == nil {
+		t.Errorf("expected record rejected over publisher limit")
+	}
+
+	// Replacing a stored record doesn't count towards limits.
+	if err := store.Put(ID{1}, Record{Publisher: publisherA, Expires: expires(3 * time.Hour)}); err != nil {
+		t.Errorf("expected stored record replaced, got %v", err)
+	}
+
+	store.Put(ID{4}, Record{Publisher: publisherB, Expires: expires(4 * time.Hour)})
+	if err := store.Put(ID{5}, Record{Publisher: publisherB, Expires: expires(time.Minute)}); err == nil {
+		t.Errorf("expected record expiring first rejected with full store")
+	}
+
+	// The record closest to expiration is evicted.
+	if err := store.Put(ID{5}, Record{Publisher: publisherB, Expires: expires(5 * time.Hour)}); err != nil {
+		t.Errorf("expected record stored evicting the closest to expire, got %v", err)
+	}
+
+	if _, ok := store.Get(ID{2}); ok || len(store.records) != 3 {
+		t.Errorf("expected closest to expire record evicted, got %d records", len(store.records))
+	}
+
+	if store.publishers[newBlake2ID(publisherA)] != 1 {
+		t.Errorf("expected publisher count updated after eviction")
+	}
+}
+
+func TestPutGetRecord(t *testing.T) {
+	nodes := make([]*Node, 3)
+	for i := range nodes {
+		configuration := config.New()
+		configuration.Write(config.SetSelfListeningAddress("127.0.0.1:"))
+		nodes[i] = New(configuration)
+		defer nodes[i].Close()
+		<-whenReadyForIncomingDial(nodes[i])
+	}
+
+	nodeA, nodeB, nodeC := nodes[0], nodes[1], nodes[2]
+	for _, node := range []*Node{nodeA, nodeC} {
+		if err := node.Dial(nodeB.LocalAddr().String()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for seq, value := range []string{"v1", "v2"} {
+		if err := nodeA.PutRecord(ctx, "svc", []byte(value)); err != nil {
+			t.Fatal(err)
+		}
+
+		r, err := nodeC.GetRecord(ctx, nodeA.ID().String(), "svc")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(r.Value) != value || r.Seq != uint64(seq+1) || r.ID() != nodeA.ID() {
+			t.Errorf("expected value %s with sequence %d, got %s with sequence %d", value, seq+1, r.Value, r.Seq)
+		}
+	}
+
+	// Older sequences are rejected.
+	stale, _ := nodeA.sign("svc", []byte("v0"), 1)
+	var secErr *SecError
+	if err := nodeB.storeRecord(recordKey(nodeA.ID(), "svc"), encodeRecord(stale)); !errors.As(err, &secErr) {
+		t.Errorf("expected stale record rejected, got %v", err)
+	}
+
+	if _, err := nodeC.GetRecord(ctx, nodeA.ID().String(), "missing"); err == nil {
+		t.Errorf("expected error for missing record")
+	}
+}
+
+func TestPutRecordLongTTL(t *testing.T) {
+	configurationA := config.New()
+	configurationA.Write(config.SetRecordTTL(30 * 24 * time.Hour))
+	configurationB := config.New()
+	configurationB.Write(config.SetSelfListeningAddress("127.0.0.1:"))
+
+	nodeA := New(configurationA)
+	nodeB := New(configurationB)
+	defer nodeA.Close()
+	defer nodeB.Close()
+
+	<-whenReadyForIncomingDial(nodeB)
+	if err := nodeA.Dial(nodeB.LocalAddr().String()); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Concurrent puts for the same key get a different sequence each.
+	const puts = 4
+	results := make(chan error, puts)
+	for i := 0; i < puts; i++ {
+		go func() { results <- nodeA.PutRecord(ctx, "svc", []byte("value")) }()
+	}
+
+	var stored int
+	for i := 0; i < puts; i++ {
+		// A put could be rejected as stale if a newer sequence arrives first.
+		if err := <-results; err == nil {
+			stored++
+		}
+	}
+
+	if stored == 0 {
+		t.Fatalf("expected record stored with capped ttl")
+	}
+
+	r, ok := nodeB.recordStore().Get(recordKey(nodeA.ID(), "svc"))
+	if !ok {
+		t.Fatalf("expected record stored in remote peer")
+	}
+
+	if r.Seq != puts {
+		t.Errorf("expected sequence %d after concurrent puts, got %d", puts, r.Seq)
+	}
+
+	if time.Until(r.Expires) > maxRecordTTL {
+		t.Errorf("expected expiration capped to %s, got %s", maxRecordTTL, time.Until(r.Expires))
+	}
+}
@@ -10,7 +10,8 @@ import (
 // Unstructured P2P topologies do not attempt to organize all peers into a single, structured topology.
 // Rather, each peer attempts to keep a "sensible" set of other peers in its routing table.
 type router struct {
-	sync.Map // embed map
+	sync.Map            // embed map
+	mu       sync.Mutex // serialize writes to table
 	counter  uint32
 }
 
@@ -53,20 +54,46 @@ func (r *router) Query(id ID) (*peer, bool) {
 }
 
 // Add forward method to internal sync.Map store for peer.
-func (r *router) Add(peer *peer) {
+// It returns false if there is a peer already routed with the same ID.
+func (r *router) Add(peer *peer) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, loaded := r.LoadOrStore(peer.ID(), peer); loaded {
+		return false
+	}
+
 	atomic.AddUint32(&r.counter, 1)
-	r.Store(peer.ID(), peer)
+	return true
+}
+
+// Replace swap the routed peer with a new peer with the same ID.
+// It returns false if the old peer is not routed anymore.
+func (r *router) Replace(old, new *peer) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if current, ok := r.Query(old.ID()); !ok || current != old {
+		return false
+	}
+
+	r.Store(new.ID(), new)
+	return true
 }
 
 // Len return the number of routed connections.
-func (r *router) Len() uint8 {
-	return uint8(atomic.LoadUint32(&r.counter))
+func (r *router) Len() uint32 {
+	return atomic.LoadUint32(&r.counter)
 }
 
 // Remove forward method to internal sync.Map to delete a connection from router.
-// It return recently removed peer.
+// Removing an already removed or replaced peer has no effect.
 func (r *router) Remove(peer *peer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if current, ok := r.Query(peer.ID()); !ok || current != peer {
+		return
+	}
+
+	r.Delete(peer.ID())
 	// ref: https://github.com/golang/go/blob/509ee7064207cc9c8ac81bc76f182a5fbb877e9b/src/sync/atomic/doc.go#L96
 	atomic.AddUint32(&r.counter, ^uint32(0))
-	r.Delete(peer.ID())
 }
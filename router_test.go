@@ -121,3 +121,66 @@ func TestDelete(t *testing.T) {
 	}
 
 }
+
+func TestRemoveTwice(t *testing.T) {
+	router := newRouter()
+	router.Add(peerA)
+	router.Add(peerB)
+
+	// removing an already removed peer should not affect the counter
+	router.Remove(peerA)
+	router.Remove(peerA)
+
+	if router.Len() != 1 {
+		t.Errorf("expected 1 len for registered peers, got %v", router.Len())
+	}
+}
+
+func TestAddDuplicated(t *testing.T) {
+	router := newRouter()
+	duplicated := newPeer(sessionA)
+
+	if !router.Add(peerA) {
+		t.Errorf("expected peer added to router")
+	}
+
+	if router.Add(duplicated) {
+		t.Errorf("expected duplicated peer not added to router")
+	}
+
+	if router.Len() != 1 {
+		t.Errorf("expected 1 len for registered peers, got %v", router.Len())
+	}
+}
+
+func TestReplace(t *testing.T) {
+	router := newRouter()
+	duplicated := newPeer(sessionA)
+	router.Add(peerA)
+
+	if !router.Replace(peerA, duplicated) {
+		t.Errorf("expected routed peer replaced")
+	}
+
+	// Removing the replaced peer should keep the new one.
+	router.Remove(peerA)
+	if peer, ok := router.Query(peerA.ID()); !ok || peer != duplicated {
+		t.Errorf("expected replacement peer routed, got %v", peer)
+	}
+
+	if router.Len() != 1 {
+		t.Errorf("expected 1 len for registered peers, got %v", router.Len())
+	}
+}
+
+func TestLenBeyond255(t *testing.T) {
+	router := newRouter()
+	for i := 0; i < 1000; i++ {
+		pb := PublicKey(fmt.Sprintf("peer-%d", i))
+		router.Add(newPeer(mockSession(&mockConn{}, pb)))
+	}
+
+	if router.Len() != 1000 {
+		t.Errorf("expected 1000 len for registered peers, got %v", router.Len())
+	}
+}
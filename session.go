@@ -37,11 +37,13 @@ type session struct {
 	svk        PublicKey // remote public key
 	encryption CipherState
 	decryption CipherState
+	cert       []byte // remote certificate
+	static     []byte // remote static DH key
 }
 
 // Create a new secure session
 func newSession(conn net.Conn, kr KeyRing) (*session, error) {
-	return &session{conn, kr, PublicKey{}, nil, nil}, nil
+	return &session{conn, kr, PublicKey{}, nil, nil, nil, nil}, nil
 }
 
 // Set encryption/decryption state for session.
@@ -66,6 +68,27 @@ func (s *session) Decrypt(out, digest []byte) ([]byte, error) {
 	return s.decryption.Decrypt(out, nil, digest)
 }
 
+// SetRemoteCertificate set the certificate presented by remote peer during handshake.
+func (s *session) SetRemoteCertificate(cert []byte) {
+	s.cert = cert
+}
+
+// RemoteCertificate returns the certificate presented by remote peer during handshake.
+// It is empty if the remote peer didn't present a certificate.
+func (s *session) RemoteCertificate() []byte {
+	return s.cert
+}
+
+// SetRemoteStatic set the static DH key provided by remote peer during handshake.
+func (s *session) SetRemoteStatic(static []byte) {
+	s.static = static
+}
+
+// RemoteStatic returns the static DH key provided by remote peer during handshake.
+func (s *session) RemoteStatic() []byte {
+	return s.static
+}
+
 // RemotePublicKey returns the static key provided by the remote peer during a handshake.
 func (s *session) RemotePublicKey() []byte {
 	return s.svk
@@ -78,6 +101,11 @@ func (s *session) Sign(msg []byte) []byte {
 
 // Verify message with remote public key.
 func (s *session) Verify(msg, sig []byte) bool {
+	// Verify panics with invalid public key size.
+	if len(s.svk) != ed25519.PublicKeySize {
+		return false
+	}
+
 	// Use remote peer public key to verify message
 	return ed25519.Verify(s.svk, msg, sig)
 }
@@ -3,8 +3,10 @@ package noise
 // header keep the context for triggered signal.
 type header struct {
 	// Type of event published
-	peer  *peer // Hold the involved peer
-	event Event // Hold the triggered event
+	peer   *peer  // Hold the involved peer
+	event  Event  // Hold the triggered event
+	reason Reason // Hold the reason for disconnection events
+	origin ID     // Hold the originator for broadcast and relayed messages
 }
 
 // Peer return bundled peer
@@ -30,6 +32,24 @@ func (s *Signal) Type() Event {
 	return s.header.Type()
 }
 
+// Reason forward internal signal header reason.
+// Reason is bundled only in PeerDisconnected signals otherwise ReasonUnknown is returned.
+func (s *Signal) Reason() Reason {
+	return s.header.reason
+}
+
+// Origin returns the originator ID for broadcast and relayed messages.
+// Origin is bundled only in BroadcastReceived, RelayedMessageReceived and DeliveryFailed signals
+// otherwise the involved peer ID is returned.
+// For DeliveryFailed signals the origin is the peer reporting the failure.
+func (s *Signal) Origin() string {
+	if s.header.origin == (ID{}) && s.header.peer != nil {
+		return s.header.peer.ID().String()
+	}
+
+	return s.header.origin.String()
+}
+
 // Reply send an answer to peer in context.
 func (s *Signal) Reply(msg []byte) (uint32, error) {
 	return s.header.Peer().Send(msg)
@@ -8,7 +8,7 @@ const PAYLOAD = "hello test"
 
 func TestType(t *testing.T) {
 	event := NewPeerDetected
-	message := Signal{header{nil, event}, PAYLOAD}
+	message := Signal{header{nil, event, ReasonUnknown, ID{}}, PAYLOAD}
 
 	if message.Type() != event {
 		t.Errorf("expected message with type %v, got %v", event, message.Type())
@@ -19,7 +19,7 @@ func TestPayload(t *testing.T) {
 	event := MessageReceived
 	session := mockSession(&mockConn{}, nil)
 	peer := newPeer(session)
-	header := header{peer, NewPeerDetected}
+	header := header{peer, NewPeerDetected, ReasonUnknown, ID{}}
 	message := Signal{header, PAYLOAD}
 
 	if message.Payload() != PAYLOAD {
@@ -27,3 +27,24 @@ func TestPayload(t *testing.T) {
 	}
 
 }
+
+func TestOrigin(t *testing.T) {
+	session := mockSession(&mockConn{}, nil)
+	peer := newPeer(session)
+	origin := ID{1}
+
+	received := Signal{header{peer, MessageReceived, ReasonUnknown, ID{}}, PAYLOAD}
+	if received.Origin() != peer.ID().String() {
+		t.Errorf("expected origin to be the involved peer")
+	}
+
+	broadcast := Signal{header{peer, BroadcastReceived, ReasonUnknown, origin}, PAYLOAD}
+	if broadcast.Origin() != origin.String() {
+		t.Errorf("expected origin to be the broadcast originator")
+	}
+
+	relayed := Signal{header{peer, RelayedMessageReceived, ReasonUnknown, origin}, PAYLOAD}
+	if relayed.Origin() != origin.String() {
+		t.Errorf("expected origin to be the relayed message sender")
+	}
+}
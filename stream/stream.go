@@ -1,3 +1,232 @@
+// Package stream implements the delivery patterns on top of a node, eg. multicast to named groups of peers.
 package stream
 
-// usar este paquete para enviar multicast, unicast, broadcast
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	noise "github.com/geolffreym/p2p-noise"
+)
+
+// [Sender] sends messages to routed peers, eg. [noise.Node].
+type Sender interface {
+	Send(rawID string, message []byte) (uint32, error)
+}
+
+// [Sealer] is a [Sender] able to sign and encode a message once to be sent to many peers, eg. [noise.Node].
+type Sealer interface {
+	Sender
+	Seal(message []byte) (noise.Envelope, error)
+	SendEnvelope(rawID string, envelope noise.Envelope) (uint32, error)
+}
+
+// [Result] is the outcome of sending a message to a group member.
+type Result struct {
+	ID    string // raw peer ID
+	Bytes uint32 // bytes sent
+	Err   error
+}
+
+// [Results] aggregates the outcome of sending a message to every group member.
+type Results []Result
+
+// Sent returns the number of members the message was sent to.
+func (r Results) Sent() int {
+	var sent int
+	for _, result := range r {
+		if result.Err == nil {
+			sent++
+		}
+	}
+
+	return sent
+}
+
+// Failed returns the results for members the message couldn't be sent to.
+func (r Results) Failed() Results {
+	var failed Results
+	for _, result := range r {
+		if result.Err != nil {
+			failed = append(failed, result)
+		}
+	}
+
+	return failed
+}
+
+// Err returns a [GroupError] if the message couldn't be sent to some member, otherwise nil.
+func (r Results) Err() error {
+	if failed := r.Failed(); len(failed) > 0 {
+		return &GroupError{failed}
+	}
+
+	return nil
+}
+
+// [GroupError] holds the members a message couldn't be sent to.
+type GroupError struct {
+	Failed Results
+}
+
+func (e *GroupError) Error() string {
+	errs := make([]string, len(e.Failed))
+	for i, result := range e.Failed {
+		errs[i] = fmt.Sprintf("%x: %v", result.ID, result.Err)
+	}
+
+	return fmt.Sprintf("multicast: failed sending to %d peers -> %s", len(e.Failed), strings.Join(errs, "; "))
+}
+
+// [Group] is a named group of peers receiving the same messages.
+// Please see [Multicast.Group] to create a group.
+type Group struct {
+	sender      Sender
+	name        string
+	mu          sync.RWMutex // guard members and settings
+	members     map[string]struct{}
+	encryptOnce bool
+}
+
+// Name returns the group name.
+func (g *Group) Name() string {
+	return g.name
+}
+
+// Add adds the raw peer IDs to group.
+func (g *Group) Add(ids ...string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, id := range ids {
+		g.members[id] = struct{}{}
+	}
+}
+
+// Remove removes the raw peer IDs from group.
+func (g *Group) Remove(ids ...string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, id := range ids {
+		delete(g.members, id)
+	}
+}
+
+// Has returns true if the raw peer ID is a group member.
+func (g *Group) Has(id string) bool {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	_, ok := g.members[id]
+	return ok
+}
+
+// Members returns the raw peer IDs in group.
+func (g *Group) Members() []string {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	members := make([]string, 0, len(g.members))
+	for id := range g.members {
+		members = append(members, id)
+	}
+
+	return members
+}
+
+// Len returns the number of members in group.
+func (g *Group) Len() int {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return len(g.members)
+}
+
+// SetEncryptOnce sets if messages are signed and encoded once for every member.
+// Only the session encryption is done for each member, the sender must implement [Sealer].
+func (g *Group) SetEncryptOnce(enabled bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.encryptOnce = enabled
+}
+
+// Send sends the message concurrently to every group member connected to node.
+// It returns the result for each member, please see [Results.Err] to check if any send failed.
+func (g *Group) Send(message []byte) Results {
+	g.mu.RLock()
+	encryptOnce := g.encryptOnce
+	g.mu.RUnlock()
+
+	members := g.Members()
+	results := make(Results, len(members))
+	send := g.sender.Send
+	if sealer, ok := g.sender.(Sealer); ok && encryptOnce {
+		envelope, err := sealer.Seal(message)
+		if err != nil {
+			for i, id := range members {
+				results[i] = Result{id, 0, err}
+			}
+
+			return results
+		}
+
+		send = func(id string, _ []byte) (uint32, error) {
+			return sealer.SendEnvelope(id, envelope)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i, id := range members {
+		wg.Add(1)
+		go func(i int, id string) {
+			defer wg.Done()
+			bytes, err := send(id, message)
+			results[i] = Result{id, bytes, err}
+		}(i, id)
+	}
+
+	wg.Wait()
+	return results
+}
+
+// [Multicast] keeps the named groups of peers for a node.
+type Multicast struct {
+	sender Sender
+	mu     sync.Mutex
+	groups map[string]*Group
+}
+
+// NewMulticast creates a new multicast sending messages through sender, eg. [noise.Node].
+func NewMulticast(sender Sender) *Multicast {
+	return &Multicast{sender: sender, groups: make(map[string]*Group)}
+}
+
+// Group returns the group with name creating an empty group if it doesn't exist.
+func (m *Multicast) Group(name string) *Group {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if g, ok := m.groups[name]; ok {
+		return g
+	}
+
+	g := &Group{sender: m.sender, name: name, members: make(map[string]struct{})}
+	m.groups[name] = g
+	return g
+}
+
+// Delete removes the group with name.
+func (m *Multicast) Delete(name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.groups, name)
+}
+
+// Groups returns the sorted group names.
+func (m *Multicast) Groups() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	names := make([]string, 0, len(m.groups))
+	for name := range m.groups {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,110 @@
+package stream
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	noise "github.com/geolffreym/p2p-noise"
+)
+
+// The node supports encrypt once multicast.
+var _ Sealer = (*noise.Node)(nil)
+
+type mockSender struct {
+	mu   sync.Mutex
+	sent map[string][]byte
+}
+
+func (m *mockSender) Send(rawID string, message []byte) (uint32, error) {
+	if rawID == "offline" {
+		return 0, errors.New("remote peer disconnected")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent[rawID] = message
+	return uint32(len(message)), nil
+}
+
+type mockSealer struct {
+	mockSender
+	sealed    atomic.Int32
+	envelopes atomic.Int32
+}
+
+func (m *mockSealer) Seal(message []byte) (noise.Envelope, error) {
+	m.sealed.Add(1)
+	return noise.Envelope{}, nil
+}
+
+func (m *mockSealer) SendEnvelope(rawID string, envelope noise.Envelope) (uint32, error) {
+	m.envelopes.Add(1)
+	return m.Send(rawID, nil)
+}
+
+func TestGroupMembers(t *testing.T) {
+	multicast := NewMulticast(&mockSender{sent: make(map[string][]byte)})
+	group := multicast.Group("replicas")
+	group.Add("a", "b", "c")
+	group.Remove("b")
+
+	if multicast.Group("replicas") != group {
+		t.Errorf("expected same group for name")
+	}
+
+	if group.Len() != 2 || !group.Has("a") || group.Has("b") {
+		t.Errorf("expected members a and c, got %v", group.Members())
+	}
+
+	multicast.Group("shard")
+	if names := multicast.Groups(); len(names) != 2 || names[0] != "replicas" {
+		t.Errorf("expected sorted group names, got %v", names)
+	}
+
+	multicast.Delete("shard")
+	if len(multicast.Groups()) != 1 {
+		t.Errorf("expected group deleted")
+	}
+}
+
+func TestGroupSend(t *testing.T) {
+	sender := &mockSender{sent: make(map[string][]byte)}
+	group := NewMulticast(sender).Group("replicas")
+	group.Add("a", "b", "offline")
+
+	results := group.Send([]byte("hello"))
+	if len(results) != 3 || results.Sent() != 2 {
+		t.Fatalf("expected message sent to 2 of 3 members, got %d", results.Sent())
+	}
+
+	if string(sender.sent["a"]) != "hello" || string(sender.sent["b"]) != "hello" {
+		t.Errorf("expected message delivered to online members")
+	}
+
+	var groupErr *GroupError
+	if err := results.Err(); !errors.As(err, &groupErr) || len(groupErr.Failed) != 1 || groupErr.Failed[0].ID != "offline" {
+		t.Errorf("expected error for offline member, got %v", err)
+	}
+}
+
+func TestGroupSendEncryptOnce(t *testing.T) {
+	sealer := &mockSealer{mockSender: mockSender{sent: make(map[string][]byte)}}
+	group := NewMulticast(sealer).Group("replicas")
+	group.Add("a", "b", "c")
+
+	group.Send([]byte("plain"))
+	if sealer.sealed.Load() != 0 {
+		t.Errorf("expected message not sealed by default")
+	}
+
+	group.SetEncryptOnce(true)
+	if results := group.Send([]byte("hello")); results.Err() != nil {
+		t.Fatal(results.Err())
+	}
+
+	if sealer.sealed.Load() != 1 || sealer.envelopes.Load() != 3 {
+		t.Errorf("expected message sealed once and sent to 3 members, got %d seals and %d sends", sealer.sealed.Load(), sealer.envelopes.Load())
+	}
+}
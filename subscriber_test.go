@@ -9,7 +9,7 @@ import (
 func TestSubscriberListen(t *testing.T) {
 	sub := newSubscriber()
 	session := mockSession(&mockConn{}, nil)
-	header := header{newPeer(session), NewPeerDetected}
+	header := header{newPeer(session), NewPeerDetected, ReasonUnknown, ID{}}
 	signaling := Signal{header, ""}
 
 	canceled := make(chan struct{})